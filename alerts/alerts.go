@@ -0,0 +1,308 @@
+package alerts
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/runner"
+	"github.com/vslchnk/aws_quotas_checker/utils"
+)
+
+// State is state of the alert
+type State string
+
+// states of alerts, alert is pending while the alarm is raised for less than for-duration of the alarm,
+// then it is firing until conditions with clear thresholds are not met anymore and it is resolved
+const (
+	StatePending  State = "pending"
+	StateFiring   State = "firing"
+	StateResolved State = "resolved"
+)
+
+// Alert is state of alarms of one quota in one target, the quota has at most one alert with the most severe
+// raised alarm, so changes of usage and alarms of the quota don't create duplicated alerts
+type Alert struct {
+	// account and region or other name of the target
+	Target      string `json:"target"`
+	ServiceCode string `json:"serviceCode"`
+	QuotaCode   string `json:"quotaCode"`
+	QuotaName   string `json:"quotaName,omitempty"`
+	State       State  `json:"state"`
+	// name, severity, thresholds and met conditions of the raised alarm
+	Alarm              string          `json:"alarm"`
+	Severity           runner.Severity `json:"severity"`
+	Threshold          int             `json:"threshold,omitempty"`
+	RemainingThreshold float64         `json:"remainingThreshold,omitempty"`
+	Condition          string          `json:"condition,omitempty"`
+	// combination, for-duration and clear thresholds of the alarm, conditions of the alarm are checked with
+	// clear thresholds while the alert is firing, pending alert is fired when the alarm is raised for for-duration
+	Combine        runner.Combination `json:"combine,omitempty"`
+	For            time.Duration      `json:"for,omitempty"`
+	ClearThreshold int                `json:"clearThreshold,omitempty"`
+	ClearRemaining float64            `json:"clearRemaining,omitempty"`
+	// usage of the last check
+	Usage     float64       `json:"usage"`
+	Value     float64       `json:"value"`
+	Percent   float64       `json:"percent"`
+	Remaining float64       `json:"remaining"`
+	Unit      string        `json:"unit,omitempty"`
+	Period    time.Duration `json:"period,omitempty"`
+	// name of the silence which suppresses the alarm of the quota, empty if it is not suppressed
+	Silence string `json:"silence,omitempty"`
+	// time when conditions were met first, when the alert was fired, resolved and checked last time
+	Since      time.Time  `json:"since"`
+	FiredAt    *time.Time `json:"firedAt,omitempty"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+// returns key of the alert in the state
+func (a *Alert) key() string {
+	return key(a.Target, a.ServiceCode, a.QuotaCode)
+}
+
+// returns key of the quota in the target
+func key(target string, serviceCode string, quotaCode string) string {
+	return target + "/" + serviceCode + "/" + quotaCode
+}
+
+// checks if conditions of the alarm of the alert are still met with clear thresholds, runner checks them for all
+// conditions of the alarm, so alarms without clear thresholds are kept only while they are raised
+func (a *Alert) holds(result runner.CheckResult) bool {
+	for _, name := range result.Holding {
+		if name == a.Alarm {
+			return true
+		}
+	}
+
+	return false
+}
+
+// takes alarm of the result
+func (a *Alert) raise(result runner.CheckResult) {
+	a.Alarm = result.Alarm
+	a.Severity = result.Severity
+	a.Threshold = result.Threshold
+	a.RemainingThreshold = result.RemainingThreshold
+	a.Condition = result.Condition
+	a.Combine = result.Combine
+	a.For = result.For
+	a.ClearThreshold = result.ClearThreshold
+	a.ClearRemaining = result.ClearRemaining
+}
+
+// takes usage of the result
+func (a *Alert) observe(result runner.CheckResult, now time.Time) {
+	a.QuotaName = result.QuotaName
+	a.Usage = result.Usage
+	a.Value = result.Value
+	a.Percent = result.Percent
+	a.Remaining = result.Remaining
+	a.Unit = result.Unit
+	a.Period = result.Period
+	a.UpdatedAt = now
+}
+
+// Transition is change of the alert which is worth a notification: the alert is fired, resolved or its
+// severity is changed while it is firing, alerts which are pending or dropped before firing have no transitions
+type Transition struct {
+	Alert Alert
+	// state before the transition, empty for alerts which are fired without pending state
+	From State
+	// severity before the change of severity of the firing alert, SeverityOK for other transitions
+	PreviousSeverity runner.Severity
+}
+
+// Tracker keeps alerts of quotas between checks, alerts are kept in the file if its path is set
+type Tracker struct {
+	path   string
+	alerts map[string]*Alert
+	// returns current time, it is replaced by tests
+	now func() time.Time
+}
+
+// creates Tracker with alerts from the file, missing file means no alerts, empty path means that alerts are
+// kept only in memory
+func NewTracker(path string) (*Tracker, error) {
+	t := Tracker{}
+	t.path = path
+	t.alerts = make(map[string]*Alert)
+	t.now = time.Now
+
+	if path == "" {
+		return &t, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &t, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error while reading state of alerts: %w", err)
+	}
+
+	alerts := make([]*Alert, 0, 0)
+	if err := json.Unmarshal(data, &alerts); err != nil {
+		return nil, fmt.Errorf("Error while decoding state of alerts %v: %w", path, err)
+	}
+	for _, a := range alerts {
+		t.alerts[a.key()] = a
+	}
+
+	return &t, nil
+}
+
+// returns path of the state file
+func (t *Tracker) Path() string {
+	return t.path
+}
+
+// updates alerts of the target with results of the check and returns transitions sorted by service and quota:
+//   - raised alarm creates pending alert, it is fired when the alarm is raised for for-duration of the alarm,
+//     alarms without for-duration are fired at once, pending alert is dropped when no alarm is raised;
+//   - firing alert is kept while its conditions are met with clear thresholds, more severe alarm replaces
+//     alarm of the alert and less severe one replaces it only when conditions of the alert are not met;
+//   - firing alert is resolved when neither conditions of the alert nor other alarms are met, pending alert is dropped;
+//
+// resolved alerts are kept until the next update, alerts of quotas which usage can't be found are not changed,
+// alerts of quotas which are missing from results are resolved or dropped as alerts without raised alarms,
+// alerts of suppressed alarms keep their state and only usage and silence of them are updated,
+// suppressed alarms don't create alerts, so they have no transitions while silences are active
+func (t *Tracker) Update(target string, results []runner.CheckResult) []Transition {
+	now := t.now()
+	transitions := make([]Transition, 0, 0)
+	checked := make(map[string]bool, len(results))
+
+	for _, result := range results {
+		k := key(target, result.ServiceCode, result.QuotaCode)
+		checked[k] = true
+		if result.Error != nil {
+			continue
+		}
+
+		a, ok := t.alerts[k]
+		if result.Silence != nil {
+			if ok && a.State != StateResolved {
+				a.Silence = result.Silence.Name
+				a.observe(result, now)
+			}
+			continue
+		}
+		if ok {
+			a.Silence = ""
+		}
+		if ok && a.State == StateResolved {
+			delete(t.alerts, k)
+			a, ok = nil, false
+		}
+
+		raised := result.Severity > runner.SeverityOK
+		if !ok {
+			if !raised {
+				continue
+			}
+			a = &Alert{Target: target, ServiceCode: result.ServiceCode, QuotaCode: result.QuotaCode, State: StatePending, Since: now}
+			a.raise(result)
+			t.alerts[k] = a
+		}
+
+		previous := a.Severity
+		switch {
+		case a.State == StateFiring && a.holds(result) && (!raised || a.Severity >= result.Severity):
+			// conditions of the firing alert are still met with clear thresholds
+		case raised:
+			a.raise(result)
+		case a.State == StateFiring:
+			a.State = StateResolved
+			a.ResolvedAt = &now
+			a.observe(result, now)
+			transitions = append(transitions, Transition{Alert: *a, From: StateFiring})
+			continue
+		default:
+			delete(t.alerts, k)
+			continue
+		}
+		a.observe(result, now)
+
+		switch {
+		case a.State == StatePending && now.Sub(a.Since) >= a.For:
+			from := a.State
+			if !ok {
+				from = ""
+			}
+			a.State = StateFiring
+			a.FiredAt = &now
+			transitions = append(transitions, Transition{Alert: *a, From: from})
+		case a.State == StateFiring && a.Severity != previous:
+			transitions = append(transitions, Transition{Alert: *a, From: StateFiring, PreviousSeverity: previous})
+		}
+	}
+
+	for k, a := range t.alerts {
+		if a.Target != target || checked[k] {
+			continue
+		}
+		if a.State != StateFiring {
+			delete(t.alerts, k)
+			continue
+		}
+		a.State = StateResolved
+		a.ResolvedAt = &now
+		a.Silence = ""
+		a.UpdatedAt = now
+		transitions = append(transitions, Transition{Alert: *a, From: StateFiring})
+	}
+
+	sort.SliceStable(transitions, func(i, j int) bool {
+		return transitions[i].Alert.key() < transitions[j].Alert.key()
+	})
+
+	return transitions
+}
+
+// returns all alerts sorted by target, service and quota
+func (t *Tracker) Alerts() []Alert {
+	alerts := make([]Alert, 0, len(t.alerts))
+	for _, a := range t.alerts {
+		alerts = append(alerts, *a)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].key() < alerts[j].key()
+	})
+
+	return alerts
+}
+
+// returns the most severe firing alert of the target, SeverityOK if no alert is firing, suppressed alerts are skipped
+func (t *Tracker) MaxSeverity(target string) runner.Severity {
+	max := runner.SeverityOK
+	for _, a := range t.alerts {
+		if a.Target == target && a.State == StateFiring && a.Silence == "" && a.Severity > max {
+			max = a.Severity
+		}
+	}
+
+	return max
+}
+
+// writes alerts to the file, the file is replaced atomically, nothing is written if path is empty
+func (t *Tracker) Save() error {
+	if t.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(t.Alerts(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("Error while encoding state of alerts: %w", err)
+	}
+
+	if err := utils.WriteFileAtomic(t.path, data); err != nil {
+		return fmt.Errorf("Error while writing state file of alerts: %w", err)
+	}
+
+	return nil
+}
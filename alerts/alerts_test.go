@@ -0,0 +1,265 @@
+package alerts
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/runner"
+)
+
+// returns result of the quota with value 100, alarm warning is raised at 80% and cleared below 70%,
+// alarm critical is raised at 90% and cleared below 85%
+func newTestResult(usage float64, forDuration time.Duration) runner.CheckResult {
+	result := runner.CheckResult{
+		ServiceQuotaUsage: runner.ServiceQuotaUsage{ServiceCode: "ec2", QuotaCode: "L-1", Value: 100, Usage: usage},
+		Percent:           usage,
+		Remaining:         100 - usage,
+	}
+
+	switch {
+	case usage >= 90:
+		result.Severity, result.Alarm, result.Threshold, result.ClearThreshold = runner.SeverityCritical, "critical", 90, 85
+	case usage >= 80:
+		result.Severity, result.Alarm, result.Threshold, result.ClearThreshold = runner.SeverityWarning, "warning", 80, 70
+	}
+	if result.Severity != runner.SeverityOK {
+		result.Combine = runner.CombineAny
+		result.For = forDuration
+	}
+	if usage >= 85 {
+		result.Holding = append(result.Holding, "critical")
+	}
+	if usage >= 70 {
+		result.Holding = append(result.Holding, "warning")
+	}
+
+	return result
+}
+
+func TestUpdate(t *testing.T) {
+	type step struct {
+		usage float64
+		// state and severity of the alert after the step, empty state means no alert
+		state    State
+		severity runner.Severity
+		// transition of the step as "from>to", empty if there is no transition
+		transition string
+	}
+
+	tests := []struct {
+		name  string
+		wait  time.Duration
+		steps []step
+	}{
+		{
+			"fired at once and resolved",
+			0,
+			[]step{
+				{50, "", runner.SeverityOK, ""},
+				{80, StateFiring, runner.SeverityWarning, ">firing"},
+				{85, StateFiring, runner.SeverityWarning, ""},
+				{60, StateResolved, runner.SeverityWarning, "firing>resolved"},
+				{60, "", runner.SeverityOK, ""},
+			},
+		},
+		{
+			"hysteresis",
+			0,
+			[]step{
+				{81, StateFiring, runner.SeverityWarning, ">firing"},
+				{79, StateFiring, runner.SeverityWarning, ""},
+				{81, StateFiring, runner.SeverityWarning, ""},
+				{70, StateFiring, runner.SeverityWarning, ""},
+				{69, StateResolved, runner.SeverityWarning, "firing>resolved"},
+			},
+		},
+		{
+			"escalation",
+			0,
+			[]step{
+				{80, StateFiring, runner.SeverityWarning, ">firing"},
+				{95, StateFiring, runner.SeverityCritical, "firing>firing"},
+				{87, StateFiring, runner.SeverityCritical, ""},
+				{84, StateFiring, runner.SeverityWarning, "firing>firing"},
+				{50, StateResolved, runner.SeverityWarning, "firing>resolved"},
+			},
+		},
+		{
+			"for-duration",
+			2 * time.Minute,
+			[]step{
+				{80, StatePending, runner.SeverityWarning, ""},
+				{85, StatePending, runner.SeverityWarning, ""},
+				{80, StateFiring, runner.SeverityWarning, "pending>firing"},
+			},
+		},
+		{
+			"pending alert is not kept by clear thresholds",
+			2 * time.Minute,
+			[]step{
+				{80, StatePending, runner.SeverityWarning, ""},
+				{75, "", runner.SeverityOK, ""},
+				{75, "", runner.SeverityOK, ""},
+				{75, "", runner.SeverityOK, ""},
+				{80, StatePending, runner.SeverityWarning, ""},
+			},
+		},
+		{
+			"pending alert is dropped",
+			2 * time.Minute,
+			[]step{
+				{80, StatePending, runner.SeverityWarning, ""},
+				{60, "", runner.SeverityOK, ""},
+				{80, StatePending, runner.SeverityWarning, ""},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker, err := NewTracker("")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			tracker.now = func() time.Time { return now }
+
+			for i, s := range tt.steps {
+				transitions := tracker.Update("prod", []runner.CheckResult{newTestResult(s.usage, tt.wait)})
+				now = now.Add(time.Minute)
+
+				transition := ""
+				if len(transitions) > 1 {
+					t.Fatalf("step %v: transitions = %+v, want at most one", i, transitions)
+				}
+				if len(transitions) == 1 {
+					transition = string(transitions[0].From) + ">" + string(transitions[0].Alert.State)
+				}
+				if transition != s.transition {
+					t.Errorf("step %v: transition = %q, want %q", i, transition, s.transition)
+				}
+
+				alerts := tracker.Alerts()
+				state, severity := State(""), runner.SeverityOK
+				if len(alerts) > 0 {
+					state, severity = alerts[0].State, alerts[0].Severity
+				}
+				if state != s.state || severity != s.severity {
+					t.Errorf("step %v: alert = %v %v, want %v %v", i, state, severity, s.state, s.severity)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateKeepsAlertsWithoutUsage(t *testing.T) {
+	tracker, _ := NewTracker("")
+	tracker.Update("prod", []runner.CheckResult{newTestResult(95, 0)})
+
+	failed := newTestResult(0, 0)
+	failed.Error = errors.New("throttled")
+	if transitions := tracker.Update("prod", []runner.CheckResult{failed}); len(transitions) != 0 {
+		t.Errorf("transitions = %+v, want no transitions", transitions)
+	}
+
+	if s := tracker.MaxSeverity("prod"); s != runner.SeverityCritical {
+		t.Errorf("severity = %v, want %v", s, runner.SeverityCritical)
+	}
+}
+
+func TestUpdateMissingQuotas(t *testing.T) {
+	tracker, _ := NewTracker("")
+	pending := newTestResult(80, time.Hour)
+	pending.QuotaCode = "L-2"
+	tracker.Update("prod", []runner.CheckResult{newTestResult(95, 0), pending})
+	tracker.Update("staging", []runner.CheckResult{newTestResult(95, 0)})
+
+	// firing alert of the missing quota is resolved and pending one is dropped
+	transitions := tracker.Update("prod", nil)
+	if len(transitions) != 1 || transitions[0].From != StateFiring || transitions[0].Alert.State != StateResolved || transitions[0].Alert.QuotaCode != "L-1" {
+		t.Errorf("transitions = %+v, want resolved alert", transitions)
+	}
+	if s := tracker.MaxSeverity("prod"); s != runner.SeverityOK {
+		t.Errorf("severity = %v, want %v", s, runner.SeverityOK)
+	}
+	if s := tracker.MaxSeverity("staging"); s != runner.SeverityCritical {
+		t.Errorf("severity = %v, want %v", s, runner.SeverityCritical)
+	}
+
+	// resolved alert is dropped by the next update
+	if transitions := tracker.Update("prod", nil); len(transitions) != 0 {
+		t.Errorf("transitions = %+v, want no transitions", transitions)
+	}
+	if alerts := tracker.Alerts(); len(alerts) != 1 || alerts[0].Target != "staging" {
+		t.Errorf("alerts = %+v, want only alert of staging", alerts)
+	}
+}
+
+func TestUpdateSuppressed(t *testing.T) {
+	tracker, _ := NewTracker("")
+	silence := &runner.Silence{Name: "migration"}
+	suppressed := func(usage float64) runner.CheckResult {
+		result := newTestResult(usage, 0)
+		result.Silence = silence
+		return result
+	}
+
+	// suppressed alarm doesn't create alert
+	if transitions := tracker.Update("prod", []runner.CheckResult{suppressed(95)}); len(transitions) != 0 || len(tracker.Alerts()) != 0 {
+		t.Errorf("transitions = %+v, alerts = %+v, want no alerts", transitions, tracker.Alerts())
+	}
+
+	// firing alert is neither escalated nor resolved while it is suppressed
+	tracker.Update("prod", []runner.CheckResult{newTestResult(80, 0)})
+	for _, usage := range []float64{95, 50} {
+		if transitions := tracker.Update("prod", []runner.CheckResult{suppressed(usage)}); len(transitions) != 0 {
+			t.Errorf("transitions = %+v, want no transitions", transitions)
+		}
+	}
+	alerts := tracker.Alerts()
+	if len(alerts) != 1 || alerts[0].State != StateFiring || alerts[0].Silence != "migration" || alerts[0].Usage != 50 {
+		t.Errorf("alerts = %+v, want suppressed firing alert", alerts)
+	}
+	if s := tracker.MaxSeverity("prod"); s != runner.SeverityOK {
+		t.Errorf("severity = %v, want %v", s, runner.SeverityOK)
+	}
+
+	// alert is resolved when silence ends
+	transitions := tracker.Update("prod", []runner.CheckResult{newTestResult(50, 0)})
+	if len(transitions) != 1 || transitions[0].Alert.State != StateResolved || transitions[0].Alert.Silence != "" {
+		t.Errorf("transitions = %+v, want resolved alert", transitions)
+	}
+}
+
+func TestSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "alerts.json")
+
+	tracker, err := NewTracker(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+	tracker.Update("prod", []runner.CheckResult{newTestResult(80, time.Minute)})
+	if err := tracker.Save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loaded, err := NewTracker(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Alerts(), tracker.Alerts()) {
+		t.Errorf("alerts = %+v, want %+v", loaded.Alerts(), tracker.Alerts())
+	}
+
+	// pending alert of the previous run is fired
+	loaded.now = func() time.Time { return now.Add(time.Minute) }
+	transitions := loaded.Update("prod", []runner.CheckResult{newTestResult(80, time.Minute)})
+	if len(transitions) != 1 || transitions[0].From != StatePending || transitions[0].Alert.State != StateFiring {
+		t.Errorf("transitions = %+v, want pending alert to be fired", transitions)
+	}
+}
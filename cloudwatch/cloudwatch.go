@@ -1,44 +1,197 @@
 package cloudwatch
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"sort"
 	"time"
 
+	"github.com/vslchnk/aws_quotas_checker/forecast"
+	"github.com/vslchnk/aws_quotas_checker/ratelimit"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
 	"github.com/aws/aws-sdk-go/service/servicequotas"
 )
 
+// smallest period of metric statistics in seconds
+const minMetricPeriod = 60
+
+// maximum number of datapoints returned by one request of metric statistics
+const maxDatapoints = 1440
+
+// time which rate-based quotas are checked over by default
+const defaultLookback = time.Hour
+
 type CW struct {
 	region    string
-	client    *cloudwatch.CloudWatch
+	client    cloudwatchiface.CloudWatchAPI
 	period    int64
 	threshold int64
+	lookback  time.Duration
 }
 
 // creates new CW agent
 func NewCW(region string) (*CW, error) {
+	ses, err := session.NewSession(&aws.Config{
+		Region: aws.String(region)},
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("Error while creating session: %w", err)
+	}
+
+	return NewCWWithSession(ses)
+}
+
+// creates new CW agent with client from the shared session
+func NewCWWithSession(ses *session.Session) (*CW, error) {
+	client := cloudwatch.New(ses)
+	ratelimit.Install(&client.Handlers, "cloudwatch")
+
+	c := NewCWWithClient(client)
+	c.region = aws.StringValue(ses.Config.Region)
+
+	return c, nil
+}
+
+// creates new CW agent with the client, any implementation of CloudWatch API can be used
+func NewCWWithClient(client cloudwatchiface.CloudWatchAPI) *CW {
 	c := CW{}
-	c.region = region
 	c.period = 300
 	c.threshold = 5
+	c.lookback = defaultLookback
+	c.client = client
 
-	ses, err := session.NewSession(&aws.Config{
-		Region: aws.String(c.region)},
-	)
+	return &c
+}
 
+// returns usage from for metric for the last 5 minutes
+func (c *CW) GetUsageFromMetric(usageMetric *servicequotas.MetricInfo) (*float64, error) {
+	return c.GetUsageFromMetricWithContext(context.Background(), usageMetric)
+}
+
+// returns usage from for metric for the last 5 minutes, request is made with the context
+func (c *CW) GetUsageFromMetricWithContext(ctx context.Context, usageMetric *servicequotas.MetricInfo) (*float64, error) {
+	endTime := time.Now().UTC()
+	startTime := endTime.Add(time.Duration(-c.threshold) * time.Minute)
+
+	data, err := c.getMetricStatistics(ctx, usageMetric, startTime, endTime, c.period)
 	if err != nil {
-		return nil, fmt.Errorf("Error while creating session: %v", err)
+		return nil, err
 	}
 
-	c.client = cloudwatch.New(ses)
+	usage := 0.0
 
-	return &c, nil
+	if len(data.Datapoints) > 0 {
+		usage = getStatistic(data.Datapoints[0], usageMetric.MetricStatisticRecommendation)
+	}
+
+	return &usage, nil
 }
 
-// returns usage from for metric for the last 5 minutes
-func (c *CW) GetUsageFromMetric(usageMetric *servicequotas.MetricInfo) (*int, error) {
+// sets time which rate-based quotas are checked over, not positive values mean the default hour
+func (c *CW) SetLookback(lookback time.Duration) {
+	c.lookback = lookback
+	if lookback <= 0 {
+		c.lookback = defaultLookback
+	}
+}
+
+// returns peak usage of the rate-based quota over the lookback, usage is normalized to the period of the quota,
+// e.g. requests per second for quotas with one second period, Sum statistic is scaled to the period and Maximum
+// statistic is used as it is
+func (c *CW) GetRateUsageFromMetric(usageMetric *servicequotas.MetricInfo, period time.Duration) (*float64, error) {
+	return c.GetRateUsageFromMetricWithContext(context.Background(), usageMetric, period)
+}
+
+// returns peak usage of the rate-based quota over the lookback normalized to the period of the quota,
+// request is made with the context
+func (c *CW) GetRateUsageFromMetricWithContext(ctx context.Context, usageMetric *servicequotas.MetricInfo, period time.Duration) (*float64, error) {
+	if period <= 0 {
+		return nil, fmt.Errorf("Error while getting rate usage: period %v is not positive", period)
+	}
+
+	metricPeriod := getMetricPeriod(period, c.lookback)
+	endTime := time.Now().UTC()
+	startTime := endTime.Add(-c.lookback)
+
+	data, err := c.getMetricStatistics(ctx, usageMetric, startTime, endTime, metricPeriod)
+	if err != nil {
+		return nil, err
+	}
+
+	// sum of every datapoint covers metric period, so it is scaled to the period of the quota, maximum is the peak
+	// of samples which are already reported per period of the quota, so it is not scaled
+	scale := 1.0
+	if aws.StringValue(usageMetric.MetricStatisticRecommendation) == "Sum" {
+		scale = period.Seconds() / float64(metricPeriod)
+	}
+	usage := 0.0
+
+	for _, d := range data.Datapoints {
+		if rate := getStatistic(d, usageMetric.MetricStatisticRecommendation) * scale; rate > usage {
+			usage = rate
+		}
+	}
+
+	return &usage, nil
+}
+
+// returns usage of the quota from the metric over the lookback sorted by time, usage is the recommended
+// statistic per hour or per longer period which keeps number of datapoints in the limit
+func (c *CW) GetUsageHistoryFromMetric(usageMetric *servicequotas.MetricInfo, lookback time.Duration) ([]forecast.Sample, error) {
+	return c.GetUsageHistoryFromMetricWithContext(context.Background(), usageMetric, lookback)
+}
+
+// returns usage of the quota from the metric over the lookback sorted by time, request is made with the context
+func (c *CW) GetUsageHistoryFromMetricWithContext(ctx context.Context, usageMetric *servicequotas.MetricInfo, lookback time.Duration) ([]forecast.Sample, error) {
+	if lookback <= 0 {
+		return nil, fmt.Errorf("Error while getting usage history: lookback %v is not positive", lookback)
+	}
+
+	endTime := time.Now().UTC()
+	startTime := endTime.Add(-lookback)
+
+	data, err := c.getMetricStatistics(ctx, usageMetric, startTime, endTime, getMetricPeriod(time.Hour, lookback))
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]forecast.Sample, 0, len(data.Datapoints))
+	for _, d := range data.Datapoints {
+		if d.Timestamp == nil {
+			continue
+		}
+		samples = append(samples, forecast.Sample{Time: *d.Timestamp, Usage: getStatistic(d, usageMetric.MetricStatisticRecommendation)})
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].Time.Before(samples[j].Time)
+	})
+
+	return samples, nil
+}
+
+// returns period of metric statistics in seconds for the quota period, it is a multiple of a minute
+// which is not less than the quota period and keeps number of datapoints over the lookback in the limit
+func getMetricPeriod(period time.Duration, lookback time.Duration) int64 {
+	seconds := int64(math.Ceil(period.Seconds()))
+	if min := int64(math.Ceil(lookback.Seconds() / maxDatapoints)); min > seconds {
+		seconds = min
+	}
+
+	if seconds%minMetricPeriod != 0 {
+		seconds += minMetricPeriod - seconds%minMetricPeriod
+	}
+
+	return seconds
+}
+
+// requests statistics of the metric for the time range
+func (c *CW) getMetricStatistics(ctx context.Context, usageMetric *servicequotas.MetricInfo, startTime time.Time, endTime time.Time, period int64) (*cloudwatch.GetMetricStatisticsOutput, error) {
 	dimensions := make([]*cloudwatch.Dimension, 0, len(usageMetric.MetricDimensions))
 
 	for k, v := range usageMetric.MetricDimensions {
@@ -49,15 +202,6 @@ func (c *CW) GetUsageFromMetric(usageMetric *servicequotas.MetricInfo) (*int, er
 		dimensions = append(dimensions, d)
 	}
 
-	loc, err := time.LoadLocation("UTC")
-
-	if err != nil {
-		return nil, fmt.Errorf("Error while loading time location: %v", err)
-	}
-
-	endTime := time.Now().In(loc)
-	startTime := endTime.Add(time.Duration(-c.threshold) * time.Minute)
-
 	params := &cloudwatch.GetMetricStatisticsInput{
 		Dimensions: dimensions,
 		MetricName: usageMetric.MetricName,
@@ -65,26 +209,28 @@ func (c *CW) GetUsageFromMetric(usageMetric *servicequotas.MetricInfo) (*int, er
 		Statistics: []*string{usageMetric.MetricStatisticRecommendation},
 		StartTime:  &startTime,
 		EndTime:    &endTime,
-		Period:     &c.period,
+		Period:     aws.Int64(period),
 	}
 
-	data, err := c.client.GetMetricStatistics(params)
+	data, err := c.client.GetMetricStatisticsWithContext(ctx, params)
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while getting metric statistics: %v", err)
+		return nil, fmt.Errorf("Error while getting metric statistics: %w", err)
 	}
 
-	usage := 0
+	return data, nil
+}
 
-	if data.Datapoints != nil {
-		if *usageMetric.MetricStatisticRecommendation == "Sum" {
-			usage = int(*data.Datapoints[0].Sum)
-		} else if *usageMetric.MetricStatisticRecommendation == "Maximum" {
-			usage = int(*data.Datapoints[0].Maximum)
-		}
+// returns value of the recommended statistic from the datapoint, only Sum and Maximum are supported
+func getStatistic(d *cloudwatch.Datapoint, statistic *string) float64 {
+	switch aws.StringValue(statistic) {
+	case "Sum":
+		return aws.Float64Value(d.Sum)
+	case "Maximum":
+		return aws.Float64Value(d.Maximum)
 	}
 
-	return &usage, nil
+	return 0
 }
 
 // returns actions for IAM policy which allow to work with this package
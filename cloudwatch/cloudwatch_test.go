@@ -0,0 +1,143 @@
+package cloudwatch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/fakes"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+)
+
+func TestGetUsageFromMetricWithContext(t *testing.T) {
+	client := &fakes.CloudWatch{Datapoints: map[string][]*cloudwatch.Datapoint{
+		"ResourceCount": {{Sum: aws.Float64(7.5), Maximum: aws.Float64(3)}},
+		"CallCount":     {},
+	}}
+
+	tests := []struct {
+		name      string
+		metric    string
+		statistic string
+		want      float64
+	}{
+		{"sum", "ResourceCount", "Sum", 7.5},
+		{"maximum", "ResourceCount", "Maximum", 3},
+		{"unknown statistic", "ResourceCount", "Average", 0},
+		{"no datapoints", "CallCount", "Sum", 0},
+		{"missing metric", "Missing", "Maximum", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCWWithClient(client)
+			metric := &servicequotas.MetricInfo{
+				MetricName:                    aws.String(tt.metric),
+				MetricNamespace:               aws.String("AWS/Usage"),
+				MetricStatisticRecommendation: aws.String(tt.statistic),
+				MetricDimensions:              map[string]*string{"Service": aws.String("EC2")},
+			}
+
+			usage, err := c.GetUsageFromMetricWithContext(context.Background(), metric)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *usage != tt.want {
+				t.Errorf("usage = %v, want %v", *usage, tt.want)
+			}
+
+			input := client.Inputs[len(client.Inputs)-1]
+			if len(input.Dimensions) != 1 || aws.StringValue(input.Dimensions[0].Name) != "Service" {
+				t.Errorf("dimensions = %v, want Service dimension", input.Dimensions)
+			}
+		})
+	}
+}
+
+func TestGetRateUsageFromMetricWithContext(t *testing.T) {
+	client := &fakes.CloudWatch{Datapoints: map[string][]*cloudwatch.Datapoint{
+		"CallCount": {{Sum: aws.Float64(120), Maximum: aws.Float64(40)}, {Sum: aws.Float64(300), Maximum: aws.Float64(20)}},
+	}}
+
+	tests := []struct {
+		name       string
+		statistic  string
+		period     time.Duration
+		lookback   time.Duration
+		want       float64
+		wantPeriod int64
+	}{
+		{"per second", "Sum", time.Second, time.Hour, 5, 60},
+		{"per minute", "Sum", time.Minute, time.Hour, 300, 60},
+		{"per 90 seconds", "Sum", 90 * time.Second, time.Hour, 225, 120},
+		{"per hour", "Sum", time.Hour, 0, 300, 3600},
+		{"maximum is not scaled", "Maximum", time.Second, time.Hour, 40, 60},
+		{"maximum per minute", "Maximum", time.Minute, 7 * 24 * time.Hour, 40, 420},
+		{"long lookback", "Sum", time.Second, 7 * 24 * time.Hour, 300.0 / 420, 420},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCWWithClient(client)
+			c.SetLookback(tt.lookback)
+			metric := &servicequotas.MetricInfo{
+				MetricName:                    aws.String("CallCount"),
+				MetricNamespace:               aws.String("AWS/Usage"),
+				MetricStatisticRecommendation: aws.String(tt.statistic),
+			}
+
+			usage, err := c.GetRateUsageFromMetricWithContext(context.Background(), metric, tt.period)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *usage != tt.want {
+				t.Errorf("usage = %v, want %v", *usage, tt.want)
+			}
+
+			input := client.Inputs[len(client.Inputs)-1]
+			if aws.Int64Value(input.Period) != tt.wantPeriod {
+				t.Errorf("period = %v, want %v", aws.Int64Value(input.Period), tt.wantPeriod)
+			}
+		})
+	}
+
+	c := NewCWWithClient(client)
+	if _, err := c.GetRateUsageFromMetric(&servicequotas.MetricInfo{}, 0); err == nil {
+		t.Errorf("expected error for zero period")
+	}
+}
+
+func TestGetUsageHistoryFromMetric(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Hour)
+	client := &fakes.CloudWatch{Datapoints: map[string][]*cloudwatch.Datapoint{
+		"ResourceCount": {
+			{Timestamp: aws.Time(now.Add(-time.Hour)), Maximum: aws.Float64(5)},
+			{Timestamp: aws.Time(now.Add(-3 * time.Hour)), Maximum: aws.Float64(3)},
+			{Maximum: aws.Float64(100)},
+		},
+	}}
+	metric := &servicequotas.MetricInfo{
+		MetricName:                    aws.String("ResourceCount"),
+		MetricNamespace:               aws.String("AWS/Usage"),
+		MetricStatisticRecommendation: aws.String("Maximum"),
+	}
+
+	c := NewCWWithClient(client)
+	samples, err := c.GetUsageHistoryFromMetric(metric, 14*24*time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(samples) != 2 || samples[0].Usage != 3 || samples[1].Usage != 5 {
+		t.Errorf("samples = %+v, want 2 samples sorted by time", samples)
+	}
+	if period := aws.Int64Value(client.Inputs[0].Period); period != 3600 {
+		t.Errorf("period = %v, want 3600", period)
+	}
+
+	if _, err := c.GetUsageHistoryFromMetric(metric, 0); err == nil {
+		t.Errorf("expected error for zero lookback")
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/vslchnk/aws_quotas_checker/alerts"
+	"github.com/vslchnk/aws_quotas_checker/config"
+	"github.com/vslchnk/aws_quotas_checker/render"
+	"github.com/vslchnk/aws_quotas_checker/runner"
+)
+
+// lists services with their names
+func runServices(ctx context.Context, o *options, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%w: unexpected arguments %v", errUsage, args)
+	}
+
+	return o.forEachRunner(ctx, true, func(c *config.Config, t config.Target, r *runner.Runner) (*render.Table, error) {
+		table := render.NewTable(render.Field{Name: "serviceCode", Title: "SERVICE"}, render.Field{Name: "serviceName", Title: "NAME"})
+		for _, s := range r.ListSupportedServicesFromQuotas() {
+			name, _ := r.GetServiceName(s)
+			table.Append(render.Cell{Value: s, Text: s}, render.Cell{Value: name, Text: name})
+		}
+
+		return table, nil
+	})
+}
+
+// lists quotas of the service with their values
+func runQuotas(ctx context.Context, o *options, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: service code is expected", errUsage)
+	}
+	service := args[0]
+
+	return o.forEachRunner(ctx, true, func(c *config.Config, t config.Target, r *runner.Runner) (*render.Table, error) {
+		codes, err := r.GetSupportedQuotasForService(service)
+		if err != nil {
+			return nil, err
+		}
+
+		quotas := make([]runner.ServiceQuota, 0, len(codes))
+		for _, code := range codes {
+			q, err := r.GetServiceQuota(service, code)
+			if err != nil {
+				continue
+			}
+			quotas = append(quotas, *q)
+		}
+
+		return render.Quotas(quotas), nil
+	})
+}
+
+// shows usage of selected quotas, quotas which usage can't be found are reported to stderr
+func runUsage(ctx context.Context, o *options, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%w: unexpected arguments %v", errUsage, args)
+	}
+
+	return o.forEachRunner(ctx, false, func(c *config.Config, t config.Target, r *runner.Runner) (*render.Table, error) {
+		o.printValidationErrors(ctx, r)
+
+		usage := make([]runner.ServiceQuotaUsage, 0, 0)
+		for _, u := range r.GetQuotasUsage() {
+			if u.Error != nil {
+				fmt.Fprintf(o.stderr, "Warning: service %v quota %v: %v\n", u.ServiceCode, u.QuotaCode, u.Error)
+				continue
+			}
+			usage = append(usage, u)
+		}
+
+		return render.Usage(usage), nil
+	})
+}
+
+// shows quotas which usage reaches thresholds of alarms, returned error defines exit code: critical alarms are
+// more important than failed targets and quotas which usage can't be found, they are more important than warnings,
+// if state of alerts is kept only transitions of alerts are shown and only firing alerts define exit code,
+// suppressed alarms are only counted to stderr and don't define exit code
+func runCheck(ctx context.Context, o *options, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%w: unexpected arguments %v", errUsage, args)
+	}
+
+	suites := make([]render.JUnitSuite, 0, 0)
+	severity := runner.SeverityOK
+	quotaErrors := 0
+	var tracker *alerts.Tracker
+
+	err := o.forEachRunner(ctx, false, func(c *config.Config, t config.Target, r *runner.Runner) (*render.Table, error) {
+		if len(c.Alarms) == 0 {
+			return nil, fmt.Errorf("%w: no alarms in config or flags", errUsage)
+		}
+		o.printValidationErrors(ctx, r)
+
+		results := r.CheckQuotasWithContext(ctx)
+		suppressed := 0
+		for _, result := range results {
+			if result.Error != nil {
+				quotaErrors++
+				fmt.Fprintf(o.stderr, "Warning: service %v quota %v: %v\n", result.ServiceCode, result.QuotaCode, result.Error)
+			}
+			if result.Silence != nil {
+				suppressed++
+			}
+		}
+		if suppressed > 0 {
+			fmt.Fprintf(o.stderr, "Note: %v: %v alarms are suppressed by silences, run 'quotactl suppressed' to list them\n", targetName(t), suppressed)
+		}
+		suites = append(suites, render.JUnitSuite{Name: targetName(t), Results: results})
+
+		if c.AlertState == "" {
+			if s := runner.MaxSeverity(results); s > severity {
+				severity = s
+			}
+			return render.Warnings(runner.WarningsFrom(results)), nil
+		}
+
+		if tracker == nil {
+			var err error
+			if tracker, err = alerts.NewTracker(c.AlertState); err != nil {
+				return nil, err
+			}
+		}
+		transitions := tracker.Update(targetName(t), results)
+		if s := tracker.MaxSeverity(targetName(t)); s > severity {
+			severity = s
+		}
+
+		return render.Transitions(transitions), nil
+	})
+	if errors.Is(err, errUsage) {
+		return err
+	}
+
+	if tracker != nil {
+		if serr := tracker.Save(); serr != nil {
+			return serr
+		}
+	}
+
+	if o.junitPath != "" {
+		for _, f := range o.failures {
+			suites = append(suites, render.JUnitSuite{Name: targetName(f.target), Error: f.err})
+		}
+		if werr := writeJUnit(o.junitPath, suites); werr != nil {
+			return werr
+		}
+	}
+
+	switch {
+	case severity == runner.SeverityCritical:
+		return errCritical
+	case errors.Is(err, errTargets):
+		return fmt.Errorf("%w: %v", errCollection, err)
+	case err != nil:
+		return err
+	case quotaErrors > 0:
+		return fmt.Errorf("%w: usage of %v quotas can't be found", errCollection, quotaErrors)
+	case severity == runner.SeverityWarning:
+		return errWarning
+	}
+
+	return nil
+}
+
+// shows alarms which are suppressed by silences, maintenance windows and acknowledgements
+func runSuppressed(ctx context.Context, o *options, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%w: unexpected arguments %v", errUsage, args)
+	}
+
+	return o.forEachRunner(ctx, false, func(c *config.Config, t config.Target, r *runner.Runner) (*render.Table, error) {
+		if len(c.Silences) == 0 {
+			fmt.Fprintln(o.stderr, "Warning: no silences in config")
+		}
+
+		return render.Suppressed(r.CheckSuppressedAlarmsWithContext(ctx)), nil
+	})
+}
+
+// shows forecasts of exhaustion of quotas from usage history, quotas without enough samples are skipped
+func runForecast(ctx context.Context, o *options, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%w: unexpected arguments %v", errUsage, args)
+	}
+
+	return o.forEachRunner(ctx, false, func(c *config.Config, t config.Target, r *runner.Runner) (*render.Table, error) {
+		if c.Options.History == nil {
+			fmt.Fprintln(o.stderr, "Warning: usage history is not kept, use -history or -backfill to make forecasts")
+		}
+
+		return render.Forecasts(r.CheckQuotasWithContext(ctx)), nil
+	})
+}
+
+// writes JUnit XML report to the file
+func writeJUnit(path string, suites []render.JUnitSuite) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("Error while creating JUnit report: %w", err)
+	}
+
+	if err := render.WriteJUnit(f, suites); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("Error while writing JUnit report: %w", err)
+	}
+
+	return nil
+}
+
+// prints actions for IAM policy which are needed for selected quotas, no requests are made
+func runIam(ctx context.Context, o *options, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%w: unexpected arguments %v", errUsage, args)
+	}
+
+	c, err := o.loadConfig()
+	if err != nil {
+		return err
+	}
+	sel, err := c.GetSelector()
+	if err != nil {
+		return err
+	}
+
+	actions := runner.GetIam(sel)
+	// account is requested with STS when it is not set
+	actions["sts"] = []string{"sts:GetCallerIdentity"}
+
+	if !o.policy {
+		rend, sort, err := o.output(c)
+		if err != nil {
+			return err
+		}
+
+		return o.write(rend, sort, render.Iam(actions))
+	}
+
+	unique := make(map[string]bool)
+	for _, list := range actions {
+		for _, a := range list {
+			if a != "" {
+				unique[a] = true
+			}
+		}
+	}
+
+	sorted := make([]string, 0, len(unique))
+	for a := range unique {
+		sorted = append(sorted, a)
+	}
+	sort.Strings(sorted)
+
+	policy := map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{{
+			"Effect":   "Allow",
+			"Action":   sorted,
+			"Resource": "*",
+		}},
+	}
+
+	data, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Error while encoding policy: %w", err)
+	}
+	fmt.Fprintln(o.stdout, string(data))
+
+	return nil
+}
+
+// prints codes of the selector which are not found in the catalog to stderr
+func (o *options) printValidationErrors(ctx context.Context, r *runner.Runner) {
+	validationErrors, _ := r.ValidateWithContext(ctx)
+	for _, e := range validationErrors {
+		fmt.Fprintln(o.stderr, "Warning:", e)
+	}
+}
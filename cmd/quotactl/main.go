@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+)
+
+// exit codes of the tool, warning, critical and collection ones are returned by check command
+const (
+	exitOK         = 0
+	exitError      = 1
+	exitUsage      = 2
+	exitWarning    = 3
+	exitCritical   = 4
+	exitCollection = 5
+)
+
+// command is a subcommand of the tool
+type command struct {
+	name        string
+	args        string
+	description string
+	run         func(ctx context.Context, o *options, args []string) error
+}
+
+// subcommands by name
+var commands = map[string]command{
+	"services":   {"services", "", "list services from Service Quotas", runServices},
+	"quotas":     {"quotas", "<service>", "list quotas of the service with their values", runQuotas},
+	"usage":      {"usage", "", "show usage of selected quotas", runUsage},
+	"check":      {"check", "", "show quotas which usage reaches thresholds of alarms", runCheck},
+	"forecast":   {"forecast", "", "show when usage of selected quotas is projected to reach their values", runForecast},
+	"suppressed": {"suppressed", "", "show alarms which are suppressed by silences, maintenance windows and acknowledgements", runSuppressed},
+	"iam":        {"iam", "", "print actions for IAM policy which are needed for selected quotas", runIam},
+}
+
+// errors which define exit codes
+var (
+	// returned for invalid arguments
+	errUsage = errors.New("invalid arguments")
+	// returned when usage of quotas reaches thresholds of warning or critical alarms
+	errWarning  = errors.New("usage of quotas reaches thresholds of warning alarms")
+	errCritical = errors.New("usage of quotas reaches thresholds of critical alarms")
+	// returned when accounts or regions fail or usage of quotas can't be found
+	errCollection = errors.New("collection of usage failed")
+	// returned when some targets fail and the rest of them are processed
+	errTargets = errors.New("targets failed")
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+
+	os.Exit(code)
+}
+
+// runs the subcommand from arguments and returns exit code
+func run(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		printUsage(stderr)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n", args[0])
+		printUsage(stderr)
+		return exitUsage
+	}
+
+	o := newOptions(stdout, stderr)
+	fs := o.flagSet(cmd)
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if err := cmd.run(ctx, o, fs.Args()); err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		switch {
+		case errors.Is(err, errUsage):
+			fs.Usage()
+			return exitUsage
+		case errors.Is(err, errCritical):
+			return exitCritical
+		case errors.Is(err, errWarning):
+			return exitWarning
+		case errors.Is(err, errCollection):
+			return exitCollection
+		}
+		return exitError
+	}
+
+	return exitOK
+}
+
+// prints list of subcommands
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: quotactl <command> [flags] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+
+	names := make([]string, 0, len(commands))
+	for k := range commands {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		cmd := commands[name]
+		fmt.Fprintf(w, "  %-20v %v\n", strings.TrimSpace(cmd.name+" "+cmd.args), cmd.description)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'quotactl <command> -h' for flags of the command.")
+}
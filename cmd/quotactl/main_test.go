@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/config"
+	"github.com/vslchnk/aws_quotas_checker/render"
+	"github.com/vslchnk/aws_quotas_checker/selector"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{"no command", nil, exitUsage, "", "Commands:"},
+		{"help", []string{"help"}, exitOK, "", "Commands:"},
+		{"unknown command", []string{"limits"}, exitUsage, "", "unknown command"},
+		{"unknown flag", []string{"usage", "-regions", "us-east-1"}, exitUsage, "", "-regions"},
+		{"missing service", []string{"quotas"}, exitUsage, "", "service code is expected"},
+		{"forecast arguments", []string{"forecast", "ec2"}, exitUsage, "", "unexpected arguments"},
+		{"suppressed arguments", []string{"suppressed", "vpc"}, exitUsage, "", "unexpected arguments"},
+		{"invalid alarm", []string{"check", "-alarm", "warning"}, exitUsage, "", "name=threshold"},
+		{"invalid severity", []string{"check", "-alarm", "warning=80:fatal"}, exitError, "", "unknown severity"},
+		{"info severity", []string{"iam", "-alarm", "capacity=50:info", "-select", "vpc"}, exitOK, "ec2:DescribeVpcs", ""},
+		{"invalid selector", []string{"iam", "-select", "ec2~("}, exitError, "", "selector"},
+		{"iam", []string{"iam", "-select", "vpc"}, exitOK, "ec2:DescribeVpcs", ""},
+		{"iam markdown", []string{"iam", "-select", "vpc", "-format", "markdown"}, exitOK, "| sts | sts:GetCallerIdentity |", ""},
+		{"iam csv sorted", []string{"iam", "-select", "vpc", "-format", "csv", "-sort", "-service"}, exitOK, "service,action\nvpc,", ""},
+		{"unknown format", []string{"iam", "-format", "xml"}, exitUsage, "", "unknown format"},
+		{"unknown sort field", []string{"iam", "-sort", "percent"}, exitUsage, "", "unknown field"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			code := run(context.Background(), tt.args, stdout, stderr)
+			if code != tt.wantCode {
+				t.Errorf("code = %v, want %v, stderr: %v", code, tt.wantCode, stderr)
+			}
+			if !strings.Contains(stdout.String(), tt.wantStdout) {
+				t.Errorf("stdout = %q, want %q", stdout, tt.wantStdout)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("stderr = %q, want %q", stderr, tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestIamPolicy(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	if code := run(context.Background(), []string{"iam", "-select", "elasticfilesystem", "-policy"}, stdout, &bytes.Buffer{}); code != exitOK {
+		t.Fatalf("code = %v, want %v", code, exitOK)
+	}
+
+	policy := struct {
+		Statement []struct {
+			Action []string
+		}
+	}{}
+	if err := json.Unmarshal(stdout.Bytes(), &policy); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	actions := policy.Statement[0].Action
+	for _, want := range []string{"elasticfilesystem:DescribeFileSystems", "servicequotas:ListServiceQuotas", "sts:GetCallerIdentity"} {
+		found := false
+		for _, a := range actions {
+			found = found || a == want
+		}
+		if !found {
+			t.Errorf("actions = %v, want %v", actions, want)
+		}
+	}
+	for _, a := range actions {
+		if strings.HasPrefix(a, "ec2:") {
+			t.Errorf("actions = %v, want no actions of not selected services", actions)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	o := newOptions(&bytes.Buffer{}, &bytes.Buffer{})
+	fs := o.flagSet(commands["check"])
+	err := fs.Parse([]string{
+		"-region", "us-east-1,eu-west-1", "-region", "us-east-2",
+		"-profile", "prod",
+		"-select", "ec2", "-select", "!ec2:L-1216C47A",
+		"-alarm", "warning=80", "-alarm", "critical=95:critical", "-alarm", "warning=60@ec2:L-0263D0A3",
+		"-catalog-ttl", "1h",
+		"-state", "alerts.json",
+		"-history", "history", "-backfill", "168h",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c, err := o.loadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &config.Config{
+		Accounts: []config.Account{{Name: "prod", Profile: "prod"}},
+		Regions:  []string{"us-east-1", "eu-west-1", "us-east-2"},
+		Selector: &config.Selection{Terms: []string{"ec2", "!ec2:L-1216C47A"}},
+		Alarms: []config.Alarm{
+			{Name: "warning", Threshold: 80},
+			{Name: "critical", Threshold: 95, Severity: "critical"},
+			{Name: "warning", Threshold: 60, Scope: &selector.Rule{Service: "ec2", Quota: "L-0263D0A3"}},
+		},
+		Options: config.Options{
+			CatalogCache: &config.CatalogCache{TTL: config.Duration(time.Hour)},
+			History:      &config.History{Dir: "history", Backfill: config.Duration(168 * time.Hour)},
+		},
+		AlertState: "alerts.json",
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("config = %+v, want %+v", c, want)
+	}
+	if targets := c.Targets(); len(targets) != 3 || targetName(targets[0]) != "prod us-east-1" {
+		t.Errorf("targets = %+v", targets)
+	}
+}
+
+func TestParseAlarm(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    config.Alarm
+		wantErr bool
+	}{
+		{"warning=80", config.Alarm{Name: "warning", Threshold: 80}, false},
+		{"vpcs=<2:critical@vpc", config.Alarm{Name: "vpcs", Remaining: 2, Severity: "critical", Scope: &selector.Rule{Service: "vpc"}}, false},
+		{"eni=90|<100", config.Alarm{Name: "eni", Threshold: 90, Remaining: 100}, false},
+		{"eni=90&<100", config.Alarm{Name: "eni", Threshold: 90, Remaining: 100, Combine: "all"}, false},
+		{"soon=in14d:critical", config.Alarm{Name: "soon", Exhaustion: config.Duration(14 * 24 * time.Hour), Severity: "critical"}, false},
+		{"soon=90|in36h", config.Alarm{Name: "soon", Threshold: 90, Exhaustion: config.Duration(36 * time.Hour)}, false},
+		{"soon=inx", config.Alarm{}, true},
+		{"warning", config.Alarm{}, true},
+		{"warning=", config.Alarm{}, true},
+		{"warning=<x", config.Alarm{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			a, err := parseAlarm(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(a, tt.want) {
+				t.Errorf("alarm = %+v, want %+v", a, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteFiles(t *testing.T) {
+	dir := t.TempDir()
+	c := &config.Config{Outputs: []config.Output{
+		{Type: "stdout", Format: "csv"},
+		{Type: "file", Format: "json", Sort: []string{"-percent"}, Path: filepath.Join(dir, "out", "usage.json")},
+		{Type: "file", Format: "csv", Path: filepath.Join(dir, "usage.csv")},
+	}}
+
+	table := render.NewTable(render.Field{Name: "quota", Title: "QUOTA"}, render.Field{Name: "percent", Title: "PERCENT"})
+	table.Append(render.Cell{Value: "L-1", Text: "L-1"}, render.Cell{Value: 10.0, Text: "10%"})
+	table.Append(render.Cell{Value: "L-2", Text: "L-2"}, render.Cell{Value: 80.0, Text: "80%"})
+
+	o := &options{}
+	if err := o.writeFiles(c, table); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "out", "usage.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rows := make([]map[string]interface{}, 0, 0)
+	if err := json.Unmarshal(data, &rows); err != nil || len(rows) != 2 || rows[0]["quota"] != "L-2" {
+		t.Errorf("json output = %s, error = %v, want rows sorted by percent", data, err)
+	}
+
+	data, err = ioutil.ReadFile(filepath.Join(dir, "usage.csv"))
+	if err != nil || !strings.HasPrefix(string(data), "quota,percent\n") {
+		t.Errorf("csv output = %q, error = %v", data, err)
+	}
+
+	c.Outputs = []config.Output{{Type: "file", Sort: []string{"usage"}, Path: filepath.Join(dir, "usage.txt")}}
+	if err := o.writeFiles(c, table); !errors.Is(err, errUsage) {
+		t.Errorf("error = %v, want usage error for unknown sort field", err)
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/config"
+	"github.com/vslchnk/aws_quotas_checker/render"
+	"github.com/vslchnk/aws_quotas_checker/runner"
+	"github.com/vslchnk/aws_quotas_checker/selector"
+	"github.com/vslchnk/aws_quotas_checker/utils"
+)
+
+// stringList is a flag which can be repeated, every value can contain several comma-separated items
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+
+	return nil
+}
+
+// targetError is error of the failed target
+type targetError struct {
+	target config.Target
+	err    error
+}
+
+// options are flags which are common for all subcommands
+type options struct {
+	configPath     string
+	regions        stringList
+	profile        string
+	terms          stringList
+	alarms         stringList
+	timeout        time.Duration
+	catalogTTL     time.Duration
+	refreshCatalog bool
+	format         string
+	sort           stringList
+	policy         bool
+	junitPath      string
+	statePath      string
+	historyDir     string
+	backfill       time.Duration
+	failures       []targetError
+	stdout         io.Writer
+	stderr         io.Writer
+}
+
+// creates options which write to the writers
+func newOptions(stdout io.Writer, stderr io.Writer) *options {
+	o := options{}
+	o.stdout = stdout
+	o.stderr = stderr
+
+	return &o
+}
+
+// returns set of flags of the subcommand
+func (o *options) flagSet(cmd command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(o.stderr)
+
+	fs.StringVar(&o.configPath, "config", "", "path of YAML or JSON configuration file")
+	fs.Var(&o.regions, "region", "region to check, can be repeated or comma-separated (overrides config)")
+	fs.StringVar(&o.profile, "profile", "", "profile from shared config and credentials files (overrides accounts of config)")
+	fs.Var(&o.terms, "select", "selector term, e.g. ec2:L-1216C47A, !s3, ec2~(?i)on-demand, adjustable=true (overrides selector of config)")
+	fs.Var(&o.alarms, "alarm", "alarm as name=threshold[:severity][@scope], threshold is percents \"80\", remaining capacity \"<5\", exhaustion within \"in14d\" or several of them combined with | (any) or & (all), e.g. \"80|<5\", severity is info, warning or critical, scope is service[:quota][~name], can be repeated (added to alarms of config)")
+	fs.DurationVar(&o.timeout, "timeout", 0, "limit of the whole command, zero means no limit")
+	fs.DurationVar(&o.catalogTTL, "catalog-ttl", 0, "keep catalog of services and quotas on disk for this time")
+	fs.BoolVar(&o.refreshCatalog, "refresh-catalog", false, "request catalog of services and quotas again and replace cached one")
+	fs.StringVar(&o.format, "format", "", fmt.Sprintf("format of results: %v (overrides stdout output of config, default table)", strings.Join(render.Formats(), ", ")))
+	fs.StringVar(&o.junitPath, "junit", "", "write JUnit XML report with one test case per quota to the file (check only)")
+	fs.StringVar(&o.historyDir, "history", "", "keep usage history of quotas in the directory, forecasts are made from it (overrides config)")
+	fs.DurationVar(&o.backfill, "backfill", 0, "add usage from cloudwatch metrics over this time to usage history, e.g. 336h")
+	fs.StringVar(&o.statePath, "state", "", "keep state of alerts in the file and show only fired, resolved and changed alerts (check only, overrides config)")
+	fs.BoolVar(&o.policy, "policy", false, "print IAM policy document instead of list of actions (iam only)")
+	fs.Var(&o.sort, "sort", "fields to sort results by, \"-\" prefix means descending order, e.g. -percent,serviceCode (overrides stdout output of config)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(o.stderr, "Usage: quotactl %v [flags] %v\n\n%v\n\nFlags:\n", cmd.name, cmd.args, cmd.description)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// returns configuration from the file with values of flags applied
+func (o *options) loadConfig() (*config.Config, error) {
+	c := &config.Config{}
+	if o.configPath != "" {
+		var err error
+		c, err = config.Load(o.configPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(o.regions) > 0 {
+		c.Regions = o.regions
+	}
+	if o.profile != "" {
+		c.Accounts = []config.Account{{Name: o.profile, Profile: o.profile}}
+	}
+	if len(o.terms) > 0 {
+		c.Selector = &config.Selection{Terms: o.terms}
+	}
+	for _, a := range o.alarms {
+		ca, err := parseAlarm(a)
+		if err != nil {
+			return nil, err
+		}
+		c.Alarms = append(c.Alarms, ca)
+	}
+	if o.historyDir != "" || o.backfill > 0 {
+		if c.Options.History == nil {
+			c.Options.History = &config.History{}
+		}
+		if o.historyDir != "" {
+			c.Options.History.Dir = o.historyDir
+		}
+		if o.backfill > 0 {
+			c.Options.History.Backfill = config.Duration(o.backfill)
+		}
+	}
+	if o.statePath != "" {
+		c.AlertState = o.statePath
+	}
+	if o.catalogTTL > 0 || o.refreshCatalog {
+		if c.Options.CatalogCache == nil {
+			c.Options.CatalogCache = &config.CatalogCache{}
+		}
+		if o.catalogTTL > 0 {
+			c.Options.CatalogCache.TTL = config.Duration(o.catalogTTL)
+		}
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// returns alarm parsed from "name=conditions[:severity][@scope]", conditions are percentage threshold "80",
+// remaining threshold "<5", exhaustion "in14d" or several of them combined with "|" for any or "&" for all
+func parseAlarm(value string) (config.Alarm, error) {
+	a := config.Alarm{}
+	invalid := fmt.Errorf("%w: alarm %q is not name=threshold[:severity][@scope]", errUsage, value)
+
+	alarm, scope, scoped := strings.Cut(value, "@")
+	name, conditions, ok := strings.Cut(alarm, "=")
+	conditions, a.Severity, _ = strings.Cut(conditions, ":")
+	if !ok || conditions == "" {
+		return a, invalid
+	}
+	a.Name = name
+
+	separator := "|"
+	if strings.Contains(conditions, "&") {
+		separator = "&"
+		a.Combine = "all"
+	}
+	for _, condition := range strings.Split(conditions, separator) {
+		var err error
+		switch {
+		case strings.HasPrefix(condition, "<"):
+			a.Remaining, err = strconv.ParseFloat(condition[1:], 64)
+		case strings.HasPrefix(condition, "in"):
+			var d time.Duration
+			d, err = parseDays(condition[2:])
+			a.Exhaustion = config.Duration(d)
+		default:
+			a.Threshold, err = strconv.Atoi(condition)
+		}
+		if err != nil {
+			return a, invalid
+		}
+	}
+
+	if scoped {
+		rule := selector.ParseRule(scope)
+		a.Scope = &rule
+	}
+
+	return a, nil
+}
+
+// returns duration parsed as days with "d" suffix, e.g. "14d", or as Go duration, e.g. "36h"
+func parseDays(value string) (time.Duration, error) {
+	if days := strings.TrimSuffix(value, "d"); days != value {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+
+	return time.ParseDuration(value)
+}
+
+// returns context limited by the timeout flag
+func (o *options) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.timeout > 0 {
+		return context.WithTimeout(ctx, o.timeout)
+	}
+
+	return context.WithCancel(ctx)
+}
+
+// creates runner agent for every target of the configuration and calls fn for it, tables of all targets are
+// rendered together to stdout and file outputs of the configuration, account and region fields are added
+// if there are several targets, failed targets are reported and kept in failures and the rest of them are still processed
+func (o *options) forEachRunner(ctx context.Context, lazy bool, fn func(c *config.Config, t config.Target, r *runner.Runner) (*render.Table, error)) error {
+	c, err := o.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	rend, sort, err := o.output(c)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := o.withTimeout(ctx)
+	defer cancel()
+
+	opts := make([]runner.Option, 0, 2)
+	if lazy {
+		opts = append(opts, runner.WithLazyLoading())
+	}
+	if o.refreshCatalog {
+		opts = append(opts, runner.WithCatalogRefresh())
+	}
+
+	targets := c.Targets()
+	failed := 0
+	var result *render.Table
+
+	for _, t := range targets {
+		r, err := c.NewRunner(ctx, t, opts...)
+		var table *render.Table
+		if err == nil {
+			table, err = fn(c, t, r)
+		}
+		if errors.Is(err, errUsage) {
+			return err
+		}
+		if err != nil {
+			failed++
+			o.failures = append(o.failures, targetError{t, err})
+			fmt.Fprintf(o.stderr, "Error: %v: %v\n", targetName(t), err)
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+
+		if len(targets) > 1 {
+			table.Prepend(render.Field{Name: "region", Title: "REGION"}, render.Cell{Value: t.Region, Text: t.Region})
+			account := ""
+			if t.Account != nil {
+				account = t.Account.Name
+			}
+			table.Prepend(render.Field{Name: "account", Title: "ACCOUNT"}, render.Cell{Value: account, Text: account})
+		}
+
+		if result == nil {
+			result = table
+		} else if err := result.Merge(table); err != nil {
+			return err
+		}
+	}
+
+	if result != nil {
+		if err := o.write(rend, sort, result); err != nil {
+			return err
+		}
+		if err := o.writeFiles(c, result); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%v of %v %w", failed, len(targets), errTargets)
+	}
+
+	return nil
+}
+
+// returns renderer and sort keys from flags or stdout output of the configuration
+func (o *options) output(c *config.Config) (render.Renderer, []string, error) {
+	format, sort := o.format, []string(o.sort)
+	for _, out := range c.Outputs {
+		if out.Type != "stdout" {
+			continue
+		}
+		if format == "" {
+			format = out.Format
+		}
+		if len(sort) == 0 {
+			sort = out.Sort
+		}
+		break
+	}
+	if format == "" || format == "text" {
+		format = render.FormatTable
+	}
+
+	r, err := render.New(format)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	return r, sort, nil
+}
+
+// sorts the table and writes it to stdout
+func (o *options) write(r render.Renderer, sort []string, t *render.Table) error {
+	if err := t.Sort(sort...); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	return r.Render(o.stdout, t)
+}
+
+// writes the table to file outputs of the configuration, every file has its own format and sort keys,
+// files are replaced atomically
+func (o *options) writeFiles(c *config.Config, t *render.Table) error {
+	for _, out := range c.Outputs {
+		if out.Type != "file" {
+			continue
+		}
+
+		format := out.Format
+		if format == "" || format == "text" {
+			format = render.FormatTable
+		}
+		r, err := render.New(format)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errUsage, err)
+		}
+		if err := t.Sort(out.Sort...); err != nil {
+			return fmt.Errorf("%w: %v", errUsage, err)
+		}
+
+		data := bytes.Buffer{}
+		if err := r.Render(&data, t); err != nil {
+			return fmt.Errorf("Error while rendering output %v: %w", out.Path, err)
+		}
+		if err := utils.WriteFileAtomic(out.Path, data.Bytes()); err != nil {
+			return fmt.Errorf("Error while writing output %v: %w", out.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// returns name of the target for errors
+func targetName(t config.Target) string {
+	region := t.Region
+	if region == "" {
+		region = "default region"
+	}
+
+	if t.Account == nil {
+		return region
+	}
+
+	return t.Account.Name + " " + region
+}
@@ -0,0 +1,283 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/selector"
+
+	"gopkg.in/yaml.v2"
+)
+
+// formats of configuration files
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
+// Config describes accounts and regions which quotas are checked in, selection of quotas, alarms and outputs
+type Config struct {
+	// accounts which quotas are checked in, default credentials are used if it is empty
+	Accounts []Account `json:"accounts,omitempty" yaml:"accounts,omitempty"`
+	// regions which quotas are checked in, default region is used if it is empty
+	Regions []string `json:"regions,omitempty" yaml:"regions,omitempty"`
+	// selection of services and quotas, all quotas are selected if it is not set
+	Selector *Selection `json:"selector,omitempty" yaml:"selector,omitempty"`
+	// alarms which are checked after every update of usage
+	Alarms []Alarm `json:"alarms,omitempty" yaml:"alarms,omitempty"`
+	// outputs which results are written to
+	Outputs []Output `json:"outputs,omitempty" yaml:"outputs,omitempty"`
+	// options of runner agents
+	Options Options `json:"options,omitempty" yaml:"options,omitempty"`
+	// path of the file which keeps state of alerts between checks, alerts are not tracked if it is empty
+	AlertState string `json:"alertState,omitempty" yaml:"alertState,omitempty"`
+	// silences, maintenance windows and acknowledgements which suppress alarms
+	Silences []Silence `json:"silences,omitempty" yaml:"silences,omitempty"`
+}
+
+// Account describes credentials of the account
+type Account struct {
+	// unique name of the account in the configuration
+	Name string `json:"name" yaml:"name"`
+	// ID of the account, it is requested from STS if it is empty
+	ID string `json:"id,omitempty" yaml:"id,omitempty"`
+	// name of the profile from shared config and credentials files
+	Profile string `json:"profile,omitempty" yaml:"profile,omitempty"`
+	// role which is assumed with credentials of the profile
+	RoleARN    string `json:"roleArn,omitempty" yaml:"roleArn,omitempty"`
+	ExternalID string `json:"externalId,omitempty" yaml:"externalId,omitempty"`
+}
+
+// Selection selects quotas with selector terms and rules, quotas are selected if they match both
+type Selection struct {
+	// terms of the selector, e.g. "ec2:L-1216C47A" or "!s3"
+	Terms             []string `json:"terms,omitempty" yaml:"terms,omitempty"`
+	selector.Selector `yaml:",inline"`
+}
+
+// Alarm is raised when usage of the quota reaches threshold percents of its value, alarms with the same name
+// and different scopes are rules of one alarm and the most specific matching one is applied to every quota
+type Alarm struct {
+	Name string `json:"name" yaml:"name"`
+	// percents of the quota value
+	Threshold int `json:"threshold,omitempty" yaml:"threshold,omitempty"`
+	// alarm is raised when fewer than remaining units of the quota value are left, zero threshold,
+	// remaining and exhaustion without expression disable the alarm for quotas of the scope
+	Remaining float64 `json:"remaining,omitempty" yaml:"remaining,omitempty"`
+	// alarm is raised when usage is forecast to reach the quota value within this time, e.g. "336h"
+	Exhaustion Duration `json:"exhaustion,omitempty" yaml:"exhaustion,omitempty"`
+	// forecasts with lower confidence from 0 to 1 are ignored
+	MinConfidence float64 `json:"minConfidence,omitempty" yaml:"minConfidence,omitempty"`
+	// alarm is raised when the boolean expression over usage, value and other fields of the quota is true,
+	// e.g. "usage / limit > 0.8 and not adjustable", it is checked when the file is loaded
+	Expression string `json:"expression,omitempty" yaml:"expression,omitempty"`
+	// any or all, defines if any or all of threshold, remaining, exhaustion and expression conditions must be met,
+	// any by default
+	Combine string `json:"combine,omitempty" yaml:"combine,omitempty"`
+	// info, warning or critical, warning by default
+	Severity string `json:"severity,omitempty" yaml:"severity,omitempty"`
+	// alert of the alarm is fired when conditions are met for this time, at once if it is not set
+	For Duration `json:"for,omitempty" yaml:"for,omitempty"`
+	// firing alert is resolved when usage is below clear threshold percents and more than clear remaining units
+	// are left, threshold and remaining are used if they are not set
+	ClearThreshold int     `json:"clearThreshold,omitempty" yaml:"clearThreshold,omitempty"`
+	ClearRemaining float64 `json:"clearRemaining,omitempty" yaml:"clearRemaining,omitempty"`
+	// quotas which the alarm is applied to, all quotas if it is not set
+	Scope *selector.Rule `json:"scope,omitempty" yaml:"scope,omitempty"`
+	// tag of applied quotas as "key" or "key=value"
+	Tag string `json:"tag,omitempty" yaml:"tag,omitempty"`
+}
+
+// Silence suppresses alarms of matching quotas while it is active, suppressed alarms are reported separately
+// and don't define exit code of checks
+type Silence struct {
+	// unique name of the silence
+	Name string `json:"name" yaml:"name"`
+	// silence, maintenance or acknowledgement, maintenance if window is set and silence otherwise
+	Kind string `json:"kind,omitempty" yaml:"kind,omitempty"`
+	// quotas which alarms are suppressed, all quotas if it is not set
+	Scope *selector.Rule `json:"scope,omitempty" yaml:"scope,omitempty"`
+	// glob of names of suppressed alarms, all alarms if it is not set
+	Alarm string `json:"alarm,omitempty" yaml:"alarm,omitempty"`
+	// RFC 3339 times, silence is active from start until end, it is active already and never expires if they are not set
+	Start *time.Time `json:"start,omitempty" yaml:"start,omitempty"`
+	End   *time.Time `json:"end,omitempty" yaml:"end,omitempty"`
+	// recurring maintenance window, silence is active only within it
+	Window *Window `json:"window,omitempty" yaml:"window,omitempty"`
+	// who created the silence and why, owner is required for acknowledgements
+	Owner   string `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Comment string `json:"comment,omitempty" yaml:"comment,omitempty"`
+	// info, warning or critical, more severe alarms are not suppressed, alarms of any severity if it is not set
+	Severity string `json:"severity,omitempty" yaml:"severity,omitempty"`
+}
+
+// Window is recurring maintenance window, e.g. every Saturday from 22:00 for 4 hours
+type Window struct {
+	// days of the week when window starts, e.g. [sat, sun], every day if it is not set
+	Days []string `json:"days,omitempty" yaml:"days,omitempty"`
+	// time of the day when window starts as "15:04"
+	Start    string   `json:"start" yaml:"start"`
+	Duration Duration `json:"duration" yaml:"duration"`
+	// IANA time zone of the start time, e.g. "Europe/Berlin", UTC if it is not set
+	Timezone string `json:"timezone,omitempty" yaml:"timezone,omitempty"`
+}
+
+// Output describes where and in which format results are written
+type Output struct {
+	// stdout or file
+	Type string `json:"type" yaml:"type"`
+	// text, table, json, ndjson, csv or markdown
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+	// names of fields to sort rows by, "-" prefix means descending order, e.g. ["-percent", "serviceCode"]
+	Sort []string `json:"sort,omitempty" yaml:"sort,omitempty"`
+	// path of the file for file outputs
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+}
+
+// Options configures runner agents
+type Options struct {
+	Workers  int      `json:"workers,omitempty" yaml:"workers,omitempty"`
+	Timeouts Timeouts `json:"timeouts,omitempty" yaml:"timeouts,omitempty"`
+	// limits by API namespace (ec2, cloudwatch, servicequotas, ...)
+	RateLimits map[string]RateLimit `json:"rateLimits,omitempty" yaml:"rateLimits,omitempty"`
+	// endpoint URLs by endpoint ID of service (ec2, monitoring, servicequotas, ...)
+	Endpoints            map[string]string `json:"endpoints,omitempty" yaml:"endpoints,omitempty"`
+	Lazy                 bool              `json:"lazy,omitempty" yaml:"lazy,omitempty"`
+	CatalogCache         *CatalogCache     `json:"catalogCache,omitempty" yaml:"catalogCache,omitempty"`
+	Precedence           string            `json:"precedence,omitempty" yaml:"precedence,omitempty"`
+	DiscrepancyTolerance float64           `json:"discrepancyTolerance,omitempty" yaml:"discrepancyTolerance,omitempty"`
+	RateLookback         Duration          `json:"rateLookback,omitempty" yaml:"rateLookback,omitempty"`
+	History              *History          `json:"history,omitempty" yaml:"history,omitempty"`
+}
+
+// Timeouts limits time of collecting information, zero values mean no limits
+type Timeouts struct {
+	Overall Duration `json:"overall,omitempty" yaml:"overall,omitempty"`
+	Call    Duration `json:"call,omitempty" yaml:"call,omitempty"`
+}
+
+// RateLimit limits rate of requests per second with bursts
+type RateLimit struct {
+	Rate  float64 `json:"rate" yaml:"rate"`
+	Burst int     `json:"burst" yaml:"burst"`
+}
+
+// CatalogCache configures on-disk cache of services and their default quotas
+type CatalogCache struct {
+	// directory of cache files, default one is in the user cache directory
+	Dir string `json:"dir,omitempty" yaml:"dir,omitempty"`
+	// entries older than TTL are requested again, zero means that entries never expire
+	TTL Duration `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+}
+
+// History configures on-disk usage history of quotas which forecasts are made from
+type History struct {
+	// directory of history files, default one is in the user cache directory
+	Dir string `json:"dir,omitempty" yaml:"dir,omitempty"`
+	// samples older than retention are dropped, 30 days by default
+	Retention Duration `json:"retention,omitempty" yaml:"retention,omitempty"`
+	// usage from cloudwatch metrics over this time is added to the history when runner agent is created
+	Backfill Duration `json:"backfill,omitempty" yaml:"backfill,omitempty"`
+}
+
+// Duration is time.Duration which is written as a string in configuration files, e.g. "90s" or "1h"
+type Duration time.Duration
+
+// returns Duration parsed from the string
+func parseDuration(s string) (Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("Error while parsing duration: %w", err)
+	}
+
+	return Duration(d), nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("Error while parsing duration: %w", err)
+	}
+
+	var err error
+	*d, err = parseDuration(s)
+
+	return err
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return fmt.Errorf("Error while parsing duration: %w", err)
+	}
+
+	var err error
+	*d, err = parseDuration(s)
+
+	return err
+}
+
+// returns Config loaded from the file, format is defined by the extension, YAML is used for unknown extensions
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error while reading config file: %w", err)
+	}
+
+	format := FormatYAML
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		format = FormatJSON
+	}
+
+	c, err := Parse(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("Error while loading config file %v: %w", path, err)
+	}
+
+	return c, nil
+}
+
+// returns Config parsed from data in the format, references to environment variables are replaced before parsing,
+// unknown fields and invalid values are reported as errors
+func Parse(data []byte, format string) (*Config, error) {
+	data, err := Interpolate(data)
+	if err != nil {
+		return nil, err
+	}
+
+	c := Config{}
+
+	switch format {
+	case FormatYAML:
+		if err := yaml.UnmarshalStrict(data, &c); err != nil {
+			return nil, fmt.Errorf("Error while decoding YAML config: %w", err)
+		}
+	case FormatJSON:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&c); err != nil {
+			return nil, fmt.Errorf("Error while decoding JSON config: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("Error while decoding config: unknown format %v", format)
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
@@ -0,0 +1,265 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/runner"
+	"github.com/vslchnk/aws_quotas_checker/selector"
+)
+
+const yamlConfig = `
+accounts:
+  - name: prod
+    profile: prod
+    roleArn: arn:aws:iam::123456789012:role/quotas
+    externalId: ${QUOTAS_EXTERNAL_ID}
+regions: [us-east-1, eu-west-1]
+selector:
+  terms: ["ec2:L-1216C47A", "!s3"]
+  include:
+    - service: vpc
+  adjustable: true
+alarms:
+  - name: warning
+    threshold: 80
+    clearThreshold: 70
+    for: 10m
+  - name: warning
+    threshold: 60
+    remaining: 2
+    combine: all
+    scope: {service: ec2, quota: L-0263D0A3}
+  - name: warning
+    threshold: 0
+    tag: checker=ignore
+  - name: soon
+    exhaustion: 336h
+    minConfidence: 0.8
+  - name: fixed
+    expression: usage / limit > 0.8 and not adjustable
+    severity: critical
+outputs:
+  - type: file
+    format: json
+    path: ${QUOTAS_OUTPUT_DIR:-/tmp}/quotas.json
+options:
+  workers: 4
+  timeouts:
+    overall: 5m
+    call: 30s
+  rateLimits:
+    ec2: {rate: 5, burst: 10}
+  precedence: max
+  rateLookback: 2h
+  history:
+    retention: 720h
+    backfill: 168h
+alertState: /tmp/alerts.json
+silences:
+  - name: vpc-migration
+    scope: {service: vpc}
+    start: 2024-06-01T20:00:00Z
+    end: "2024-06-02T04:00:00Z"
+    owner: network
+    comment: VPC migration
+  - name: weekend
+    alarm: warning
+    window: {days: [sat, Sunday], start: "22:30", duration: 4h, timezone: UTC}
+  - name: eni
+    kind: acknowledgement
+    scope: {service: ec2, quota: L-DF5E4CA3}
+    owner: alice
+    severity: warning
+`
+
+const jsonConfig = `{
+  "regions": ["us-east-2"],
+  "selector": {"include": [{"service": "ec2", "quota": "L-12*"}], "withUsage": true},
+  "alarms": [{"name": "critical", "threshold": 95, "severity": "critical"}],
+  "options": {"catalogCache": {"ttl": "24h"}}
+}`
+
+func TestParse(t *testing.T) {
+	os.Setenv("QUOTAS_EXTERNAL_ID", "secret")
+	defer os.Unsetenv("QUOTAS_EXTERNAL_ID")
+
+	c, err := Parse([]byte(yamlConfig), FormatYAML)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if c.Accounts[0].ExternalID != "secret" || c.Outputs[0].Path != "/tmp/quotas.json" {
+		t.Errorf("interpolated values = %v, %v", c.Accounts[0].ExternalID, c.Outputs[0].Path)
+	}
+	if time.Duration(c.Options.Timeouts.Overall) != 5*time.Minute || time.Duration(c.Options.RateLookback) != 2*time.Hour {
+		t.Errorf("durations = %v, %v", c.Options.Timeouts.Overall, c.Options.RateLookback)
+	}
+	if c.Options.RateLimits["ec2"] != (RateLimit{Rate: 5, Burst: 10}) {
+		t.Errorf("rate limits = %v", c.Options.RateLimits)
+	}
+
+	sel, err := c.GetSelector()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	yes := true
+	want := &selector.Selector{
+		Include:    []selector.Rule{{Service: "ec2", Quota: "L-1216C47A"}, {Service: "vpc"}},
+		Exclude:    []selector.Rule{{Service: "s3"}},
+		Adjustable: &yes,
+	}
+	if !reflect.DeepEqual(sel, want) {
+		t.Errorf("selector = %+v, want %+v", sel, want)
+	}
+
+	if rule := c.Alarms[0].rule(); rule.For != 10*time.Minute || rule.ClearThreshold != 70 || c.AlertState != "/tmp/alerts.json" {
+		t.Errorf("alarm rule = %+v, alert state = %v", rule, c.AlertState)
+	}
+
+	if rule := c.Alarms[3].rule(); rule.Exhaustion != 14*24*time.Hour || rule.MinConfidence != 0.8 {
+		t.Errorf("alarm rule = %+v", rule)
+	}
+	if rule := c.Alarms[4].rule(); rule.Expression != "usage / limit > 0.8 and not adjustable" || rule.Severity != runner.SeverityCritical {
+		t.Errorf("alarm rule = %+v", rule)
+	}
+	if h := c.Options.History; h == nil || time.Duration(h.Retention) != 720*time.Hour || time.Duration(h.Backfill) != 168*time.Hour {
+		t.Errorf("history = %+v", h)
+	}
+
+	silence, err := c.Silences[0].silence()
+	if err != nil || silence.Scope.Service != "vpc" || !silence.End.Equal(time.Date(2024, 6, 2, 4, 0, 0, 0, time.UTC)) || !silence.End.After(silence.Start) || silence.Owner != "network" {
+		t.Errorf("silence = %+v, error = %v", silence, err)
+	}
+	silence, err = c.Silences[1].silence()
+	if err != nil || silence.Window == nil || silence.Window.Start != 22*time.Hour+30*time.Minute || !reflect.DeepEqual(silence.Window.Weekdays, []time.Weekday{time.Saturday, time.Sunday}) {
+		t.Errorf("silence = %+v, error = %v", silence, err)
+	}
+	if silence, _ = c.Silences[2].silence(); silence.Kind != runner.KindAcknowledgement || silence.Severity != runner.SeverityWarning {
+		t.Errorf("silence = %+v", silence)
+	}
+
+	rule := c.Alarms[1].rule()
+	if rule.Severity != runner.SeverityWarning || rule.Remaining != 2 || rule.Combine != runner.CombineAll || rule.Scope != (selector.Rule{Service: "ec2", Quota: "L-0263D0A3"}) || c.Alarms[2].Tag != "checker=ignore" {
+		t.Errorf("alarm rule = %+v", rule)
+	}
+
+	targets := c.Targets()
+	if len(targets) != 2 || targets[0].Account.Name != "prod" || targets[0].Region != "us-east-1" || targets[1].Region != "eu-west-1" {
+		t.Errorf("targets = %+v", targets)
+	}
+	if opts := c.RunnerOptions(targets[0]); len(opts) != 9 {
+		t.Errorf("options = %v, want 9 options", len(opts))
+	}
+
+	c, err = Parse([]byte(jsonConfig), FormatJSON)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sel, _ = c.GetSelector()
+	if len(sel.Include) != 1 || !sel.WithUsage || time.Duration(c.Options.CatalogCache.TTL) != 24*time.Hour {
+		t.Errorf("config = %+v, selector = %+v", c, sel)
+	}
+	if targets := c.Targets(); len(targets) != 1 || targets[0].Account != nil || targets[0].Region != "us-east-2" {
+		t.Errorf("targets = %+v", targets)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format string
+		want   string
+	}{
+		{"unknown YAML field", "regoins: [us-east-1]", FormatYAML, "regoins"},
+		{"unknown JSON field", `{"alarm": []}`, FormatJSON, "alarm"},
+		{"missing variable", "regions: [${QUOTAS_MISSING_REGION}]", FormatYAML, "QUOTAS_MISSING_REGION"},
+		{"invalid region", "regions: [us-east]", FormatYAML, "invalid region"},
+		{"invalid duration", "options: {rateLookback: 2 hours}", FormatYAML, "duration"},
+		{"invalid selector", "selector: {terms: ['ec2~(']}", FormatYAML, "selector"},
+		{"duplicated alarm", "alarms: [{name: a, threshold: 1}, {name: a, threshold: 2}]", FormatYAML, "duplicated name"},
+		{"duplicated alarm scope", "alarms: [{name: a, threshold: 1, scope: {service: ec2}}, {name: a, threshold: 2, scope: {service: ec2}}]", FormatYAML, "duplicated name"},
+		{"zero threshold without scope", "alarms: [{name: a, threshold: 0}]", FormatYAML, "must be set"},
+		{"negative remaining", "alarms: [{name: a, remaining: -1}]", FormatYAML, "negative"},
+		{"unknown combination", "alarms: [{name: a, threshold: 1, remaining: 2, combine: both}]", FormatYAML, "unknown combination"},
+		{"invalid alarm scope", "alarms: [{name: a, threshold: 1, scope: {name: '('}}]", FormatYAML, "scope"},
+		{"unknown severity", "alarms: [{name: a, threshold: 1, severity: fatal}]", FormatYAML, "unknown severity"},
+		{"clear threshold above threshold", "alarms: [{name: a, threshold: 80, clearThreshold: 90}]", FormatYAML, "clear threshold"},
+		{"clear remaining without remaining", "alarms: [{name: a, threshold: 80, clearRemaining: 5}]", FormatYAML, "clear remaining"},
+		{"invalid minimum confidence", "alarms: [{name: a, exhaustion: 24h, minConfidence: 2}]", FormatYAML, "minimum confidence"},
+		{"invalid expression", "alarms: [{name: a, expression: 'usage > 80 and adjustible'}]", FormatYAML, "at position 16: unknown variable adjustible"},
+		{"expression which is not bool", "alarms: [{name: a, expression: 'usage / limit'}]", FormatYAML, "result is number"},
+		{"negative backfill", "options: {history: {backfill: -1h}}", FormatYAML, "backfill"},
+		{"negative for-duration", "alarms: [{name: a, threshold: 80, for: -1m}]", FormatYAML, "for-duration"},
+		{"duplicated silence", "silences: [{name: a}, {name: a}]", FormatYAML, "duplicated name"},
+		{"unknown silence kind", "silences: [{name: a, kind: mute}]", FormatYAML, "unknown kind"},
+		{"acknowledgement without owner", "silences: [{name: a, kind: acknowledgement}]", FormatYAML, "owner"},
+		{"maintenance without window", "silences: [{name: a, kind: maintenance}]", FormatYAML, "window is required"},
+		{"silence end before start", "silences: [{name: a, start: 2024-06-02T00:00:00Z, end: 2024-06-01T00:00:00Z}]", FormatYAML, "end is not after start"},
+		{"unknown window day", "silences: [{name: a, window: {days: [sabbath], start: '22:00', duration: 1h}}]", FormatYAML, "unknown day"},
+		{"invalid window start", "silences: [{name: a, window: {start: '25:00', duration: 1h}}]", FormatYAML, "start of window"},
+		{"long window", "silences: [{name: a, window: {start: '22:00', duration: 200h}}]", FormatYAML, "duration of window"},
+		{"unknown time zone", "silences: [{name: a, window: {start: '22:00', duration: 1h, timezone: Mars/Olympus}}]", FormatYAML, "time zone"},
+		{"invalid silence scope", "silences: [{name: a, scope: {name: '('}}]", FormatYAML, "scope"},
+		{"unknown silence severity", "silences: [{name: a, severity: ok}]", FormatYAML, "unknown severity"},
+		{"unknown output", "outputs: [{type: slack}]", FormatYAML, "unknown type"},
+		{"file without path", "outputs: [{type: file}]", FormatYAML, "path"},
+		{"unknown precedence", "options: {precedence: min}", FormatYAML, "precedence"},
+		{"unknown format", "{}", "toml", "unknown format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data), tt.format)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	os.Setenv("QUOTAS_SET", "value")
+	os.Setenv("QUOTAS_EMPTY", "")
+	defer os.Unsetenv("QUOTAS_SET")
+	defer os.Unsetenv("QUOTAS_EMPTY")
+
+	got, err := Interpolate([]byte("${QUOTAS_SET} ${QUOTAS_EMPTY} ${QUOTAS_EMPTY:-default} ${QUOTAS_UNSET:-} $$HOME $HOME"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "value  default  $HOME $HOME"; string(got) != want {
+		t.Errorf("result = %q, want %q", got, want)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "quotas.json")
+	if err := ioutil.WriteFile(path, []byte(jsonConfig), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(c.Alarms) != 1 || c.Alarms[0].Threshold != 95 {
+		t.Errorf("alarms = %v", c.Alarms)
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Errorf("expected error for missing file")
+	}
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// reference to the environment variable: ${NAME} or ${NAME:-default}, $$ is replaced with $
+var envReference = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// returns data where references to environment variables are replaced with their values,
+// default value is used for unset or empty variable, error is returned for unset variables without default value
+func Interpolate(data []byte) ([]byte, error) {
+	missing := make(map[string]bool)
+
+	res := envReference.ReplaceAllFunc(data, func(ref []byte) []byte {
+		if string(ref) == "$$" {
+			return []byte("$")
+		}
+
+		m := envReference.FindSubmatch(ref)
+		name := string(m[1])
+		if value, ok := os.LookupEnv(name); ok && (value != "" || m[2] == nil) {
+			return []byte(value)
+		}
+		if m[2] != nil {
+			return m[3]
+		}
+
+		missing[name] = true
+		return ref
+	})
+
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for k := range missing {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+
+		return nil, fmt.Errorf("Error while interpolating config: environment variables are not set: %v", strings.Join(names, ", "))
+	}
+
+	return res, nil
+}
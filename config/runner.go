@@ -0,0 +1,246 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/runner"
+	"github.com/vslchnk/aws_quotas_checker/selector"
+)
+
+// Target is the account and the region which quotas are checked in
+type Target struct {
+	// nil if default credentials are used
+	Account *Account
+	// empty if default region is used
+	Region string
+}
+
+// returns selector of quotas, nil is returned if all quotas are selected
+func (c *Config) GetSelector() (*selector.Selector, error) {
+	if c.Selector == nil {
+		return nil, nil
+	}
+
+	sel, err := selector.Parse(c.Selector.Terms...)
+	if err != nil {
+		return nil, err
+	}
+
+	sel.Include = append(sel.Include, c.Selector.Include...)
+	sel.Exclude = append(sel.Exclude, c.Selector.Exclude...)
+	if c.Selector.Adjustable != nil {
+		sel.Adjustable = c.Selector.Adjustable
+	}
+	if c.Selector.Global != nil {
+		sel.Global = c.Selector.Global
+	}
+	sel.WithUsage = sel.WithUsage || c.Selector.WithUsage
+
+	if err := sel.Validate(); err != nil {
+		return nil, err
+	}
+
+	return sel, nil
+}
+
+// returns every combination of accounts and regions
+func (c *Config) Targets() []Target {
+	regions := c.Regions
+	if len(regions) == 0 {
+		regions = []string{""}
+	}
+
+	targets := make([]Target, 0, len(regions)*(len(c.Accounts)+1))
+	if len(c.Accounts) == 0 {
+		for _, region := range regions {
+			targets = append(targets, Target{Region: region})
+		}
+
+		return targets
+	}
+
+	for i := range c.Accounts {
+		for _, region := range regions {
+			targets = append(targets, Target{Account: &c.Accounts[i], Region: region})
+		}
+	}
+
+	return targets
+}
+
+// returns options of runner agent for the target
+func (c *Config) RunnerOptions(t Target) []runner.Option {
+	o := c.Options
+	opts := make([]runner.Option, 0, 0)
+
+	if o.Workers > 0 {
+		opts = append(opts, runner.WithWorkers(o.Workers))
+	}
+	opts = append(opts, runner.WithTimeouts(runner.Timeouts{Overall: time.Duration(o.Timeouts.Overall), Call: time.Duration(o.Timeouts.Call)}))
+	for namespace, l := range o.RateLimits {
+		opts = append(opts, runner.WithRateLimit(namespace, l.Rate, l.Burst))
+	}
+	for endpointID, url := range o.Endpoints {
+		opts = append(opts, runner.WithEndpoint(endpointID, url))
+	}
+	if o.Lazy {
+		opts = append(opts, runner.WithLazyLoading())
+	}
+	if o.CatalogCache != nil {
+		opts = append(opts, runner.WithCatalogCache(o.CatalogCache.Dir, time.Duration(o.CatalogCache.TTL)))
+	}
+	if o.Precedence != "" {
+		opts = append(opts, runner.WithPrecedence(runner.Precedence(o.Precedence)))
+	}
+	if o.DiscrepancyTolerance > 0 {
+		opts = append(opts, runner.WithDiscrepancyTolerance(o.DiscrepancyTolerance))
+	}
+	if o.RateLookback > 0 {
+		opts = append(opts, runner.WithRateLookback(time.Duration(o.RateLookback)))
+	}
+	if o.History != nil {
+		opts = append(opts, runner.WithUsageHistory(o.History.Dir, time.Duration(o.History.Retention)))
+		if o.History.Backfill > 0 {
+			opts = append(opts, runner.WithHistoryBackfill(time.Duration(o.History.Backfill)))
+		}
+	}
+
+	if a := t.Account; a != nil {
+		if a.Profile != "" {
+			opts = append(opts, runner.WithProfile(a.Profile))
+		}
+		if a.RoleARN != "" {
+			opts = append(opts, runner.WithAssumeRole(a.RoleARN, a.ExternalID))
+		}
+		if a.ID != "" {
+			opts = append(opts, runner.WithAccount(a.ID))
+		}
+	}
+
+	return opts
+}
+
+// creates runner agent for the target with selector, alarms and silences from Config,
+// extra options are applied after options from Config
+func (c *Config) NewRunner(ctx context.Context, t Target, opts ...runner.Option) (*runner.Runner, error) {
+	sel, err := c.GetSelector()
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := runner.NewRunnerWithOptions(ctx, t.Region, sel, append(c.RunnerOptions(t), opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("Error while creating runner agent: %w", err)
+	}
+
+	for _, a := range c.Alarms {
+		if err := r.AddAlarmRule(a.rule()); err != nil {
+			return nil, err
+		}
+	}
+	for _, cs := range c.Silences {
+		s, err := cs.silence()
+		if err != nil {
+			return nil, err
+		}
+		if err := r.AddSilence(s); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// returns rule of runner agent for the alarm
+func (a Alarm) rule() runner.AlarmRule {
+	rule := runner.AlarmRule{
+		Name:          a.Name,
+		Threshold:     a.Threshold,
+		Remaining:     a.Remaining,
+		Exhaustion:    time.Duration(a.Exhaustion),
+		MinConfidence: a.MinConfidence,
+		Expression:    a.Expression,
+		Combine:       runner.Combination(a.Combine),
+		Severity:      runner.SeverityWarning,
+		Tag:           a.Tag,
+
+		For:            time.Duration(a.For),
+		ClearThreshold: a.ClearThreshold,
+		ClearRemaining: a.ClearRemaining,
+	}
+	if a.Severity != "" {
+		rule.Severity, _ = runner.ParseSeverity(a.Severity)
+	}
+	if a.Scope != nil {
+		rule.Scope = *a.Scope
+	}
+
+	return rule
+}
+
+// returns silence of runner agent, days, start time and time zone of the window are parsed
+func (s Silence) silence() (runner.Silence, error) {
+	silence := runner.Silence{
+		Name:    s.Name,
+		Kind:    runner.SilenceKind(s.Kind),
+		Alarm:   s.Alarm,
+		Owner:   s.Owner,
+		Comment: s.Comment,
+	}
+	if s.Scope != nil {
+		silence.Scope = *s.Scope
+	}
+	if s.Start != nil {
+		silence.Start = *s.Start
+	}
+	if s.End != nil {
+		silence.End = *s.End
+	}
+	if s.Severity != "" {
+		var err error
+		if silence.Severity, err = runner.ParseSeverity(s.Severity); err != nil {
+			return silence, err
+		}
+	}
+
+	if w := s.Window; w != nil {
+		window := runner.Window{Duration: time.Duration(w.Duration)}
+
+		for _, day := range w.Days {
+			weekday, ok := weekdays[strings.ToLower(day)]
+			if !ok {
+				return silence, fmt.Errorf("Error while parsing window: unknown day %q, known days are mon, tue, wed, thu, fri, sat and sun", day)
+			}
+			window.Weekdays = append(window.Weekdays, weekday)
+		}
+
+		start, err := time.Parse("15:04", w.Start)
+		if err != nil {
+			return silence, fmt.Errorf("Error while parsing start of window: %w", err)
+		}
+		window.Start = time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute
+
+		if w.Timezone != "" {
+			if window.Location, err = time.LoadLocation(w.Timezone); err != nil {
+				return silence, fmt.Errorf("Error while parsing time zone of window: %w", err)
+			}
+		}
+		silence.Window = &window
+	}
+
+	return silence, nil
+}
+
+// days of the week by short and full names
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/render"
+	"github.com/vslchnk/aws_quotas_checker/runner"
+	"github.com/vslchnk/aws_quotas_checker/selector"
+	"github.com/vslchnk/aws_quotas_checker/utils"
+)
+
+// known types and formats of outputs, text format is the same as table one
+var (
+	outputTypes   = []string{"stdout", "file"}
+	outputFormats = append([]string{"text"}, render.Formats()...)
+)
+
+// known severities of alarms and combinations of their conditions
+var (
+	severities   = []string{"info", "warning", "critical"}
+	combinations = []string{"any", "all"}
+)
+
+// known kinds of silences
+var silenceKinds = []string{"silence", "maintenance", "acknowledgement"}
+
+// known values of precedence of usage sources
+var precedences = []string{"api", "metrics", "max"}
+
+// region code, e.g. us-east-1 or us-gov-west-1
+var regionCode = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+
+// checks that all values of Config are valid, the first invalid value is reported
+func (c *Config) Validate() error {
+	names := make(map[string]bool)
+	for i, a := range c.Accounts {
+		if a.Name == "" {
+			return fmt.Errorf("Invalid config: accounts[%v]: name is empty", i)
+		}
+		if names[a.Name] {
+			return fmt.Errorf("Invalid config: accounts[%v]: duplicated name %v", i, a.Name)
+		}
+		names[a.Name] = true
+		if a.ExternalID != "" && a.RoleARN == "" {
+			return fmt.Errorf("Invalid config: accounts[%v]: external ID is set without role", i)
+		}
+	}
+
+	for i, r := range c.Regions {
+		if !regionCode.MatchString(r) {
+			return fmt.Errorf("Invalid config: regions[%v]: invalid region %q", i, r)
+		}
+	}
+
+	if _, err := c.GetSelector(); err != nil {
+		return fmt.Errorf("Invalid config: selector: %w", err)
+	}
+
+	names = make(map[string]bool)
+	for i, a := range c.Alarms {
+		if a.Name == "" {
+			return fmt.Errorf("Invalid config: alarms[%v]: name is empty", i)
+		}
+		rule := a.rule()
+		key := fmt.Sprintf("%v %+v %v", a.Name, rule.Scope, a.Tag)
+		if names[key] {
+			return fmt.Errorf("Invalid config: alarms[%v]: duplicated name %v with the same scope", i, a.Name)
+		}
+		names[key] = true
+		if a.Threshold < 0 || a.Remaining < 0 {
+			return fmt.Errorf("Invalid config: alarms[%v]: threshold %v or remaining %v is negative", i, a.Threshold, a.Remaining)
+		}
+		if a.Threshold == 0 && a.Remaining == 0 && a.Exhaustion == 0 && a.Expression == "" && rule.Scope == (selector.Rule{}) && a.Tag == "" {
+			return fmt.Errorf("Invalid config: alarms[%v]: threshold, remaining, exhaustion or expression must be set for alarm without scope", i)
+		}
+		if a.Expression != "" {
+			if _, err := runner.ParseExpression(a.Expression); err != nil {
+				return fmt.Errorf("Invalid config: alarms[%v]: %w", i, err)
+			}
+		}
+		if a.Exhaustion < 0 || a.MinConfidence < 0 || a.MinConfidence > 1 {
+			return fmt.Errorf("Invalid config: alarms[%v]: exhaustion %v is negative or minimum confidence %v is not in range from 0 to 1", i, time.Duration(a.Exhaustion), a.MinConfidence)
+		}
+		if a.Combine != "" && !utils.Find(combinations, a.Combine) {
+			return fmt.Errorf("Invalid config: alarms[%v]: unknown combination %q, known combinations are %v", i, a.Combine, combinations)
+		}
+		if a.For < 0 {
+			return fmt.Errorf("Invalid config: alarms[%v]: for-duration is negative", i)
+		}
+		if a.ClearThreshold < 0 || a.ClearThreshold > a.Threshold {
+			return fmt.Errorf("Invalid config: alarms[%v]: clear threshold %v is not between 0 and threshold %v", i, a.ClearThreshold, a.Threshold)
+		}
+		if a.ClearRemaining < 0 || (a.ClearRemaining > 0 && a.ClearRemaining < a.Remaining) || (a.ClearRemaining > 0 && a.Remaining == 0) {
+			return fmt.Errorf("Invalid config: alarms[%v]: clear remaining %v is negative, less than remaining %v or remaining is not set", i, a.ClearRemaining, a.Remaining)
+		}
+		if err := rule.Scope.Validate(); err != nil {
+			return fmt.Errorf("Invalid config: alarms[%v]: scope: %w", i, err)
+		}
+		if strings.HasPrefix(a.Tag, "=") {
+			return fmt.Errorf("Invalid config: alarms[%v]: key of tag %q is empty", i, a.Tag)
+		}
+		if a.Severity != "" && !utils.Find(severities, a.Severity) {
+			return fmt.Errorf("Invalid config: alarms[%v]: unknown severity %q, known severities are %v", i, a.Severity, severities)
+		}
+	}
+
+	names = make(map[string]bool)
+	for i, s := range c.Silences {
+		if s.Name == "" {
+			return fmt.Errorf("Invalid config: silences[%v]: name is empty", i)
+		}
+		if names[s.Name] {
+			return fmt.Errorf("Invalid config: silences[%v]: duplicated name %v", i, s.Name)
+		}
+		names[s.Name] = true
+		if s.Severity != "" && !utils.Find(severities, s.Severity) {
+			return fmt.Errorf("Invalid config: silences[%v]: unknown severity %q, known severities are %v", i, s.Severity, severities)
+		}
+		silence, err := s.silence()
+		if err != nil {
+			return fmt.Errorf("Invalid config: silences[%v]: %w", i, err)
+		}
+		if s.Kind != "" && !utils.Find(silenceKinds, s.Kind) {
+			return fmt.Errorf("Invalid config: silences[%v]: unknown kind %q, known kinds are %v", i, s.Kind, silenceKinds)
+		}
+		if (s.Kind == "maintenance" && s.Window == nil) || (s.Kind != "" && s.Kind != "maintenance" && s.Window != nil) {
+			return fmt.Errorf("Invalid config: silences[%v]: window is required for maintenance and is not allowed for other kinds", i)
+		}
+		if s.Kind == "acknowledgement" && s.Owner == "" {
+			return fmt.Errorf("Invalid config: silences[%v]: owner of acknowledgement is empty", i)
+		}
+		if s.Start != nil && s.End != nil && !s.End.After(*s.Start) {
+			return fmt.Errorf("Invalid config: silences[%v]: end is not after start", i)
+		}
+		if w := silence.Window; w != nil && (w.Duration <= 0 || w.Duration > 7*24*time.Hour) {
+			return fmt.Errorf("Invalid config: silences[%v]: duration of window %v is not between 0 and 7 days", i, w.Duration)
+		}
+		if _, err := path.Match(s.Alarm, ""); err != nil {
+			return fmt.Errorf("Invalid config: silences[%v]: invalid alarm glob %q", i, s.Alarm)
+		}
+		if err := silence.Scope.Validate(); err != nil {
+			return fmt.Errorf("Invalid config: silences[%v]: scope: %w", i, err)
+		}
+	}
+
+	for i, o := range c.Outputs {
+		if !utils.Find(outputTypes, o.Type) {
+			return fmt.Errorf("Invalid config: outputs[%v]: unknown type %q, known types are %v", i, o.Type, outputTypes)
+		}
+		if o.Format != "" && !utils.Find(outputFormats, o.Format) {
+			return fmt.Errorf("Invalid config: outputs[%v]: unknown format %q, known formats are %v", i, o.Format, outputFormats)
+		}
+		if o.Type == "file" && o.Path == "" {
+			return fmt.Errorf("Invalid config: outputs[%v]: path of file output is empty", i)
+		}
+	}
+
+	return c.Options.validate()
+}
+
+// checks that all options are valid
+func (o *Options) validate() error {
+	if o.Workers < 0 {
+		return fmt.Errorf("Invalid config: options: workers %v is negative", o.Workers)
+	}
+	if o.Timeouts.Overall < 0 || o.Timeouts.Call < 0 {
+		return fmt.Errorf("Invalid config: options: timeouts are negative")
+	}
+	for namespace, l := range o.RateLimits {
+		if l.Rate <= 0 || l.Burst < 1 {
+			return fmt.Errorf("Invalid config: options: rate limit of %v needs positive rate and burst", namespace)
+		}
+	}
+	if o.Precedence != "" && !utils.Find(precedences, o.Precedence) {
+		return fmt.Errorf("Invalid config: options: unknown precedence %q, known values are %v", o.Precedence, precedences)
+	}
+	if o.DiscrepancyTolerance < 0 || o.DiscrepancyTolerance > 1 {
+		return fmt.Errorf("Invalid config: options: discrepancy tolerance %v is not in range from 0 to 1", o.DiscrepancyTolerance)
+	}
+	if o.RateLookback < 0 {
+		return fmt.Errorf("Invalid config: options: rate lookback is negative")
+	}
+	if o.CatalogCache != nil && o.CatalogCache.TTL < 0 {
+		return fmt.Errorf("Invalid config: options: TTL of catalog cache is negative")
+	}
+	if o.History != nil && (o.History.Retention < 0 || o.History.Backfill < 0) {
+		return fmt.Errorf("Invalid config: options: retention or backfill of history is negative")
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package errs
+
+import (
+	"context"
+	"errors"
+
+	"github.com/vslchnk/aws_quotas_checker/utils"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// typed errors which can be checked with errors.Is
+var (
+	ErrAccessDenied   = errors.New("Access denied")
+	ErrThrottled      = errors.New("Request throttled")
+	ErrCanceled       = errors.New("Request canceled")
+	ErrUnknownService = errors.New("No service with such code")
+	ErrUnknownQuota   = errors.New("No quota with such code")
+	ErrNotSupported   = errors.New("Quota is not supported or not allowed")
+)
+
+// names of typed errors which are used in serialized results
+var names = []struct {
+	kind error
+	name string
+}{
+	{ErrAccessDenied, "AccessDenied"},
+	{ErrThrottled, "Throttled"},
+	{ErrCanceled, "Canceled"},
+	{ErrUnknownService, "UnknownService"},
+	{ErrUnknownQuota, "UnknownQuota"},
+	{ErrNotSupported, "NotSupported"},
+}
+
+// AWS error codes which mean that access is denied
+var accessDeniedCodes = []string{
+	"AccessDenied",
+	"AccessDeniedException",
+	"AuthFailure",
+	"UnauthorizedOperation",
+	"UnauthorizedException",
+}
+
+// AWS error codes which mean that request is throttled
+var throttledCodes = []string{
+	"Throttling",
+	"ThrottlingException",
+	"ThrottledException",
+	"RequestLimitExceeded",
+	"RequestThrottled",
+	"RequestThrottledException",
+	"TooManyRequestsException",
+	"SlowDown",
+}
+
+// AWS error codes which mean that operation is not supported
+var notSupportedCodes = []string{
+	"InvalidAction",
+	"UnknownOperationException",
+	"UnsupportedOperation",
+	"UnsupportedOperationException",
+}
+
+// classifiedError keeps the original error and adds the typed error to it
+type classifiedError struct {
+	kind error
+	err  error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+func (e *classifiedError) Is(target error) bool {
+	return target == e.kind
+}
+
+// returns error which matches one of typed errors with errors.Is if its kind is known, otherwise returns err
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, ErrAccessDenied) || errors.Is(err, ErrThrottled) || errors.Is(err, ErrCanceled) {
+		return err
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return &classifiedError{ErrCanceled, err}
+	}
+
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) {
+		return err
+	}
+
+	code := awsErr.Code()
+	switch {
+	case utils.Find(accessDeniedCodes, code):
+		return &classifiedError{ErrAccessDenied, err}
+	case utils.Find(throttledCodes, code):
+		return &classifiedError{ErrThrottled, err}
+	case utils.Find(notSupportedCodes, code):
+		return &classifiedError{ErrNotSupported, err}
+	case code == request.CanceledErrorCode:
+		return &classifiedError{ErrCanceled, err}
+	}
+
+	return err
+}
+
+// checks if AWS error code of err is equal to code
+func HasCode(err error, code string) bool {
+	var awsErr awserr.Error
+
+	return errors.As(err, &awsErr) && awsErr.Code() == code
+}
+
+// returns name of the typed error which err matches, empty string is returned if it matches none of them
+func Name(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	for _, n := range names {
+		if errors.Is(err, n.kind) {
+			return n.name
+		}
+	}
+
+	return ""
+}
+
+// returns typed error by its name, nil is returned for unknown name
+func FromName(name string) error {
+	for _, n := range names {
+		if n.name == name {
+			return n.kind
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+func TestClassifyAndName(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"access denied", awserr.New("AccessDeniedException", "denied", nil), "AccessDenied"},
+		{"throttled", awserr.New("RequestLimitExceeded", "slow down", nil), "Throttled"},
+		{"canceled", context.DeadlineExceeded, "Canceled"},
+		{"wrapped", fmt.Errorf("Error while getting usage: %w", awserr.New("Throttling", "rate exceeded", nil)), "Throttled"},
+		{"not supported", fmt.Errorf("%w: L-1", ErrNotSupported), "NotSupported"},
+		{"unsupported operation", awserr.New("UnsupportedOperationException", "not available", nil), "NotSupported"},
+		{"unknown", errors.New("something failed"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Classify(tt.err)
+
+			if got := Name(err); got != tt.want {
+				t.Errorf("name = %q, want %q", got, tt.want)
+			}
+			if tt.want != "" && !errors.Is(err, FromName(tt.want)) {
+				t.Errorf("error %v is not %v", err, FromName(tt.want))
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("original error is lost: %v", err)
+			}
+		})
+	}
+
+	if FromName("Unknown") != nil {
+		t.Errorf("unknown name has typed error")
+	}
+}
@@ -4,41 +4,56 @@ import (
 	"fmt"
 
 	"github.com/vslchnk/aws_quotas_checker/runner"
+	"github.com/vslchnk/aws_quotas_checker/selector"
 )
 
 func main() {
 	actions := runner.GetIam(nil)
 	actions.Print()
 
-	allowedServices := make(map[string]*[]string)
-
-	quotasEC2 := make([]string, 0, 0)
-	quotasEC2 = append(quotasEC2, "L-74FC7D96")
-	quotasEC2 = append(quotasEC2, "L-4FB7FF5D")
-	quotasEC2 = append(quotasEC2, "L-1216C47A")
+	// select quotas by service code, quota code glob and quota name
+	sel, err := selector.Parse(
+		"ec2:L-74FC7D96",
+		"ec2:L-4FB7FF5D",
+		"ec2:L-1216C47A",
+		"cloudformation",
+		"access-analyzer",
+		"elasticbeanstalk",
+		"autoscaling:L-CDE20ADC",
+		"s3",
+		"vpc",
+		"elasticloadbalancing~(?i)load balancers",
+		"elasticfilesystem",
+	)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
-	quotasAs := make([]string, 0, 0)
-	quotasAs = append(quotasAs, "L-CDE20ADC")
+	actions = runner.GetIam(sel)
+	actions.Print()
 
-	allowedServices["ec2"] = &quotasEC2
-	allowedServices["cloudformation"] = nil
-	allowedServices["access-analyzer"] = nil
-	allowedServices["elasticbeanstalk"] = nil
-	allowedServices["autoscaling"] = &quotasAs
-	allowedServices["s3"] = nil
-	allowedServices["vpc"] = nil
-	allowedServices["elasticloadbalancing"] = nil
-	allowedServices["elasticfilesystem"] = nil
+	r, err := runner.NewRunner("us-east-2", sel)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
-	actions = runner.GetIam(&allowedServices)
-	actions.Print()
+	// list codes of the selector which are not found in the catalog
+	validationErrors, _ := r.Validate()
+	for _, e := range validationErrors {
+		fmt.Println(e)
+	}
 
-	r, err := runner.NewRunner("us-east-2", &allowedServices)
-	fmt.Println(err)
+	// list errors of quotas which information or usage can't be found
+	for _, e := range r.GetErrors() {
+		fmt.Println(e)
+	}
 
 	// list all
 	for _, s := range r.ListSupportedServicesFromQuotas() {
-		fmt.Println("Service:", s, r.GetServiceName(s))
+		name, _ := r.GetServiceName(s)
+		fmt.Println("Service:", s, name)
 		quotas, _ := r.GetSupportedQuotasForService(s)
 		for _, q := range quotas {
 			quota, _ := r.GetServiceQuota(s, q)
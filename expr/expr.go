@@ -0,0 +1,304 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+)
+
+// Type is type of values of expressions
+type Type int
+
+// types of values, numbers are float64, strings are string and booleans are bool
+const (
+	TypeNumber Type = iota + 1
+	TypeString
+	TypeBool
+)
+
+// names of types
+var typeNames = map[Type]string{
+	TypeNumber: "number",
+	TypeString: "string",
+	TypeBool:   "bool",
+}
+
+func (t Type) String() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Type(%d)", int(t))
+}
+
+// returns type of the value, zero if the value is not number, string or bool
+func typeOf(value interface{}) Type {
+	switch value.(type) {
+	case float64:
+		return TypeNumber
+	case string:
+		return TypeString
+	case bool:
+		return TypeBool
+	}
+
+	return 0
+}
+
+// Vars are names and types of variables which expressions can refer to
+type Vars map[string]Type
+
+// Env returns value of the variable while the expression is evaluated, false is returned if the value is unknown
+type Env func(name string) (interface{}, bool)
+
+// Error is error of parsing or evaluating the expression at the position
+type Error struct {
+	Expression string
+	// position of the error in characters, 1 is the first character of the expression
+	Pos int
+	Msg string
+	// error is found while the expression is evaluated
+	eval bool
+}
+
+func (e *Error) Error() string {
+	action := "parsing"
+	if e.eval {
+		action = "evaluating"
+	}
+
+	return fmt.Sprintf("Error while %v expression %q at position %v: %v", action, e.Expression, e.Pos, e.Msg)
+}
+
+// returns error at the position
+func errorf(pos int, format string, a ...interface{}) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, a...)}
+}
+
+// Expr is parsed expression which types are checked, expressions have no side effects, loops and access to
+// anything except variables and builtin functions min, max and abs, so they are safe to evaluate
+type Expr struct {
+	source string
+	root   node
+}
+
+// returns source of the expression
+func (e *Expr) String() string {
+	return e.source
+}
+
+// returns type of the result of the expression
+func (e *Expr) Type() Type {
+	return e.root.typ()
+}
+
+// evaluates the expression with values of variables from env, operands of "and" and "or" are evaluated only
+// if they are needed, so unknown values on the other side don't fail the expression
+func (e *Expr) Eval(env Env) (interface{}, error) {
+	v, err := e.root.eval(env)
+	if err != nil {
+		err.Expression = e.source
+		err.eval = true
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// evaluates boolean expression
+func (e *Expr) EvalBool(env Env) (bool, error) {
+	v, err := e.Eval(env)
+	if err != nil {
+		return false, err
+	}
+
+	b, ok := v.(bool)
+	if !ok {
+		return false, &Error{Expression: e.source, Pos: 1, Msg: fmt.Sprintf("result is %v, bool is expected", typeOf(v)), eval: true}
+	}
+
+	return b, nil
+}
+
+// node is operation of the expression
+type node interface {
+	typ() Type
+	eval(env Env) (interface{}, *Error)
+}
+
+// literal is number, string or bool
+type literal struct {
+	value interface{}
+}
+
+func (n *literal) typ() Type {
+	return typeOf(n.value)
+}
+
+func (n *literal) eval(env Env) (interface{}, *Error) {
+	return n.value, nil
+}
+
+// variable is value from the environment
+type variable struct {
+	pos  int
+	name string
+	t    Type
+}
+
+func (n *variable) typ() Type {
+	return n.t
+}
+
+func (n *variable) eval(env Env) (interface{}, *Error) {
+	v, ok := env(n.name)
+	if !ok {
+		return nil, errorf(n.pos, "value of %v is unknown", n.name)
+	}
+	if t := typeOf(v); t != n.t {
+		return nil, errorf(n.pos, "value of %v is %T, %v is expected", n.name, v, n.t)
+	}
+
+	return v, nil
+}
+
+// unary is negation of number or boolean
+type unary struct {
+	op string
+	x  node
+}
+
+func (n *unary) typ() Type {
+	return n.x.typ()
+}
+
+func (n *unary) eval(env Env) (interface{}, *Error) {
+	x, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.op == "-" {
+		return -x.(float64), nil
+	}
+
+	return !x.(bool), nil
+}
+
+// binary is arithmetic, comparison or logical operation
+type binary struct {
+	pos  int
+	op   string
+	x, y node
+}
+
+func (n *binary) typ() Type {
+	switch n.op {
+	case "+", "-", "*", "/":
+		return TypeNumber
+	}
+
+	return TypeBool
+}
+
+func (n *binary) eval(env Env) (interface{}, *Error) {
+	x, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case n.op == "and" && !x.(bool):
+		return false, nil
+	case n.op == "or" && x.(bool):
+		return true, nil
+	}
+
+	y, err := n.y.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "and", "or":
+		return y.(bool), nil
+	case "==":
+		return x == y, nil
+	case "!=":
+		return x != y, nil
+	}
+
+	a, b := x.(float64), y.(float64)
+	switch n.op {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/":
+		if b == 0 {
+			return nil, errorf(n.pos, "division by zero")
+		}
+		return a / b, nil
+	case "<":
+		return a < b, nil
+	case "<=":
+		return a <= b, nil
+	case ">":
+		return a > b, nil
+	case ">=":
+		return a >= b, nil
+	}
+
+	return nil, errorf(n.pos, "unknown operator %v", n.op)
+}
+
+// function is builtin function of numbers
+type function struct {
+	// minimum and maximum number of arguments, negative maximum means any number
+	min, max int
+	fn       func(args []float64) float64
+}
+
+// builtin functions by name
+var functions = map[string]function{
+	"min": {1, -1, func(args []float64) float64 {
+		m := args[0]
+		for _, a := range args[1:] {
+			m = math.Min(m, a)
+		}
+		return m
+	}},
+	"max": {1, -1, func(args []float64) float64 {
+		m := args[0]
+		for _, a := range args[1:] {
+			m = math.Max(m, a)
+		}
+		return m
+	}},
+	"abs": {1, 1, func(args []float64) float64 {
+		return math.Abs(args[0])
+	}},
+}
+
+// call is call of builtin function
+type call struct {
+	fn   function
+	args []node
+}
+
+func (n *call) typ() Type {
+	return TypeNumber
+}
+
+func (n *call) eval(env Env) (interface{}, *Error) {
+	args := make([]float64, 0, len(n.args))
+	for _, arg := range n.args {
+		v, err := arg.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v.(float64))
+	}
+
+	return n.fn.fn(args), nil
+}
@@ -0,0 +1,145 @@
+package expr
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// variables of test expressions
+var testVars = Vars{
+	"usage":      TypeNumber,
+	"value":      TypeNumber,
+	"growth_7d":  TypeNumber,
+	"adjustable": TypeBool,
+	"service":    TypeString,
+}
+
+// returns environment with values of test variables, growth_7d is unknown
+func testEnv(name string) (interface{}, bool) {
+	values := map[string]interface{}{"usage": 85.0, "value": 100.0, "adjustable": false, "service": "vpc"}
+	v, ok := values[name]
+
+	return v, ok
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		source string
+		want   interface{}
+	}{
+		{"usage / value > 0.8 and adjustable == false", true},
+		{"usage/value>0.8&&!adjustable", true},
+		{"not adjustable and service == 'vpc'", true},
+		{`service != "ec2" or growth_7d > 20`, true},
+		{"usage > 90 and growth_7d > 20", false},
+		{"usage - value * 2", -115.0},
+		{"(usage - value) * 2", -30.0},
+		{"-usage + --value", 15.0},
+		{"max(usage, value, 90) - min(1e2, 5) + abs(-1.5)", 96.5},
+		{"1 + 2 == 3 == true", nil},
+		{`"a\"b" == 'a"b'`, true},
+		{"true or false and false", true},
+		{"not not true", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			e, err := Parse(tt.source, testVars)
+			if tt.want == nil {
+				if err == nil {
+					t.Errorf("error = nil, want parsing error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got, err := e.Eval(testEnv)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("result = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		source  string
+		wantPos int
+		wantMsg string
+	}{
+		{"", 1, "empty"},
+		{"usag > 5", 1, "unknown variable usag"},
+		{"usage > 5 and", 14, "unexpected end of expression"},
+		{"usage = 5", 7, "use \"==\""},
+		{"usage > 5 adjustable", 11, "unexpected \"adjustable\""},
+		{"usage + service > 5", 7, "operator + needs numbers, not number and string"},
+		{"usage == 'vpc'", 7, "number and string can't be compared"},
+		{"usage and adjustable", 7, "operator and needs bools"},
+		{"not usage", 1, "operator not needs bool"},
+		{"-adjustable", 1, "operator - needs number"},
+		{"(usage > 5", 11, "\")\" is expected"},
+		{"service == 'vpc", 12, "not terminated"},
+		{"usage > 5 # comment", 11, "unexpected character"},
+		{"1 < usage < 5", 11, "can't be chained"},
+		{"avg(usage)", 1, "unknown function avg"},
+		{"abs(usage, value)", 1, "abs is called with 2 arguments"},
+		{"max(adjustable)", 5, "argument of max is bool"},
+		{"1.2.3 > usage", 1, "invalid number"},
+		{"usage → 5", 7, "unexpected character"},
+		{strings.Repeat("(", 40) + "true" + strings.Repeat(")", 40), 33, "nested deeper"},
+		{strings.Repeat(" ", 1025), 1025, "longer than"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			_, err := Parse(tt.source, testVars)
+			e := &Error{}
+			if !errors.As(err, &e) {
+				t.Fatalf("error = %v, want *Error", err)
+			}
+			if e.Pos != tt.wantPos || !strings.Contains(e.Msg, tt.wantMsg) || e.Expression != tt.source {
+				t.Errorf("error = %v, want %q at position %v", err, tt.wantMsg, tt.wantPos)
+			}
+		})
+	}
+
+	if _, err := ParseBool("usage / value", testVars); err == nil || !strings.Contains(err.Error(), "result is number") {
+		t.Errorf("error = %v, want error for number result", err)
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		source  string
+		wantPos int
+		wantMsg string
+	}{
+		{"growth_7d > 20", 1, "value of growth_7d is unknown"},
+		{"usage > 5 and growth_7d > 20", 15, "value of growth_7d is unknown"},
+		{"usage / (value - 100) > 1", 7, "division by zero"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			e, err := ParseBool(tt.source, testVars)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			_, err = e.EvalBool(testEnv)
+			pe := &Error{}
+			if !errors.As(err, &pe) || pe.Pos != tt.wantPos || !strings.Contains(pe.Msg, tt.wantMsg) {
+				t.Errorf("error = %v, want %q at position %v", err, tt.wantMsg, tt.wantPos)
+			}
+			if err != nil && !strings.HasPrefix(err.Error(), "Error while evaluating expression") {
+				t.Errorf("error = %v, want evaluation error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,434 @@
+package expr
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// limits of expressions, so that parsing and evaluation of them is cheap
+const (
+	// maximum length of the expression in characters
+	maxLength = 1024
+	// maximum nesting of parentheses, negations and function calls
+	maxDepth = 32
+)
+
+// kinds of tokens
+const (
+	tokenEOF = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOperator
+)
+
+// token is number, string, identifier or operator of the expression
+type token struct {
+	kind int
+	text string
+	// position of the first character, 1 is the first character of the expression
+	pos   int
+	value interface{}
+}
+
+// returns description of the token for errors
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+
+	return strconv.Quote(t.text)
+}
+
+// operators of two characters and aliases of logical operators
+var (
+	operators2 = []string{"==", "!=", "<=", ">=", "&&", "||"}
+	aliases    = map[string]string{"&&": "and", "||": "or", "!": "not"}
+)
+
+// keywords which can't be names of variables
+var keywords = map[string]bool{"and": true, "or": true, "not": true, "true": true, "false": true}
+
+// returns tokens of the source
+func lex(source []rune) ([]token, *Error) {
+	tokens := make([]token, 0, 0)
+
+	for i := 0; i < len(source); {
+		c := source[i]
+		start := i
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+			continue
+		case unicode.IsDigit(c) || (c == '.' && i+1 < len(source) && unicode.IsDigit(source[i+1])):
+			for i < len(source) && (unicode.IsDigit(source[i]) || source[i] == '.') {
+				i++
+			}
+			if i < len(source) && (source[i] == 'e' || source[i] == 'E') {
+				i++
+				if i < len(source) && (source[i] == '+' || source[i] == '-') {
+					i++
+				}
+				for i < len(source) && unicode.IsDigit(source[i]) {
+					i++
+				}
+			}
+			text := string(source[start:i])
+			n, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, errorf(start+1, "invalid number %v", text)
+			}
+			tokens = append(tokens, token{tokenNumber, text, start + 1, n})
+		case unicode.IsLetter(c) || c == '_':
+			for i < len(source) && (unicode.IsLetter(source[i]) || unicode.IsDigit(source[i]) || source[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdent, string(source[start:i]), start + 1, nil})
+		case c == '"' || c == '\'':
+			s := strings.Builder{}
+			for i++; i < len(source) && source[i] != c; i++ {
+				if source[i] == '\\' && i+1 < len(source) {
+					i++
+				}
+				s.WriteRune(source[i])
+			}
+			if i == len(source) {
+				return nil, errorf(start+1, "string is not terminated")
+			}
+			i++
+			tokens = append(tokens, token{tokenString, string(source[start:i]), start + 1, s.String()})
+		default:
+			op := string(c)
+			for _, o := range operators2 {
+				if strings.HasPrefix(string(source[i:]), o) {
+					op = o
+				}
+			}
+			if op == string(c) && !strings.ContainsRune("+-*/()<>!,", c) {
+				if op == "=" {
+					return nil, errorf(start+1, "unexpected \"=\", use \"==\" to compare")
+				}
+				return nil, errorf(start+1, "unexpected character %q", c)
+			}
+			i += len([]rune(op))
+			if alias, ok := aliases[op]; ok {
+				op = alias
+			}
+			tokens = append(tokens, token{tokenOperator, op, start + 1, nil})
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(source) + 1}), nil
+}
+
+// parser builds nodes from tokens with recursive descent, operators from the lowest precedence are:
+// "or", "and", "not", comparisons, "+" and "-", "*" and "/", unary "-"
+type parser struct {
+	tokens []token
+	i      int
+	vars   Vars
+	depth  int
+}
+
+// parses the expression and checks types of its operations and variables, e.g.
+// `usage / value > 0.8 and not adjustable` or `service == "vpc" and max(remaining, 0) < 5`
+func Parse(source string, vars Vars) (*Expr, error) {
+	e, err := parse(source, vars)
+	if err != nil {
+		err.Expression = source
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// parses the expression which result must be bool
+func ParseBool(source string, vars Vars) (*Expr, error) {
+	e, err := Parse(source, vars)
+	if err != nil {
+		return nil, err
+	}
+	if e.Type() != TypeBool {
+		return nil, &Error{Expression: source, Pos: 1, Msg: fmt.Sprintf("result is %v, bool is expected", e.Type())}
+	}
+
+	return e, nil
+}
+
+// parses the expression, expression of errors is not set
+func parse(source string, vars Vars) (*Expr, *Error) {
+	runes := []rune(source)
+	if len(runes) > maxLength {
+		return nil, errorf(maxLength+1, "expression is longer than %v characters", maxLength)
+	}
+
+	tokens, err := lex(runes)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 1 {
+		return nil, errorf(1, "expression is empty")
+	}
+
+	p := parser{tokens: tokens, vars: vars}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, errorf(t.pos, "unexpected %v", t)
+	}
+
+	return &Expr{source: source, root: root}, nil
+}
+
+// returns the current token
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+// returns the current token and moves to the next one
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokenEOF {
+		p.i++
+	}
+
+	return t
+}
+
+// checks if the current token is one of operators or keywords
+func (p *parser) is(ops ...string) bool {
+	t := p.peek()
+	if t.kind != tokenOperator && t.kind != tokenIdent {
+		return false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return true
+		}
+	}
+
+	return false
+}
+
+// enters nested operation
+func (p *parser) enter(pos int) *Error {
+	p.depth++
+	if p.depth > maxDepth {
+		return errorf(pos, "expression is nested deeper than %v levels", maxDepth)
+	}
+
+	return nil
+}
+
+// parses operations of the same precedence from left to right
+func (p *parser) parseBinary(ops []string, operand func() (node, *Error)) (node, *Error) {
+	x, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.is(ops...) {
+		op := p.next()
+		y, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if x, err = newBinary(op, x, y); err != nil {
+			return nil, err
+		}
+	}
+
+	return x, nil
+}
+
+func (p *parser) parseOr() (node, *Error) {
+	return p.parseBinary([]string{"or"}, p.parseAnd)
+}
+
+func (p *parser) parseAnd() (node, *Error) {
+	return p.parseBinary([]string{"and"}, p.parseNot)
+}
+
+func (p *parser) parseNot() (node, *Error) {
+	if !p.is("not") {
+		return p.parseComparison()
+	}
+
+	op := p.next()
+	if err := p.enter(op.pos); err != nil {
+		return nil, err
+	}
+	x, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	p.depth--
+
+	if x.typ() != TypeBool {
+		return nil, errorf(op.pos, "operator not needs bool, not %v", x.typ())
+	}
+
+	return &unary{"not", x}, nil
+}
+
+func (p *parser) parseComparison() (node, *Error) {
+	x, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if !p.is("==", "!=", "<", "<=", ">", ">=") {
+		return x, nil
+	}
+
+	op := p.next()
+	y, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if p.is("==", "!=", "<", "<=", ">", ">=") {
+		return nil, errorf(p.peek().pos, "comparisons can't be chained, use \"and\"")
+	}
+
+	return newBinary(op, x, y)
+}
+
+func (p *parser) parseAdditive() (node, *Error) {
+	return p.parseBinary([]string{"+", "-"}, p.parseMultiplicative)
+}
+
+func (p *parser) parseMultiplicative() (node, *Error) {
+	return p.parseBinary([]string{"*", "/"}, p.parseUnary)
+}
+
+func (p *parser) parseUnary() (node, *Error) {
+	if !p.is("-") {
+		return p.parsePrimary()
+	}
+
+	op := p.next()
+	if err := p.enter(op.pos); err != nil {
+		return nil, err
+	}
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	p.depth--
+
+	if x.typ() != TypeNumber {
+		return nil, errorf(op.pos, "operator - needs number, not %v", x.typ())
+	}
+
+	return &unary{"-", x}, nil
+}
+
+func (p *parser) parsePrimary() (node, *Error) {
+	t := p.next()
+
+	switch {
+	case t.kind == tokenNumber || t.kind == tokenString:
+		return &literal{t.value}, nil
+	case t.kind == tokenIdent && (t.text == "true" || t.text == "false"):
+		return &literal{t.text == "true"}, nil
+	case t.kind == tokenIdent && !keywords[t.text] && p.is("("):
+		return p.parseCall(t)
+	case t.kind == tokenIdent && !keywords[t.text]:
+		typ, ok := p.vars[t.text]
+		if !ok {
+			return nil, errorf(t.pos, "unknown variable %v, known variables are %v", t.text, p.names())
+		}
+		return &variable{t.pos, t.text, typ}, nil
+	case t.kind == tokenOperator && t.text == "(":
+		if err := p.enter(t.pos); err != nil {
+			return nil, err
+		}
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.text != ")" || closing.kind != tokenOperator {
+			return nil, errorf(closing.pos, "unexpected %v, \")\" is expected", closing)
+		}
+		p.depth--
+		return x, nil
+	}
+
+	return nil, errorf(t.pos, "unexpected %v, value is expected", t)
+}
+
+// parses arguments of the call of builtin function
+func (p *parser) parseCall(name token) (node, *Error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, errorf(name.pos, "unknown function %v, known functions are abs, max and min", name.text)
+	}
+	if err := p.enter(name.pos); err != nil {
+		return nil, err
+	}
+	p.next()
+
+	args := make([]node, 0, 0)
+	for !p.is(")") {
+		if len(args) > 0 {
+			if comma := p.next(); comma.text != "," || comma.kind != tokenOperator {
+				return nil, errorf(comma.pos, "unexpected %v, \",\" or \")\" is expected", comma)
+			}
+		}
+		pos := p.peek().pos
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if arg.typ() != TypeNumber {
+			return nil, errorf(pos, "argument of %v is %v, number is expected", name.text, arg.typ())
+		}
+		args = append(args, arg)
+	}
+	p.next()
+	p.depth--
+
+	if len(args) < fn.min || (fn.max >= 0 && len(args) > fn.max) {
+		return nil, errorf(name.pos, "%v is called with %v arguments", name.text, len(args))
+	}
+
+	return &call{fn, args}, nil
+}
+
+// returns sorted names of variables
+func (p *parser) names() string {
+	names := make([]string, 0, len(p.vars))
+	for k := range p.vars {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+// creates binary operation and checks types of its operands
+func newBinary(op token, x node, y node) (node, *Error) {
+	tx, ty := x.typ(), y.typ()
+
+	switch op.text {
+	case "and", "or":
+		if tx != TypeBool || ty != TypeBool {
+			return nil, errorf(op.pos, "operator %v needs bools, not %v and %v", op.text, tx, ty)
+		}
+	case "==", "!=":
+		if tx != ty {
+			return nil, errorf(op.pos, "%v and %v can't be compared", tx, ty)
+		}
+	default:
+		if tx != TypeNumber || ty != TypeNumber {
+			return nil, errorf(op.pos, "operator %v needs numbers, not %v and %v", op.text, tx, ty)
+		}
+	}
+
+	return &binary{op.pos, op.text, x, y}, nil
+}
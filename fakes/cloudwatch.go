@@ -0,0 +1,37 @@
+package fakes
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+)
+
+// CloudWatch is a fake of CloudWatch API which returns configured datapoints by metric name
+type CloudWatch struct {
+	cloudwatchiface.CloudWatchAPI
+	Datapoints map[string][]*cloudwatch.Datapoint
+	// returned by all calls if set
+	Err error
+	// inputs of GetMetricStatistics calls
+	Inputs []*cloudwatch.GetMetricStatisticsInput
+	// guards Inputs, metrics are requested concurrently
+	mu sync.Mutex
+}
+
+func (f *CloudWatch) GetMetricStatisticsWithContext(ctx aws.Context, input *cloudwatch.GetMetricStatisticsInput, opts ...request.Option) (*cloudwatch.GetMetricStatisticsOutput, error) {
+	f.mu.Lock()
+	f.Inputs = append(f.Inputs, input)
+	f.mu.Unlock()
+
+	if f.Err != nil {
+		return nil, f.Err
+	}
+
+	return &cloudwatch.GetMetricStatisticsOutput{
+		Label:      input.MetricName,
+		Datapoints: f.Datapoints[aws.StringValue(input.MetricName)],
+	}, ctx.Err()
+}
@@ -0,0 +1,116 @@
+package fakes
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+// EC2 is a fake of EC2 API which returns configured pages of resources, not configured calls panic
+type EC2 struct {
+	ec2iface.EC2API
+	HostsPages        [][]*ec2.Host
+	VpcEndpointsPages [][]*ec2.VpcEndpoint
+	VpcsPages         [][]*ec2.Vpc
+	Addresses         []*ec2.Address
+	VpnGateways       []*ec2.VpnGateway
+	// returned by all calls if set
+	Err error
+	// number of calls by method name
+	Calls map[string]int
+	// guards Calls, the fake is called concurrently
+	mu sync.Mutex
+}
+
+func (f *EC2) call(method string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Calls == nil {
+		f.Calls = make(map[string]int)
+	}
+	f.Calls[method]++
+
+	return f.Err
+}
+
+func (f *EC2) DescribeHostsPagesWithContext(ctx aws.Context, input *ec2.DescribeHostsInput, fn func(*ec2.DescribeHostsOutput, bool) bool, opts ...request.Option) error {
+	if err := f.call("DescribeHosts"); err != nil {
+		return err
+	}
+
+	for i, page := range f.HostsPages {
+		if !fn(&ec2.DescribeHostsOutput{Hosts: page}, i == len(f.HostsPages)-1) {
+			break
+		}
+	}
+
+	return ctx.Err()
+}
+
+func (f *EC2) DescribeVpcEndpointsPagesWithContext(ctx aws.Context, input *ec2.DescribeVpcEndpointsInput, fn func(*ec2.DescribeVpcEndpointsOutput, bool) bool, opts ...request.Option) error {
+	if err := f.call("DescribeVpcEndpoints"); err != nil {
+		return err
+	}
+
+	for i, page := range f.VpcEndpointsPages {
+		if !fn(&ec2.DescribeVpcEndpointsOutput{VpcEndpoints: page}, i == len(f.VpcEndpointsPages)-1) {
+			break
+		}
+	}
+
+	return ctx.Err()
+}
+
+func (f *EC2) DescribeVpcsPagesWithContext(ctx aws.Context, input *ec2.DescribeVpcsInput, fn func(*ec2.DescribeVpcsOutput, bool) bool, opts ...request.Option) error {
+	if err := f.call("DescribeVpcs"); err != nil {
+		return err
+	}
+
+	for i, page := range f.VpcsPages {
+		if !fn(&ec2.DescribeVpcsOutput{Vpcs: page}, i == len(f.VpcsPages)-1) {
+			break
+		}
+	}
+
+	return ctx.Err()
+}
+
+// applies only domain filter of the input
+func (f *EC2) DescribeAddressesWithContext(ctx aws.Context, input *ec2.DescribeAddressesInput, opts ...request.Option) (*ec2.DescribeAddressesOutput, error) {
+	if err := f.call("DescribeAddresses"); err != nil {
+		return nil, err
+	}
+
+	addresses := f.Addresses
+	if input != nil {
+		for _, filter := range input.Filters {
+			if aws.StringValue(filter.Name) != "domain" {
+				continue
+			}
+
+			filtered := make([]*ec2.Address, 0, 0)
+			for _, a := range addresses {
+				for _, v := range filter.Values {
+					if aws.StringValue(a.Domain) == aws.StringValue(v) {
+						filtered = append(filtered, a)
+						break
+					}
+				}
+			}
+			addresses = filtered
+		}
+	}
+
+	return &ec2.DescribeAddressesOutput{Addresses: addresses}, ctx.Err()
+}
+
+func (f *EC2) DescribeVpnGatewaysWithContext(ctx aws.Context, input *ec2.DescribeVpnGatewaysInput, opts ...request.Option) (*ec2.DescribeVpnGatewaysOutput, error) {
+	if err := f.call("DescribeVpnGateways"); err != nil {
+		return nil, err
+	}
+
+	return &ec2.DescribeVpnGatewaysOutput{VpnGateways: f.VpnGateways}, ctx.Err()
+}
@@ -0,0 +1,54 @@
+package fakes
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elb/elbiface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+)
+
+// ELB is a fake of classic ELB API which returns configured pages of load balancers
+type ELB struct {
+	elbiface.ELBAPI
+	LoadBalancersPages [][]*elb.LoadBalancerDescription
+	// returned by all calls if set
+	Err error
+}
+
+func (f *ELB) DescribeLoadBalancersPagesWithContext(ctx aws.Context, input *elb.DescribeLoadBalancersInput, fn func(*elb.DescribeLoadBalancersOutput, bool) bool, opts ...request.Option) error {
+	if f.Err != nil {
+		return f.Err
+	}
+
+	for i, page := range f.LoadBalancersPages {
+		if !fn(&elb.DescribeLoadBalancersOutput{LoadBalancerDescriptions: page}, i == len(f.LoadBalancersPages)-1) {
+			break
+		}
+	}
+
+	return ctx.Err()
+}
+
+// ELBV2 is a fake of ELB v2 API which returns configured pages of load balancers
+type ELBV2 struct {
+	elbv2iface.ELBV2API
+	LoadBalancersPages [][]*elbv2.LoadBalancer
+	// returned by all calls if set
+	Err error
+}
+
+func (f *ELBV2) DescribeLoadBalancersPagesWithContext(ctx aws.Context, input *elbv2.DescribeLoadBalancersInput, fn func(*elbv2.DescribeLoadBalancersOutput, bool) bool, opts ...request.Option) error {
+	if f.Err != nil {
+		return f.Err
+	}
+
+	for i, page := range f.LoadBalancersPages {
+		if !fn(&elbv2.DescribeLoadBalancersOutput{LoadBalancers: page}, i == len(f.LoadBalancersPages)-1) {
+			break
+		}
+	}
+
+	return ctx.Err()
+}
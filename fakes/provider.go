@@ -0,0 +1,95 @@
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/vslchnk/aws_quotas_checker/errs"
+)
+
+// Provider is a fake usage provider which returns configured usage by quota code
+type Provider struct {
+	Code   string
+	Usage  map[string]float64
+	Errors map[string]error
+	Iam    map[string]string
+	// number of GetQuotaUsageWithContext calls by quota code
+	Calls map[string]int
+	// guards Calls, usage of quotas is requested concurrently
+	mu sync.Mutex
+}
+
+// returns map of usage where key is the quotas code and value is the usage
+func (p *Provider) GetUsage() (*map[string]float64, error) {
+	return p.GetUsageWithContext(context.Background())
+}
+
+// returns map of usage where key is the quotas code and value is the usage
+func (p *Provider) GetUsageWithContext(ctx context.Context) (*map[string]float64, error) {
+	usageMap := make(map[string]float64)
+
+	for _, k := range p.ListQuotasCodes() {
+		usage, err := p.GetQuotaUsageWithContext(ctx, k)
+		if err != nil {
+			return nil, err
+		}
+
+		usageMap[k] = *usage
+	}
+
+	return &usageMap, nil
+}
+
+// returns configured usage or error of the quota
+func (p *Provider) GetQuotaUsageWithContext(ctx context.Context, quotaCode string) (*float64, error) {
+	p.mu.Lock()
+	if p.Calls == nil {
+		p.Calls = make(map[string]int)
+	}
+	p.Calls[quotaCode]++
+	p.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err, ok := p.Errors[quotaCode]; ok {
+		return nil, err
+	}
+
+	usage, ok := p.Usage[quotaCode]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", errs.ErrNotSupported, quotaCode)
+	}
+
+	return &usage, nil
+}
+
+// returns sorted slice of quotas codes which have usage or error
+func (p *Provider) ListQuotasCodes() []string {
+	codes := make([]string, 0, len(p.Usage)+len(p.Errors))
+
+	for k := range p.Usage {
+		codes = append(codes, k)
+	}
+	for k := range p.Errors {
+		if _, ok := p.Usage[k]; !ok {
+			codes = append(codes, k)
+		}
+	}
+	sort.Strings(codes)
+
+	return codes
+}
+
+// returns code for service in quotas
+func (p *Provider) GetCode() string {
+	return p.Code
+}
+
+// returns configured actions for IAM policy
+func (p *Provider) GetIam() map[string]string {
+	return p.Iam
+}
@@ -0,0 +1,200 @@
+package fakes
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/servicequotas/servicequotasiface"
+)
+
+// ServiceQuotas is a fake of Service Quotas API which returns configured services and quotas
+type ServiceQuotas struct {
+	servicequotasiface.ServiceQuotasAPI
+	// pages of services
+	ServicesPages [][]*servicequotas.ServiceInfo
+	// pages of default quotas by service code
+	DefaultQuotasPages map[string][][]*servicequotas.ServiceQuota
+	// applied quotas by quota code, NoSuchResourceException is returned for missing ones
+	AppliedQuotas map[string]*servicequotas.ServiceQuota
+	// returned by ListServices if set
+	ServicesErr error
+	// returned by ListAWSDefaultServiceQuotas by service code
+	DefaultQuotasErrors map[string]error
+	// ListAWSDefaultServiceQuotas of the service code waits until the channel is closed or the context is done
+	DefaultQuotasWait map[string]chan struct{}
+	// returned by GetServiceQuota by quota code
+	AppliedQuotasErrors map[string]error
+	// returned by ListServiceQuotas by service code
+	AppliedListErrors map[string]error
+	// tags of applied quotas by quota ARN
+	Tags map[string]map[string]string
+	// returned by ListTagsForResource by quota ARN
+	TagsErrors map[string]error
+	// number of applied quotas in a page of ListServiceQuotas, all quotas are in one page if it is less than 1
+	AppliedPageSize int
+	// number of calls by method name
+	Calls map[string]int
+	// guards Calls, the fake is called concurrently
+	mu sync.Mutex
+}
+
+func (f *ServiceQuotas) call(method string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Calls == nil {
+		f.Calls = make(map[string]int)
+	}
+	f.Calls[method]++
+}
+
+func (f *ServiceQuotas) ListServicesPagesWithContext(ctx aws.Context, input *servicequotas.ListServicesInput, fn func(*servicequotas.ListServicesOutput, bool) bool, opts ...request.Option) error {
+	f.call("ListServices")
+
+	if f.ServicesErr != nil {
+		return f.ServicesErr
+	}
+
+	for i, page := range f.ServicesPages {
+		if !fn(&servicequotas.ListServicesOutput{Services: page}, i == len(f.ServicesPages)-1) {
+			break
+		}
+	}
+
+	return ctx.Err()
+}
+
+func (f *ServiceQuotas) ListAWSDefaultServiceQuotasPagesWithContext(ctx aws.Context, input *servicequotas.ListAWSDefaultServiceQuotasInput, fn func(*servicequotas.ListAWSDefaultServiceQuotasOutput, bool) bool, opts ...request.Option) error {
+	f.call("ListAWSDefaultServiceQuotas")
+
+	serviceCode := aws.StringValue(input.ServiceCode)
+	if wait, ok := f.DefaultQuotasWait[serviceCode]; ok {
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if err, ok := f.DefaultQuotasErrors[serviceCode]; ok {
+		return err
+	}
+
+	pages := f.DefaultQuotasPages[serviceCode]
+	for i, page := range pages {
+		if !fn(&servicequotas.ListAWSDefaultServiceQuotasOutput{Quotas: page}, i == len(pages)-1) {
+			break
+		}
+	}
+
+	return ctx.Err()
+}
+
+func (f *ServiceQuotas) GetServiceQuotaWithContext(ctx aws.Context, input *servicequotas.GetServiceQuotaInput, opts ...request.Option) (*servicequotas.GetServiceQuotaOutput, error) {
+	f.call("GetServiceQuota")
+
+	quotaCode := aws.StringValue(input.QuotaCode)
+	if err, ok := f.AppliedQuotasErrors[quotaCode]; ok {
+		return nil, err
+	}
+
+	quota, ok := f.AppliedQuotas[quotaCode]
+	if !ok {
+		return nil, awserr.New(servicequotas.ErrCodeNoSuchResourceException, "quota is not applied", nil)
+	}
+
+	return &servicequotas.GetServiceQuotaOutput{Quota: quota}, ctx.Err()
+}
+
+// applied quotas are listed in order of quota codes
+func (f *ServiceQuotas) ListServiceQuotasPagesWithContext(ctx aws.Context, input *servicequotas.ListServiceQuotasInput, fn func(*servicequotas.ListServiceQuotasOutput, bool) bool, opts ...request.Option) error {
+	f.call("ListServiceQuotas")
+
+	serviceCode := aws.StringValue(input.ServiceCode)
+	if err, ok := f.AppliedListErrors[serviceCode]; ok {
+		return err
+	}
+
+	codes := make([]string, 0, len(f.AppliedQuotas))
+	for k, v := range f.AppliedQuotas {
+		if aws.StringValue(v.ServiceCode) == serviceCode {
+			codes = append(codes, k)
+		}
+	}
+	sort.Strings(codes)
+
+	size := f.AppliedPageSize
+	if size < 1 {
+		size = len(codes)
+	}
+
+	for start := 0; start < len(codes) || start == 0; start += size {
+		end := start + size
+		if end > len(codes) {
+			end = len(codes)
+		}
+
+		page := make([]*servicequotas.ServiceQuota, 0, end-start)
+		for _, k := range codes[start:end] {
+			page = append(page, f.AppliedQuotas[k])
+		}
+
+		if !fn(&servicequotas.ListServiceQuotasOutput{Quotas: page}, end == len(codes)) || end == len(codes) {
+			break
+		}
+	}
+
+	return ctx.Err()
+}
+
+// tags are listed in order of keys
+func (f *ServiceQuotas) ListTagsForResourceWithContext(ctx aws.Context, input *servicequotas.ListTagsForResourceInput, opts ...request.Option) (*servicequotas.ListTagsForResourceOutput, error) {
+	f.call("ListTagsForResource")
+
+	arn := aws.StringValue(input.ResourceARN)
+	if err, ok := f.TagsErrors[arn]; ok {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(f.Tags[arn]))
+	for k := range f.Tags[arn] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tags := make([]*servicequotas.Tag, 0, len(keys))
+	for _, k := range keys {
+		tags = append(tags, &servicequotas.Tag{Key: aws.String(k), Value: aws.String(f.Tags[arn][k])})
+	}
+
+	return &servicequotas.ListTagsForResourceOutput{Tags: tags}, ctx.Err()
+}
+
+// returns not adjustable regional quota with the codes and the value, names are equal to the codes
+func NewServiceQuota(serviceCode string, quotaCode string, value float64) *servicequotas.ServiceQuota {
+	return &servicequotas.ServiceQuota{
+		Adjustable:  aws.Bool(false),
+		GlobalQuota: aws.Bool(false),
+		ServiceCode: aws.String(serviceCode),
+		ServiceName: aws.String(serviceCode),
+		QuotaArn:    aws.String(QuotaArn(serviceCode, quotaCode)),
+		QuotaCode:   aws.String(quotaCode),
+		QuotaName:   aws.String(quotaCode),
+		Value:       aws.Float64(value),
+	}
+}
+
+// returns ARN of the quota in us-east-2 region
+func QuotaArn(serviceCode string, quotaCode string) string {
+	return "arn:aws:servicequotas:us-east-2:123456789012:" + serviceCode + "/" + quotaCode
+}
+
+// returns service info with the code, name is equal to the code
+func NewServiceInfo(serviceCode string) *servicequotas.ServiceInfo {
+	return &servicequotas.ServiceInfo{
+		ServiceCode: aws.String(serviceCode),
+		ServiceName: aws.String(serviceCode),
+	}
+}
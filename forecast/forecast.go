@@ -0,0 +1,139 @@
+package forecast
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// minimum number of samples which the model is fitted to
+const minSamples = 3
+
+// Sample is usage of the quota at the moment
+type Sample struct {
+	Time  time.Time `json:"time"`
+	Usage float64   `json:"usage"`
+}
+
+// Model is linear growth of usage fitted to samples with least squares
+type Model struct {
+	// time of the first sample, usage of the model is Intercept at this time
+	Start     time.Time
+	Intercept float64
+	// growth of usage per day, negative for falling usage
+	Slope float64
+	// coefficient of determination from 0 to 1, it is 0 for constant usage
+	Confidence float64
+	// number of samples and time between the first and the last one
+	Samples int
+	Span    time.Duration
+	// time of the last sample
+	End time.Time
+}
+
+// Forecast is projection of usage of the quota to its value
+type Forecast struct {
+	// growth of usage per day
+	Growth float64
+	// time when usage reaches value of the quota, nil if usage doesn't grow
+	Exhaustion *time.Time
+	// coefficient of determination of the model from 0 to 1
+	Confidence float64
+	Samples    int
+	Span       time.Duration
+}
+
+// returns time which is left until exhaustion at the moment, zero if the quota is already exhausted,
+// false is returned if usage doesn't grow
+func (f Forecast) Left(now time.Time) (time.Duration, bool) {
+	if f.Exhaustion == nil {
+		return 0, false
+	}
+	if left := f.Exhaustion.Sub(now); left > 0 {
+		return left, true
+	}
+
+	return 0, true
+}
+
+// fits linear model to samples, at least 3 samples over non-zero time are needed
+func Fit(samples []Sample) (*Model, error) {
+	if len(samples) < minSamples {
+		return nil, fmt.Errorf("Error while fitting model: %v samples, at least %v are needed", len(samples), minSamples)
+	}
+
+	start, end := samples[0].Time, samples[0].Time
+	for _, s := range samples {
+		if s.Time.Before(start) {
+			start = s.Time
+		}
+		if s.Time.After(end) {
+			end = s.Time
+		}
+	}
+	if !end.After(start) {
+		return nil, fmt.Errorf("Error while fitting model: all samples are taken at the same time")
+	}
+
+	n := float64(len(samples))
+	meanX, meanY := 0.0, 0.0
+	for _, s := range samples {
+		meanX += days(s.Time.Sub(start))
+		meanY += s.Usage
+	}
+	meanX /= n
+	meanY /= n
+
+	sxx, sxy, syy := 0.0, 0.0, 0.0
+	for _, s := range samples {
+		dx, dy := days(s.Time.Sub(start))-meanX, s.Usage-meanY
+		sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+	}
+
+	m := Model{}
+	m.Start = start
+	m.End = end
+	m.Samples = len(samples)
+	m.Span = end.Sub(start)
+	m.Slope = sxy / sxx
+	m.Intercept = meanY - m.Slope*meanX
+	if syy > 0 {
+		m.Confidence = sxy * sxy / (sxx * syy)
+	}
+
+	return &m, nil
+}
+
+// returns usage of the model at the time
+func (m *Model) At(t time.Time) float64 {
+	return m.Intercept + m.Slope*days(t.Sub(m.Start))
+}
+
+// returns forecast of reaching the value, exhaustion is not earlier than the last sample
+func (m *Model) Forecast(value float64) Forecast {
+	f := Forecast{Growth: m.Slope, Confidence: m.Confidence, Samples: m.Samples, Span: m.Span}
+	if m.Slope <= 0 {
+		return f
+	}
+
+	// offset is limited, so that slow growth doesn't overflow time
+	offset := (value - m.Intercept) / m.Slope * float64(24*time.Hour)
+	if offset > math.MaxInt64/2 {
+		return f
+	}
+
+	exhaustion := m.End
+	if offset > float64(m.End.Sub(m.Start)) {
+		exhaustion = m.Start.Add(time.Duration(offset))
+	}
+	f.Exhaustion = &exhaustion
+
+	return f
+}
+
+// returns duration in days
+func days(d time.Duration) float64 {
+	return d.Hours() / 24
+}
@@ -0,0 +1,111 @@
+package forecast
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// returns samples taken every day with usage of the function of the day
+func newTestSamples(start time.Time, count int, usage func(day int) float64) []Sample {
+	samples := make([]Sample, 0, count)
+	for i := 0; i < count; i++ {
+		samples = append(samples, Sample{Time: start.Add(time.Duration(i) * 24 * time.Hour), Usage: usage(i)})
+	}
+
+	return samples
+}
+
+func TestFit(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		samples        []Sample
+		value          float64
+		wantGrowth     float64
+		wantConfidence float64
+		// days from start to exhaustion, negative means no exhaustion
+		wantDays float64
+		wantErr  bool
+	}{
+		{"linear growth", newTestSamples(start, 5, func(d int) float64 { return 10 + 2*float64(d) }), 30, 2, 1, 10, false},
+		{"constant usage", newTestSamples(start, 5, func(d int) float64 { return 10 }), 30, 0, 0, -1, false},
+		{"falling usage", newTestSamples(start, 5, func(d int) float64 { return 10 - float64(d) }), 30, -1, 1, -1, false},
+		{"already exhausted", newTestSamples(start, 5, func(d int) float64 { return 30 + float64(d) }), 30, 1, 1, 4, false},
+		{"noisy growth", newTestSamples(start, 4, func(d int) float64 { return []float64{10, 14, 12, 16}[d] }), 30, 1.6, 0.64, 12.125, false},
+		{"not enough samples", newTestSamples(start, 2, func(d int) float64 { return 1 }), 30, 0, 0, 0, true},
+		{"same time", []Sample{{start, 1}, {start, 2}, {start, 3}}, 30, 0, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Fit(tt.samples)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			f := m.Forecast(tt.value)
+			if math.Abs(f.Growth-tt.wantGrowth) > 1e-9 || math.Abs(f.Confidence-tt.wantConfidence) > 1e-9 {
+				t.Errorf("forecast = %+v, want growth %v and confidence %v", f, tt.wantGrowth, tt.wantConfidence)
+			}
+
+			if tt.wantDays < 0 {
+				if f.Exhaustion != nil {
+					t.Errorf("exhaustion = %v, want no exhaustion", f.Exhaustion)
+				}
+				return
+			}
+			want := start.Add(time.Duration(tt.wantDays * float64(24*time.Hour)))
+			if f.Exhaustion == nil || f.Exhaustion.Sub(want).Abs() > time.Second {
+				t.Errorf("exhaustion = %v, want %v", f.Exhaustion, want)
+			}
+		})
+	}
+}
+
+func TestHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history", "usage.json")
+	now := time.Now().UTC().Truncate(time.Second)
+
+	h, err := NewHistory(path, 10*24*time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	h.Add("ec2", "L-1", Sample{now, 5}, Sample{now.Add(-48 * time.Hour), 3})
+	// sample at the same time replaces the previous one and expired samples are dropped
+	h.Add("ec2", "L-1", Sample{now, 6}, Sample{now.Add(-24 * time.Hour), 4}, Sample{now.Add(-20 * 24 * time.Hour), 1})
+
+	want := []Sample{{now.Add(-48 * time.Hour), 3}, {now.Add(-24 * time.Hour), 4}, {now, 6}}
+	if got := h.Samples("ec2", "L-1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("samples = %+v, want %+v", got, want)
+	}
+
+	f, err := h.Forecast("ec2", "L-1", 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if left, ok := f.Left(now); !ok || left <= 0 {
+		t.Errorf("forecast = %+v, want exhaustion in the future", f)
+	}
+	if _, err := h.Forecast("ec2", "L-2", 10); err == nil {
+		t.Errorf("expected error for quota without samples")
+	}
+
+	if err := h.Save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	loaded, err := NewHistory(path, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := loaded.Samples("ec2", "L-1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded samples = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,134 @@
+package forecast
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/utils"
+)
+
+// time which samples are kept for by default
+const DefaultRetention = 30 * 24 * time.Hour
+
+// maximum number of samples of one quota, the oldest ones are dropped
+const maxSamples = 2000
+
+// History keeps samples of usage of quotas, samples older than retention are dropped,
+// history is kept in the file if its path is set
+type History struct {
+	path      string
+	retention time.Duration
+	mu        sync.Mutex
+	samples   map[string][]Sample
+}
+
+// creates History with samples from the file, missing file means empty history, empty path means that
+// samples are kept only in memory, not positive retention means the default one
+func NewHistory(path string, retention time.Duration) (*History, error) {
+	h := History{}
+	h.path = path
+	h.retention = retention
+	if retention <= 0 {
+		h.retention = DefaultRetention
+	}
+	h.samples = make(map[string][]Sample)
+
+	if path == "" {
+		return &h, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error while reading usage history: %w", err)
+	}
+	if err := json.Unmarshal(data, &h.samples); err != nil {
+		return nil, fmt.Errorf("Error while decoding usage history %v: %w", path, err)
+	}
+
+	return &h, nil
+}
+
+// returns path of the history file
+func (h *History) Path() string {
+	return h.path
+}
+
+// returns key of the quota in the history
+func key(serviceCode string, quotaCode string) string {
+	return serviceCode + ":" + quotaCode
+}
+
+// adds samples of the quota, samples are kept sorted by time and a sample replaces one taken at the same time
+func (h *History) Add(serviceCode string, quotaCode string, samples ...Sample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	k := key(serviceCode, quotaCode)
+	byTime := make(map[int64]Sample, len(h.samples[k])+len(samples))
+	for _, s := range append(h.samples[k], samples...) {
+		byTime[s.Time.UnixNano()] = s
+	}
+
+	oldest := time.Now().Add(-h.retention)
+	merged := make([]Sample, 0, len(byTime))
+	for _, s := range byTime {
+		if s.Time.After(oldest) {
+			merged = append(merged, s)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Time.Before(merged[j].Time)
+	})
+	if len(merged) > maxSamples {
+		merged = merged[len(merged)-maxSamples:]
+	}
+
+	h.samples[k] = merged
+}
+
+// returns samples of the quota sorted by time
+func (h *History) Samples(serviceCode string, quotaCode string) []Sample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]Sample{}, h.samples[key(serviceCode, quotaCode)]...)
+}
+
+// returns forecast of reaching the value by usage of the quota
+func (h *History) Forecast(serviceCode string, quotaCode string, value float64) (*Forecast, error) {
+	m, err := Fit(h.Samples(serviceCode, quotaCode))
+	if err != nil {
+		return nil, err
+	}
+	f := m.Forecast(value)
+
+	return &f, nil
+}
+
+// writes samples to the file, the file is replaced atomically, nothing is written if path is empty
+func (h *History) Save() error {
+	if h.path == "" {
+		return nil
+	}
+
+	h.mu.Lock()
+	data, err := json.Marshal(h.samples)
+	h.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("Error while encoding usage history: %w", err)
+	}
+
+	if err := utils.WriteFileAtomic(h.path, data); err != nil {
+		return fmt.Errorf("Error while writing usage history file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package quotas
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/utils"
+
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+)
+
+// CacheKey identifies catalog of services and quotas, every key has its own file
+type CacheKey struct {
+	Account   string
+	Partition string
+	Region    string
+}
+
+// Cache keeps catalog of services and their default quotas in a file, entries older than TTL are requested again,
+// expired entries are still used if request fails
+type Cache struct {
+	path    string
+	ttl     time.Duration
+	refresh bool
+	created time.Time
+	mu      sync.Mutex
+	catalog *catalog
+}
+
+type catalog struct {
+	Services *cachedServices          `json:"services,omitempty"`
+	Quotas   map[string]*cachedQuotas `json:"quotas"`
+}
+
+type cachedServices struct {
+	UpdatedAt time.Time                    `json:"updatedAt"`
+	Services  []*servicequotas.ServiceInfo `json:"services"`
+}
+
+type cachedQuotas struct {
+	UpdatedAt time.Time                     `json:"updatedAt"`
+	Quotas    []*servicequotas.ServiceQuota `json:"quotas"`
+}
+
+// creates Cache in the directory for the key, zero TTL means that entries never expire,
+// all entries kept before creation of Cache are treated as expired if refresh is set
+func NewCache(dir string, key CacheKey, ttl time.Duration, refresh bool) *Cache {
+	c := Cache{}
+	c.path = filepath.Join(dir, fmt.Sprintf("%v-%v-%v.json", key.Partition, key.Account, key.Region))
+	c.ttl = ttl
+	c.refresh = refresh
+	c.created = time.Now()
+
+	return &c
+}
+
+// returns default directory of cache files in the user cache directory
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("Error while getting user cache directory: %w", err)
+	}
+
+	return filepath.Join(dir, "aws_quotas_checker"), nil
+}
+
+// returns path of the cache file
+func (c *Cache) Path() string {
+	return c.path
+}
+
+// reads catalog from the file once, missing or broken file means empty catalog
+func (c *Cache) load() {
+	if c.catalog != nil {
+		return
+	}
+
+	c.catalog = &catalog{}
+	if data, err := ioutil.ReadFile(c.path); err == nil {
+		if err := json.Unmarshal(data, c.catalog); err != nil {
+			c.catalog = &catalog{}
+		}
+	}
+	if c.catalog.Quotas == nil {
+		c.catalog.Quotas = make(map[string]*cachedQuotas)
+	}
+}
+
+// writes catalog to the file, the file is replaced atomically
+func (c *Cache) save() error {
+	data, err := json.Marshal(c.catalog)
+	if err != nil {
+		return fmt.Errorf("Error while encoding catalog: %w", err)
+	}
+
+	if err := utils.WriteFileAtomic(c.path, data); err != nil {
+		return fmt.Errorf("Error while writing cache file: %w", err)
+	}
+
+	return nil
+}
+
+// checks if entry updated at the time is not expired
+func (c *Cache) isFresh(updatedAt time.Time) bool {
+	if c.refresh && updatedAt.Before(c.created) {
+		return false
+	}
+
+	return c.ttl <= 0 || time.Since(updatedAt) < c.ttl
+}
+
+// returns cached services and if they are not expired, nil is returned if there are no cached services
+func (c *Cache) getServices() ([]*servicequotas.ServiceInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.load()
+	if c.catalog.Services == nil {
+		return nil, false
+	}
+
+	return c.catalog.Services.Services, c.isFresh(c.catalog.Services.UpdatedAt)
+}
+
+// keeps services in the cache
+func (c *Cache) putServices(services []*servicequotas.ServiceInfo) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.load()
+	c.catalog.Services = &cachedServices{UpdatedAt: time.Now(), Services: services}
+
+	return c.save()
+}
+
+// returns cached default quotas of the service and if they are not expired, nil is returned if there are no cached quotas
+func (c *Cache) getQuotas(serviceCode string) ([]*servicequotas.ServiceQuota, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.load()
+	cached, ok := c.catalog.Quotas[serviceCode]
+	if !ok {
+		return nil, false
+	}
+
+	return cached.Quotas, c.isFresh(cached.UpdatedAt)
+}
+
+// keeps default quotas of the service in the cache
+func (c *Cache) putQuotas(serviceCode string, quotas []*servicequotas.ServiceQuota) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.load()
+	c.catalog.Quotas[serviceCode] = &cachedQuotas{UpdatedAt: time.Now(), Quotas: quotas}
+
+	return c.save()
+}
@@ -0,0 +1,75 @@
+package quotas
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/fakes"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	key := CacheKey{Account: "123456789012", Partition: "aws", Region: "us-east-2"}
+	throttled := awserr.New("TooManyRequestsException", "rate exceeded", nil)
+
+	// fills the cache
+	client := newFakeClient()
+	if _, err := NewQuotaWithClient(context.Background(), client, nil, WithCache(NewCache(dir, key, time.Hour, false))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(NewCache(dir, key, time.Hour, false).Path()); err != nil {
+		t.Fatalf("cache file is not written: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		key     CacheKey
+		ttl     time.Duration
+		refresh bool
+		// changes fake client of the test
+		setup func(client *fakes.ServiceQuotas)
+		// number of ListServices and ListAWSDefaultServiceQuotas calls, quotas of s3 are never cached because of error
+		wantCalls int
+	}{
+		{name: "fresh", key: key, ttl: time.Hour, wantCalls: 1},
+		{name: "expired", key: key, ttl: time.Nanosecond, wantCalls: 4},
+		{name: "refresh", key: key, ttl: time.Hour, refresh: true, wantCalls: 4},
+		{name: "other key", key: CacheKey{Account: "210987654321", Partition: "aws", Region: "us-east-2"}, ttl: time.Hour, wantCalls: 4},
+		{name: "expired on throttling", key: key, ttl: time.Nanosecond, setup: func(client *fakes.ServiceQuotas) {
+			client.ServicesErr = throttled
+			client.DefaultQuotasErrors["ec2"] = throttled
+			client.DefaultQuotasErrors["vpc"] = throttled
+		}, wantCalls: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeClient()
+			if tt.setup != nil {
+				tt.setup(client)
+			}
+
+			q, err := NewQuotaWithClient(context.Background(), client, nil, WithCache(NewCache(dir, tt.key, tt.ttl, tt.refresh)))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if calls := client.Calls["ListServices"] + client.Calls["ListAWSDefaultServiceQuotas"]; calls != tt.wantCalls {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+
+			services := *q.ListServicesCodes()
+			if len(services) != 2 || services[0] != "ec2" || services[1] != "vpc" {
+				t.Errorf("services = %v, want [ec2 vpc]", services)
+			}
+			quotas, _ := q.ListQuotasCodes("ec2")
+			if quotas == nil || len(*quotas) != 3 {
+				t.Errorf("quotas of ec2 = %v, want 3 quotas", quotas)
+			}
+		})
+	}
+}
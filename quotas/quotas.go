@@ -1,19 +1,42 @@
 package quotas
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"strings"
+	"sort"
+	"sync"
+	"time"
 
+	"github.com/vslchnk/aws_quotas_checker/errs"
+	"github.com/vslchnk/aws_quotas_checker/ratelimit"
+	"github.com/vslchnk/aws_quotas_checker/selector"
 	"github.com/vslchnk/aws_quotas_checker/utils"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/servicequotas/servicequotasiface"
 )
 
 type serviceQuota struct {
 	servicequotas.ServiceQuota
 	ValueApplied *float64
+	// ARN of the applied quota which can be tagged, nil if the quota has only default value
+	AppliedArn *string
+	// error of getting applied value, default value is used as applied one in this case
+	ValueErr error
+}
+
+// duration of units of quota periods
+var periodUnits = map[string]time.Duration{
+	servicequotas.PeriodUnitMicrosecond: time.Microsecond,
+	servicequotas.PeriodUnitMillisecond: time.Millisecond,
+	servicequotas.PeriodUnitSecond:      time.Second,
+	servicequotas.PeriodUnitMinute:      time.Minute,
+	servicequotas.PeriodUnitHour:        time.Hour,
+	servicequotas.PeriodUnitDay:         24 * time.Hour,
+	servicequotas.PeriodUnitWeek:        7 * 24 * time.Hour,
 }
 
 type serviceInfo struct {
@@ -21,104 +44,360 @@ type serviceInfo struct {
 	serviceQuotas map[string]*serviceQuota
 }
 
+// serviceLoad is loading of quotas of the service, other requests of the service wait until it is done
+type serviceLoad struct {
+	done    chan struct{}
+	service *serviceInfo
+	err     error
+	// context of the load is done, so its error is not kept
+	canceled bool
+	// the service is invalidated during the load, so its result is stale and not kept
+	invalidated bool
+}
+
 type Quotas struct {
-	region          string
-	allowedServices *map[string]*[]string
-	client          *servicequotas.ServiceQuotas
-	servicesMap     map[string]*serviceInfo
+	region         string
+	selector       *selector.Selector
+	providerQuotas map[string][]string
+	client         servicequotasiface.ServiceQuotasAPI
+	cache          *Cache
+	mu             sync.Mutex
+	servicesNames  map[string]string
+	catalogCodes   []string
+	servicesMap    map[string]*serviceInfo
+	servicesErrors map[string]error
+	// loads of services in progress by service code, quotas are requested without holding mu
+	loading map[string]*serviceLoad
 }
 
 // creates Quotas agent
-func NewQuota(region string, allowedServices *map[string]*[]string) (*Quotas, error) {
-	q := Quotas{}
-	q.region = region
-	q.allowedServices = allowedServices
+func NewQuota(region string, sel *selector.Selector) (*Quotas, error) {
+	return NewQuotaWithContext(context.Background(), region, sel)
+}
 
+// creates Quotas agent, requests are made with the context
+func NewQuotaWithContext(ctx context.Context, region string, sel *selector.Selector) (*Quotas, error) {
 	ses, err := session.NewSession(&aws.Config{
-		Region: aws.String(q.region)},
+		Region: aws.String(region)},
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while creating session: %v", err)
+		return nil, fmt.Errorf("Error while creating session: %w", err)
+	}
+
+	return NewQuotaWithSession(ctx, ses, sel)
+}
+
+// Option changes Quotas agent
+type Option func(*Quotas)
+
+// keeps catalog of services and their default quotas in the cache
+func WithCache(cache *Cache) Option {
+	return func(q *Quotas) {
+		q.cache = cache
 	}
+}
 
-	q.client = servicequotas.New(ses)
-	q.servicesMap, err = getServicesMap(q.client, q.allowedServices)
+// sets codes of quotas which usage can be found in services API by service code,
+// they are selected by selector which selects only quotas with usage along with quotas which have usage metric
+func WithProviderQuotas(providerQuotas map[string][]string) Option {
+	return func(q *Quotas) {
+		q.providerQuotas = providerQuotas
+	}
+}
 
+// creates Quotas agent with client from the shared session, requests are made with the context
+func NewQuotaWithSession(ctx context.Context, ses *session.Session, sel *selector.Selector, opts ...Option) (*Quotas, error) {
+	q, err := NewLazyQuotaWithSession(ctx, ses, sel, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("Error while getting services information: %v", err)
+		return nil, err
 	}
 
+	err = q.LoadWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// creates Quotas agent with the client, any implementation of Service Quotas API can be used
+func NewQuotaWithClient(ctx context.Context, client servicequotasiface.ServiceQuotasAPI, sel *selector.Selector, opts ...Option) (*Quotas, error) {
+	q, err := NewLazyQuotaWithClient(ctx, client, sel, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = q.LoadWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// creates Quotas agent with client from the shared session which lists only services,
+// quotas of the service are loaded when the service is requested for the first time
+func NewLazyQuotaWithSession(ctx context.Context, ses *session.Session, sel *selector.Selector, opts ...Option) (*Quotas, error) {
+	client := servicequotas.New(ses)
+	ratelimit.Install(&client.Handlers, "servicequotas")
+
+	q, err := NewLazyQuotaWithClient(ctx, client, sel, opts...)
+	if err != nil {
+		return nil, err
+	}
+	q.region = aws.StringValue(ses.Config.Region)
+
+	return q, nil
+}
+
+// creates Quotas agent with the client which lists only services,
+// quotas of the service are loaded when the service is requested for the first time
+func NewLazyQuotaWithClient(ctx context.Context, client servicequotasiface.ServiceQuotasAPI, sel *selector.Selector, opts ...Option) (*Quotas, error) {
+	if err := sel.Validate(); err != nil {
+		return nil, err
+	}
+
+	q := Quotas{}
+	q.selector = sel
+	q.client = client
+	q.servicesMap = make(map[string]*serviceInfo)
+	q.servicesErrors = make(map[string]error)
+	q.loading = make(map[string]*serviceLoad)
+	for _, opt := range opts {
+		opt(&q)
+	}
+
+	services, err := q.listServices(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("Error while getting services information: %w", err)
+	}
+	q.servicesNames = getServicesNames(services, q.selector)
+	q.catalogCodes = make([]string, 0, len(services))
+	for _, service := range services {
+		q.catalogCodes = append(q.catalogCodes, aws.StringValue(service.ServiceCode))
+	}
+	sort.Strings(q.catalogCodes)
+
 	return &q, nil
 }
 
-// retunrs map with the key as a service code and value as a serviceInfo object
-func getServicesMap(client *servicequotas.ServiceQuotas, allowedServices *map[string]*[]string) (map[string]*serviceInfo, error) {
-	services := make(map[string]*serviceInfo)
+// loads quotas of all services which are not loaded yet, services which quotas can't be listed are skipped
+// and their errors are kept, error is returned only if the context is done
+func (q *Quotas) LoadWithContext(ctx context.Context) error {
+	codes := make([]string, 0, len(q.servicesNames))
+	for k := range q.servicesNames {
+		codes = append(codes, k)
+	}
+	sort.Strings(codes)
 
-	err := client.ListServicesPages(nil, func(page *servicequotas.ListServicesOutput, lastPage bool) bool {
-		for _, value := range page.Services {
-			isServiceAllowed := true
-			var allowedQuotas *[]string
+	for _, code := range codes {
+		_, err := q.GetServiceWithContext(ctx, code)
+		if err != nil && ctx.Err() != nil {
+			return fmt.Errorf("Error while getting services information: %w", errs.Classify(ctx.Err()))
+		}
+	}
 
-			if allowedServices != nil {
-				if !utils.CheckKeyMap(*allowedServices, *value.ServiceCode) {
-					isServiceAllowed = false
-				} else {
-					allowedQuotas, _ = (*allowedServices)[*value.ServiceCode]
-				}
+	return nil
+}
+
+// returns services from the cache if they are not expired, otherwise requests them,
+// expired cached services are returned if request fails
+func (q *Quotas) listServices(ctx context.Context) ([]*servicequotas.ServiceInfo, error) {
+	if q.cache != nil {
+		if services, fresh := q.cache.getServices(); services != nil && fresh {
+			return services, nil
+		}
+	}
+
+	services, err := listServices(ctx, q.client)
+	if err != nil {
+		if q.cache != nil && ctx.Err() == nil {
+			if cached, _ := q.cache.getServices(); cached != nil {
+				return cached, nil
 			}
+		}
 
-			if isServiceAllowed {
-				sq, err := getQuotasMap(client, *value.ServiceCode, allowedQuotas)
+		return nil, err
+	}
 
-				if err != nil {
-					utils.ExitErrorf("Unable to get quotas, %v", err)
-				}
+	if q.cache != nil {
+		q.cache.putServices(services)
+	}
 
-				si := &serviceInfo{}
-				si.serviceName = *value.ServiceName
-				si.serviceQuotas = sq
+	return services, nil
+}
 
-				services[*value.ServiceCode] = si
+// returns default quotas of the service from the cache if they are not expired, otherwise requests them,
+// expired cached quotas are returned if request fails
+func (q *Quotas) listDefaultQuotas(ctx context.Context, serviceCode string) ([]*servicequotas.ServiceQuota, error) {
+	if q.cache != nil {
+		if quotas, fresh := q.cache.getQuotas(serviceCode); quotas != nil && fresh {
+			return quotas, nil
+		}
+	}
+
+	quotas, err := listDefaultQuotas(ctx, q.client, serviceCode)
+	if err != nil {
+		if q.cache != nil && ctx.Err() == nil {
+			if cached, _ := q.cache.getQuotas(serviceCode); cached != nil {
+				return cached, nil
 			}
 		}
 
+		return nil, err
+	}
+
+	if q.cache != nil {
+		q.cache.putQuotas(serviceCode, quotas)
+	}
+
+	return quotas, nil
+}
+
+// returns all services from Service Quotas
+func listServices(ctx context.Context, client servicequotasiface.ServiceQuotasAPI) ([]*servicequotas.ServiceInfo, error) {
+	services := make([]*servicequotas.ServiceInfo, 0, 0)
+
+	err := client.ListServicesPagesWithContext(ctx, nil, func(page *servicequotas.ListServicesOutput, lastPage bool) bool {
+		services = append(services, page.Services...)
+
 		return true
 	})
 
+	if err == nil {
+		err = ctx.Err()
+	}
+
 	if err != nil {
-		return nil, fmt.Errorf("Error while getting list of services: %v", err)
+		return nil, errs.Classify(fmt.Errorf("Error while getting list of services: %w", err))
 	}
 
 	return services, nil
 }
 
-// returns map with information about quotas where key is quota code and value is serviceQuota object
-func getQuotasMap(client *servicequotas.ServiceQuotas, service string, allowedQuotas *[]string) (map[string]*serviceQuota, error) {
-	quotas := make(map[string]*serviceQuota)
+// returns all default quotas of the service
+func listDefaultQuotas(ctx context.Context, client servicequotasiface.ServiceQuotasAPI, service string) ([]*servicequotas.ServiceQuota, error) {
+	quotas := make([]*servicequotas.ServiceQuota, 0, 0)
 
 	params := &servicequotas.ListAWSDefaultServiceQuotasInput{
 		ServiceCode: aws.String(service),
 	}
 
-	err := client.ListAWSDefaultServiceQuotasPages(params, func(page *servicequotas.ListAWSDefaultServiceQuotasOutput, lastPage bool) bool {
-		for _, value := range page.Quotas {
-			isQuotaAllowed := true
-			if allowedQuotas != nil && !utils.Find(*allowedQuotas, *value.QuotaCode) {
-				isQuotaAllowed = false
-			}
-			if isQuotaAllowed {
-				quota := &serviceQuota{}
-				quota.ServiceQuota = *value
-				var err error
-				quota.ValueApplied, err = getAppliedQuotaValue(client, service, *value.QuotaCode, value.Value)
-
-				if err != nil {
-					utils.ExitErrorf("Unable to get applied quota value, %v", err)
+	err := client.ListAWSDefaultServiceQuotasPagesWithContext(ctx, params, func(page *servicequotas.ListAWSDefaultServiceQuotasOutput, lastPage bool) bool {
+		quotas = append(quotas, page.Quotas...)
+
+		return true
+	})
+
+	if err == nil {
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Error while getting list of quotas: %w", err)
+	}
+
+	return quotas, nil
+}
+
+// returns map of selected services with the key as a service code and value as a service name
+func getServicesNames(services []*servicequotas.ServiceInfo, sel *selector.Selector) map[string]string {
+	names := make(map[string]string)
+
+	for _, value := range services {
+		if sel.MatchService(*value.ServiceCode) {
+			names[*value.ServiceCode] = aws.StringValue(value.ServiceName)
+		}
+	}
+
+	return names
+}
+
+// returns map with information about selected quotas where key is quota code and value is serviceQuota object,
+// applied values are listed in bulk, every quota is requested separately only if listing is denied or not
+// supported, other errors of listing, e.g. throttling, keep default values and are set as errors of values,
+// providerQuotas are codes of quotas which usage can be found in services API
+func getQuotasMap(ctx context.Context, client servicequotasiface.ServiceQuotasAPI, service string, defaultQuotas []*servicequotas.ServiceQuota, sel *selector.Selector, providerQuotas []string) (map[string]*serviceQuota, error) {
+	quotas := make(map[string]*serviceQuota)
+
+	for _, value := range defaultQuotas {
+		if sel.MatchQuota(newSelectorQuota(service, value, providerQuotas)) {
+			quota := &serviceQuota{}
+			quota.ServiceQuota = *value
+			quotas[*value.QuotaCode] = quota
+		}
+	}
+
+	if len(quotas) == 0 {
+		return quotas, nil
+	}
+
+	appliedQuotas, listErr := getAppliedQuotas(ctx, client, service)
+	if listErr != nil && ctx.Err() != nil {
+		return nil, fmt.Errorf("Error while getting list of applied quotas: %w", ctx.Err())
+	}
+	listErr = errs.Classify(listErr)
+	fallback := errors.Is(listErr, errs.ErrAccessDenied) || errors.Is(listErr, errs.ErrNotSupported)
+
+	for code, quota := range quotas {
+		quota.ValueApplied = quota.Value
+
+		if listErr != nil && !fallback {
+			quota.ValueErr = fmt.Errorf("Unable to list applied quota values, %w", listErr)
+			continue
+		}
+
+		applied, ok := appliedQuotas[code]
+		if fallback {
+			var err error
+			applied, err = getAppliedQuota(ctx, client, service, code)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, fmt.Errorf("Error while getting applied quota value: %w", ctx.Err())
 				}
 
-				quotas[*value.QuotaCode] = quota
+				quota.ValueErr = errs.Classify(fmt.Errorf("Unable to get applied quota value, %w", err))
+				continue
+			}
+			ok = applied != nil
+		}
+
+		if ok && applied.Value != nil {
+			quota.ValueApplied = applied.Value
+			quota.AppliedArn = applied.QuotaArn
+		}
+	}
+
+	return quotas, nil
+}
+
+// returns information about the quota which is matched by selector
+func newSelectorQuota(service string, quota *servicequotas.ServiceQuota, providerQuotas []string) selector.Quota {
+	sq := selector.Quota{}
+	sq.ServiceCode = service
+	sq.QuotaCode = aws.StringValue(quota.QuotaCode)
+	sq.QuotaName = aws.StringValue(quota.QuotaName)
+	sq.Adjustable = aws.BoolValue(quota.Adjustable)
+	sq.Global = aws.BoolValue(quota.GlobalQuota)
+	sq.HasUsage = quota.UsageMetric != nil || utils.Find(providerQuotas, sq.QuotaCode)
+
+	return sq
+}
+
+// returns applied quotas of the service by quota code, quotas which have only default value are missing
+func getAppliedQuotas(ctx context.Context, client servicequotasiface.ServiceQuotasAPI, service string) (map[string]*servicequotas.ServiceQuota, error) {
+	quotas := make(map[string]*servicequotas.ServiceQuota)
+
+	params := &servicequotas.ListServiceQuotasInput{
+		ServiceCode: aws.String(service),
+	}
+
+	err := client.ListServiceQuotasPagesWithContext(ctx, params, func(page *servicequotas.ListServiceQuotasOutput, lastPage bool) bool {
+		for _, value := range page.Quotas {
+			if value.QuotaCode != nil && value.Value != nil {
+				quotas[*value.QuotaCode] = value
 			}
 		}
 
@@ -126,65 +405,236 @@ func getQuotasMap(client *servicequotas.ServiceQuotas, service string, allowedQu
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while getting list of quotas: %v", err)
+		if errs.HasCode(err, servicequotas.ErrCodeNoSuchResourceException) {
+			return quotas, nil
+		}
+
+		return nil, err
 	}
 
 	return quotas, nil
 }
 
-// returns applied quota value, could be the default one
-func getAppliedQuotaValue(client *servicequotas.ServiceQuotas, service string, quotaCode string, defaultValue *float64) (*float64, error) {
+// returns applied quota, nil is returned if the quota has only default value
+func getAppliedQuota(ctx context.Context, client servicequotasiface.ServiceQuotasAPI, service string, quotaCode string) (*servicequotas.ServiceQuota, error) {
 	params := &servicequotas.GetServiceQuotaInput{
 		ServiceCode: aws.String(service),
 		QuotaCode:   aws.String(quotaCode),
 	}
 
-	value, err := client.GetServiceQuota(params)
+	value, err := client.GetServiceQuotaWithContext(ctx, params)
 
 	if err != nil {
-		if strings.Contains(err.Error(), "NoSuchResourceException") {
-			return defaultValue, nil
+		if errs.HasCode(err, servicequotas.ErrCodeNoSuchResourceException) {
+			return nil, nil
 		} else {
 			return nil, err
 		}
 	}
 
-	return value.Quota.Value, nil
+	return value.Quota, nil
 }
 
-// returns slice of codes of available service
+// returns sorted slice of codes of available service, services which quotas can't be listed are skipped
 func (q *Quotas) ListServicesCodes() *[]string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	serviceCodes := make([]string, 0, len(q.servicesNames))
+
+	for k, _ := range q.servicesNames {
+		if _, ok := q.servicesErrors[k]; !ok {
+			serviceCodes = append(serviceCodes, k)
+		}
+	}
+	sort.Strings(serviceCodes)
+
+	return &serviceCodes
+}
+
+// returns sorted slice of codes of all services in Service Quotas, including services which are not selected
+func (q *Quotas) ListCatalogServicesCodes() []string {
+	return append([]string{}, q.catalogCodes...)
+}
+
+// returns default quotas of any service in Service Quotas, including quotas which are not selected,
+// catalog cache is used if it is set
+func (q *Quotas) ListCatalogQuotasWithContext(ctx context.Context, serviceCode string) ([]*servicequotas.ServiceQuota, error) {
+	if !utils.Find(q.catalogCodes, serviceCode) {
+		return nil, fmt.Errorf("%w: %v", errs.ErrUnknownService, serviceCode)
+	}
+
+	quotas, err := q.listDefaultQuotas(ctx, serviceCode)
+	if err != nil {
+		return nil, errs.Classify(fmt.Errorf("Unable to get quotas, %w", err))
+	}
+
+	return quotas, nil
+}
+
+// returns sorted slice of codes of services which quotas are already loaded
+func (q *Quotas) ListLoadedServicesCodes() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
 	serviceCodes := make([]string, 0, len(q.servicesMap))
 
-	for k, _ := range q.servicesMap {
+	for k := range q.servicesMap {
 		serviceCodes = append(serviceCodes, k)
 	}
+	sort.Strings(serviceCodes)
 
-	return &serviceCodes
+	return serviceCodes
 }
 
-// returns string slice of quotas codes for the service's code
+// returns sorted string slice of quotas codes for the service's code
 func (q *Quotas) ListQuotasCodes(serviceCode string) (*[]string, error) {
-	if service, ok := q.servicesMap[serviceCode]; ok {
-		quotasCodes := make([]string, 0, len(service.serviceQuotas))
+	return q.ListQuotasCodesWithContext(context.Background(), serviceCode)
+}
 
-		for k, _ := range service.serviceQuotas {
-			quotasCodes = append(quotasCodes, k)
-		}
+// returns sorted string slice of quotas codes for the service's code, quotas are loaded with the context if needed
+func (q *Quotas) ListQuotasCodesWithContext(ctx context.Context, serviceCode string) (*[]string, error) {
+	service, err := q.GetServiceWithContext(ctx, serviceCode)
+	if err != nil {
+		return nil, err
+	}
+
+	quotasCodes := make([]string, 0, len(service.serviceQuotas))
 
-		return &quotasCodes, nil
+	for k, _ := range service.serviceQuotas {
+		quotasCodes = append(quotasCodes, k)
 	}
+	sort.Strings(quotasCodes)
 
-	return nil, fmt.Errorf("No service with such code: %v", serviceCode)
+	return &quotasCodes, nil
 }
 
 // returns serviceInfo objects by the code of the service
 func (q *Quotas) GetService(serviceCode string) (*serviceInfo, error) {
-	if service, ok := q.servicesMap[serviceCode]; ok {
-		return service, nil
+	return q.GetServiceWithContext(context.Background(), serviceCode)
+}
+
+// returns serviceInfo objects by the code of the service, quotas of the service are loaded with the context
+// if they are requested for the first time or after invalidation, concurrent requests of the same service
+// wait for one load, requests of other services are not blocked by it
+func (q *Quotas) GetServiceWithContext(ctx context.Context, serviceCode string) (*serviceInfo, error) {
+	for {
+		q.mu.Lock()
+		if service, ok := q.servicesMap[serviceCode]; ok {
+			q.mu.Unlock()
+			return service, nil
+		}
+
+		name, ok := q.servicesNames[serviceCode]
+		if !ok {
+			q.mu.Unlock()
+			return nil, fmt.Errorf("%w: %v", errs.ErrUnknownService, serviceCode)
+		}
+
+		load, ok := q.loading[serviceCode]
+		if !ok {
+			load = &serviceLoad{done: make(chan struct{})}
+			q.loading[serviceCode] = load
+			q.mu.Unlock()
+
+			return q.loadService(ctx, serviceCode, name, load)
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-load.done:
+		case <-ctx.Done():
+			return nil, errs.Classify(fmt.Errorf("Unable to get quotas, %w", ctx.Err()))
+		}
+
+		// load is retried with this context if the context of the load is done
+		if !load.canceled || ctx.Err() != nil {
+			return load.service, load.err
+		}
+	}
+}
+
+// loads quotas of the service and finishes the load, result is kept unless the context is done
+// or the service is invalidated during the load
+func (q *Quotas) loadService(ctx context.Context, serviceCode string, name string, load *serviceLoad) (*serviceInfo, error) {
+	defaultQuotas, err := q.listDefaultQuotas(ctx, serviceCode)
+	var sq map[string]*serviceQuota
+	if err == nil {
+		sq, err = getQuotasMap(ctx, q.client, serviceCode, defaultQuotas, q.selector, q.providerQuotas[serviceCode])
+	}
+
+	q.mu.Lock()
+	defer close(load.done)
+	defer q.mu.Unlock()
+
+	if !load.invalidated {
+		delete(q.loading, serviceCode)
+	}
+
+	if err != nil {
+		load.err = errs.Classify(fmt.Errorf("Unable to get quotas, %w", err))
+		load.canceled = ctx.Err() != nil
+		if !load.canceled && !load.invalidated {
+			q.servicesErrors[serviceCode] = load.err
+		}
+
+		return nil, load.err
+	}
+
+	si := &serviceInfo{}
+	si.serviceName = name
+	si.serviceQuotas = sq
+	load.service = si
+	if load.invalidated {
+		return si, nil
+	}
+
+	q.servicesMap[serviceCode] = si
+	delete(q.servicesErrors, serviceCode)
+
+	return si, nil
+}
+
+// drops loaded quotas and error of the service, they are loaded again on the next request,
+// result of the load in progress is not kept
+func (q *Quotas) Invalidate(serviceCode string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.servicesMap, serviceCode)
+	delete(q.servicesErrors, serviceCode)
+	if load, ok := q.loading[serviceCode]; ok {
+		load.invalidated = true
+		delete(q.loading, serviceCode)
+	}
+}
+
+// drops loaded quotas and errors of all services, they are loaded again on the next request,
+// results of loads in progress are not kept
+func (q *Quotas) InvalidateAll() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.servicesMap = make(map[string]*serviceInfo)
+	q.servicesErrors = make(map[string]error)
+	for _, load := range q.loading {
+		load.invalidated = true
 	}
+	q.loading = make(map[string]*serviceLoad)
+}
+
+// returns errors of services which quotas can't be listed, key is the service code
+func (q *Quotas) GetErrors() map[string]error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	servicesErrors := make(map[string]error, len(q.servicesErrors))
 
-	return nil, fmt.Errorf("No service with such code: %v", serviceCode)
+	for k, v := range q.servicesErrors {
+		servicesErrors[k] = v
+	}
+
+	return servicesErrors
 }
 
 // returns name of the service for serviceInfo object
@@ -198,7 +648,49 @@ func (s *serviceInfo) GetServiceQuota(quotaCode string) (*serviceQuota, error) {
 		return quota, nil
 	}
 
-	return nil, fmt.Errorf("No quota with such code: %v", quotaCode)
+	return nil, fmt.Errorf("%w: %v", errs.ErrUnknownQuota, quotaCode)
+}
+
+// returns period of the rate-based quota, e.g. one second for requests per second, zero is returned for other quotas
+func (q *serviceQuota) GetPeriod() time.Duration {
+	if q.Period == nil {
+		return 0
+	}
+
+	return time.Duration(aws.Int64Value(q.Period.PeriodValue)) * periodUnits[aws.StringValue(q.Period.PeriodUnit)]
+}
+
+// returns tags of the applied quota, quotas which have only default value have no tags
+func (q *Quotas) GetQuotaTags(serviceCode string, quotaCode string) (map[string]string, error) {
+	return q.GetQuotaTagsWithContext(context.Background(), serviceCode, quotaCode)
+}
+
+// returns tags of the applied quota, requests are made with the context
+func (q *Quotas) GetQuotaTagsWithContext(ctx context.Context, serviceCode string, quotaCode string) (map[string]string, error) {
+	service, err := q.GetServiceWithContext(ctx, serviceCode)
+	if err != nil {
+		return nil, err
+	}
+	quota, err := service.GetServiceQuota(quotaCode)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]string)
+	if quota.AppliedArn == nil {
+		return tags, nil
+	}
+
+	output, err := q.client.ListTagsForResourceWithContext(ctx, &servicequotas.ListTagsForResourceInput{ResourceARN: quota.AppliedArn})
+	if err != nil {
+		return nil, errs.Classify(fmt.Errorf("Unable to get tags of quota %v, %w", quotaCode, err))
+	}
+
+	for _, tag := range output.Tags {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+
+	return tags, nil
 }
 
 // returns actions for IAM policy which allow to work with this package
@@ -206,7 +698,9 @@ func GetIam() []string {
 	actions := []string{
 		"servicequotas:GetServiceQuota",
 		"servicequotas:ListAWSDefaultServiceQuotas",
+		"servicequotas:ListServiceQuotas",
 		"servicequotas:ListServices",
+		"servicequotas:ListTagsForResource",
 	}
 
 	return actions
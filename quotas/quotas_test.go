@@ -0,0 +1,459 @@
+package quotas
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/vslchnk/aws_quotas_checker/errs"
+	"github.com/vslchnk/aws_quotas_checker/fakes"
+	"github.com/vslchnk/aws_quotas_checker/selector"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+)
+
+func newFakeClient() *fakes.ServiceQuotas {
+	return &fakes.ServiceQuotas{
+		ServicesPages: [][]*servicequotas.ServiceInfo{
+			{fakes.NewServiceInfo("ec2"), fakes.NewServiceInfo("vpc")},
+			{fakes.NewServiceInfo("s3")},
+		},
+		DefaultQuotasPages: map[string][][]*servicequotas.ServiceQuota{
+			"ec2": {
+				{fakes.NewServiceQuota("ec2", "L-1", 5), fakes.NewServiceQuota("ec2", "L-2", 10)},
+				{fakes.NewServiceQuota("ec2", "L-3", 20)},
+			},
+			"vpc": {
+				{fakes.NewServiceQuota("vpc", "L-4", 5)},
+			},
+		},
+		AppliedQuotas: map[string]*servicequotas.ServiceQuota{
+			"L-1": fakes.NewServiceQuota("ec2", "L-1", 50),
+		},
+		DefaultQuotasErrors: map[string]error{
+			"s3": awserr.New("AccessDeniedException", "access denied", nil),
+		},
+		AppliedQuotasErrors: map[string]error{
+			"L-3": awserr.New("TooManyRequestsException", "rate exceeded", nil),
+		},
+	}
+}
+
+func TestNewQuotaWithClient(t *testing.T) {
+	q, err := NewQuotaWithClient(context.Background(), newFakeClient(), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	services := *q.ListServicesCodes()
+	if len(services) != 2 || services[0] != "ec2" || services[1] != "vpc" {
+		t.Errorf("services = %v, want [ec2 vpc]", services)
+	}
+
+	quotas, err := q.ListQuotasCodes("ec2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*quotas) != 3 {
+		t.Errorf("quotas = %v, want 3 quotas from all pages", *quotas)
+	}
+
+	servicesErrors := q.GetErrors()
+	if !errors.Is(servicesErrors["s3"], errs.ErrAccessDenied) {
+		t.Errorf("s3 error = %v, want %v", servicesErrors["s3"], errs.ErrAccessDenied)
+	}
+
+	if _, err := q.GetService("s3"); !errors.Is(err, errs.ErrAccessDenied) {
+		t.Errorf("error = %v, want %v", err, errs.ErrAccessDenied)
+	}
+
+	if _, err := q.GetService("lambda"); !errors.Is(err, errs.ErrUnknownService) {
+		t.Errorf("error = %v, want %v", err, errs.ErrUnknownService)
+	}
+}
+
+func TestAppliedQuotaValue(t *testing.T) {
+	type want struct {
+		value float64
+		err   error
+	}
+
+	tests := []struct {
+		name string
+		// changes fake client of the test
+		setup func(client *fakes.ServiceQuotas)
+		want  map[string]want
+		// number of GetServiceQuota calls
+		wantCalls int
+	}{
+		{
+			name:  "listed in bulk",
+			setup: func(client *fakes.ServiceQuotas) {},
+			want: map[string]want{
+				"L-1": {value: 50},
+				"L-2": {value: 10},
+				"L-3": {value: 20},
+			},
+		},
+		{
+			name: "listed in several pages",
+			setup: func(client *fakes.ServiceQuotas) {
+				client.AppliedQuotas["L-3"] = fakes.NewServiceQuota("ec2", "L-3", 30)
+				client.AppliedPageSize = 1
+			},
+			want: map[string]want{
+				"L-1": {value: 50},
+				"L-2": {value: 10},
+				"L-3": {value: 30},
+			},
+		},
+		{
+			name: "requested one by one if listing fails",
+			setup: func(client *fakes.ServiceQuotas) {
+				client.AppliedListErrors = map[string]error{"ec2": awserr.New("AccessDeniedException", "access denied", nil)}
+			},
+			want: map[string]want{
+				"L-1": {value: 50},
+				"L-2": {value: 10},
+				"L-3": {value: 20, err: errs.ErrThrottled},
+			},
+			wantCalls: 3,
+		},
+		{
+			name: "default values are kept if listing is throttled",
+			setup: func(client *fakes.ServiceQuotas) {
+				client.AppliedListErrors = map[string]error{"ec2": awserr.New("ThrottlingException", "rate exceeded", nil)}
+			},
+			want: map[string]want{
+				"L-1": {value: 5, err: errs.ErrThrottled},
+				"L-2": {value: 10, err: errs.ErrThrottled},
+				"L-3": {value: 20, err: errs.ErrThrottled},
+			},
+		},
+		{
+			name: "requested one by one if listing is not supported",
+			setup: func(client *fakes.ServiceQuotas) {
+				client.AppliedListErrors = map[string]error{"ec2": awserr.New("UnsupportedOperationException", "not available", nil)}
+				delete(client.AppliedQuotasErrors, "L-3")
+			},
+			want: map[string]want{
+				"L-1": {value: 50},
+				"L-2": {value: 10},
+				"L-3": {value: 20},
+			},
+			wantCalls: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeClient()
+			tt.setup(client)
+
+			q, err := NewQuotaWithClient(context.Background(), client, &selector.Selector{Include: []selector.Rule{{Service: "ec2"}}})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			s, err := q.GetService("ec2")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for code, w := range tt.want {
+				quota, err := s.GetServiceQuota(code)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got := aws.Float64Value(quota.ValueApplied); got != w.value {
+					t.Errorf("value of %v = %v, want %v", code, got, w.value)
+				}
+				if w.err == nil && quota.ValueErr != nil {
+					t.Errorf("unexpected value error of %v: %v", code, quota.ValueErr)
+				}
+				if w.err != nil && !errors.Is(quota.ValueErr, w.err) {
+					t.Errorf("value error of %v = %v, want %v", code, quota.ValueErr, w.err)
+				}
+			}
+
+			if client.Calls["GetServiceQuota"] != tt.wantCalls {
+				t.Errorf("GetServiceQuota calls = %v, want %v", client.Calls["GetServiceQuota"], tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestAllowedServices(t *testing.T) {
+	allowed := map[string]*[]string{
+		"ec2": {"L-2", "L-3"},
+		"vpc": nil,
+	}
+
+	q, err := NewQuotaWithClient(context.Background(), newFakeClient(), selector.FromMap(&allowed))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		serviceCode string
+		want        []string
+	}{
+		{"ec2", []string{"L-2", "L-3"}},
+		{"vpc", []string{"L-4"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.serviceCode, func(t *testing.T) {
+			quotas, err := q.ListQuotasCodes(tt.serviceCode)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(*quotas) != len(tt.want) {
+				t.Fatalf("quotas = %v, want %v", *quotas, tt.want)
+			}
+			for i := range tt.want {
+				if (*quotas)[i] != tt.want[i] {
+					t.Errorf("quotas = %v, want %v", *quotas, tt.want)
+				}
+			}
+		})
+	}
+
+	if len(q.GetErrors()) != 0 {
+		t.Errorf("errors = %v, want none for not allowed services", q.GetErrors())
+	}
+}
+
+func TestSelector(t *testing.T) {
+	client := newFakeClient()
+	quotas := client.DefaultQuotasPages["ec2"]
+	quotas[0][0].QuotaName = aws.String("Running On-Demand instances")
+	quotas[0][1].Adjustable = aws.Bool(true)
+	quotas[1][0].UsageMetric = &servicequotas.MetricInfo{MetricName: aws.String("ResourceCount")}
+
+	tests := []struct {
+		name           string
+		terms          []string
+		providerQuotas map[string][]string
+		want           map[string][]string
+	}{
+		{"all", nil, nil, map[string][]string{"ec2": {"L-1", "L-2", "L-3"}, "vpc": {"L-4"}}},
+		{"service", []string{"vpc"}, nil, map[string][]string{"vpc": {"L-4"}}},
+		{"excluded service", []string{"!ec2", "!s3"}, nil, map[string][]string{"vpc": {"L-4"}}},
+		{"quota glob", []string{"*:L-[12]"}, nil, map[string][]string{"ec2": {"L-1", "L-2"}}},
+		{"name", []string{"ec2~(?i)on-demand"}, nil, map[string][]string{"ec2": {"L-1"}}},
+		{"excluded name", []string{"ec2", "!ec2~On-Demand"}, nil, map[string][]string{"ec2": {"L-2", "L-3"}}},
+		{"adjustable", []string{"ec2", "adjustable=true"}, nil, map[string][]string{"ec2": {"L-2"}}},
+		{"with usage", []string{"usage=true"}, map[string][]string{"vpc": {"L-4"}}, map[string][]string{"ec2": {"L-3"}, "vpc": {"L-4"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sel, err := selector.Parse(tt.terms...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			q, err := NewQuotaWithClient(context.Background(), client, sel, WithProviderQuotas(tt.providerQuotas))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := make(map[string][]string)
+			for _, service := range *q.ListServicesCodes() {
+				codes, _ := q.ListQuotasCodes(service)
+				if len(*codes) > 0 {
+					got[service] = *codes
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("quotas = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewQuotaWithClient(context.Background(), client, &selector.Selector{Include: []selector.Rule{{Name: "("}}}); err == nil {
+		t.Errorf("expected error for invalid name pattern")
+	}
+}
+
+func TestLazyLoading(t *testing.T) {
+	client := newFakeClient()
+
+	q, err := NewLazyQuotaWithClient(context.Background(), client, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	services := *q.ListServicesCodes()
+	if len(services) != 3 {
+		t.Errorf("services = %v, want all listed services", services)
+	}
+
+	steps := []struct {
+		name string
+		// action of the step
+		do func() error
+		// number of ListAWSDefaultServiceQuotas calls after the step
+		wantCalls int
+	}{
+		{"created", func() error { return nil }, 0},
+		{"first request", func() error { _, err := q.GetService("vpc"); return err }, 1},
+		{"memoized", func() error { _, err := q.ListQuotasCodes("vpc"); return err }, 1},
+		{"other service", func() error { _, err := q.GetService("ec2"); return err }, 2},
+		{"invalidated", func() error { q.Invalidate("vpc"); _, err := q.GetService("vpc"); return err }, 3},
+		{"all invalidated", func() error { q.InvalidateAll(); return q.LoadWithContext(context.Background()) }, 6},
+	}
+
+	for _, step := range steps {
+		if err := step.do(); err != nil {
+			t.Fatalf("%v: unexpected error: %v", step.name, err)
+		}
+		if calls := client.Calls["ListAWSDefaultServiceQuotas"]; calls != step.wantCalls {
+			t.Errorf("%v: calls = %v, want %v", step.name, calls, step.wantCalls)
+		}
+	}
+
+	loaded := q.ListLoadedServicesCodes()
+	if len(loaded) != 2 || loaded[0] != "ec2" || loaded[1] != "vpc" {
+		t.Errorf("loaded services = %v, want [ec2 vpc]", loaded)
+	}
+
+	if _, ok := q.GetErrors()["s3"]; !ok {
+		t.Errorf("errors = %v, want error of s3", q.GetErrors())
+	}
+}
+
+func TestConcurrentLoading(t *testing.T) {
+	client := newFakeClient()
+	wait := make(chan struct{})
+	client.DefaultQuotasWait = map[string]chan struct{}{"ec2": wait}
+
+	q, err := NewLazyQuotaWithClient(context.Background(), client, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	results := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, err := q.GetService("ec2")
+			results <- err
+		}()
+	}
+	for loading := false; !loading; {
+		q.mu.Lock()
+		_, loading = q.loading["ec2"]
+		q.mu.Unlock()
+	}
+
+	// other services are loaded while ec2 is loading, canceled request of ec2 doesn't wait for its load
+	if _, err := q.GetService("vpc"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := q.GetServiceWithContext(ctx, "ec2"); !errors.Is(err, errs.ErrCanceled) {
+		t.Errorf("error = %v, want %v", err, errs.ErrCanceled)
+	}
+
+	close(wait)
+	for i := 0; i < 3; i++ {
+		if err := <-results; err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if calls := client.Calls["ListAWSDefaultServiceQuotas"]; calls != 2 {
+		t.Errorf("calls = %v, want one load of every service", calls)
+	}
+}
+
+func TestInvalidateLoading(t *testing.T) {
+	client := newFakeClient()
+	wait := make(chan struct{})
+	client.DefaultQuotasWait = map[string]chan struct{}{"ec2": wait}
+
+	q, err := NewLazyQuotaWithClient(context.Background(), client, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	results := make(chan error, 1)
+	go func() {
+		_, err := q.GetService("ec2")
+		results <- err
+	}()
+	for loading := false; !loading; {
+		q.mu.Lock()
+		_, loading = q.loading["ec2"]
+		q.mu.Unlock()
+	}
+
+	// load which is in progress during invalidation returns its result, but it is not kept
+	q.Invalidate("ec2")
+	close(wait)
+	if err := <-results; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	q.mu.Lock()
+	_, loaded := q.servicesMap["ec2"]
+	q.mu.Unlock()
+	if loaded {
+		t.Errorf("quotas of ec2 are kept, want stale load to be dropped")
+	}
+
+	if _, err := q.GetService("ec2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls := client.Calls["ListAWSDefaultServiceQuotas"]; calls != 2 {
+		t.Errorf("calls = %v, want ec2 to be loaded again after invalidation", calls)
+	}
+}
+
+func TestListServicesError(t *testing.T) {
+	client := newFakeClient()
+	client.ServicesErr = awserr.New("AccessDeniedException", "access denied", nil)
+
+	_, err := NewQuotaWithClient(context.Background(), client, nil)
+	if !errors.Is(err, errs.ErrAccessDenied) {
+		t.Errorf("error = %v, want %v", err, errs.ErrAccessDenied)
+	}
+}
+
+func TestGetQuotaTags(t *testing.T) {
+	client := newFakeClient()
+	client.Tags = map[string]map[string]string{fakes.QuotaArn("ec2", "L-1"): {"team": "network", "env": "prod"}}
+
+	q, err := NewQuotaWithClient(context.Background(), client, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		quotaCode string
+		want      map[string]string
+		wantErr   error
+	}{
+		{"L-1", map[string]string{"team": "network", "env": "prod"}, nil},
+		// quota which has only default value can't be tagged
+		{"L-2", map[string]string{}, nil},
+		{"L-9", nil, errs.ErrUnknownQuota},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.quotaCode, func(t *testing.T) {
+			tags, err := q.GetQuotaTags("ec2", tt.quotaCode)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(tags, tt.want) {
+				t.Errorf("tags = %v, want %v", tags, tt.want)
+			}
+		})
+	}
+
+	if calls := client.Calls["ListTagsForResource"]; calls != 1 {
+		t.Errorf("calls = %v, want tags to be requested only for applied quota", calls)
+	}
+}
@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+type contextKey struct{}
+
+// Limiter is a token bucket which allows rate requests per second with bursts up to burst requests
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// Limiters keeps limiters by API namespace, namespace is the prefix of IAM actions (ec2, cloudwatch, servicequotas, ...)
+type Limiters map[string]*Limiter
+
+// creates Limiter with full bucket, burst less than 1 is treated as 1
+func NewLimiter(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	l := Limiter{}
+	l.rate = rate
+	l.burst = float64(burst)
+	l.tokens = l.burst
+	l.last = time.Now()
+
+	return &l
+}
+
+// waits until request is allowed or context is done
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l.rate <= 0 {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens--
+	wait := time.Duration(0)
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if wait == 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// returns copy of the context which carries limiters
+func NewContext(ctx context.Context, limiters Limiters) context.Context {
+	return context.WithValue(ctx, contextKey{}, limiters)
+}
+
+// returns limiters carried by the context
+func FromContext(ctx context.Context) Limiters {
+	limiters, _ := ctx.Value(contextKey{}).(Limiters)
+
+	return limiters
+}
+
+// waits for the limiter of the namespace carried by the context, returns immediately if there is no such limiter
+func Wait(ctx context.Context, namespace string) error {
+	if l, ok := FromContext(ctx)[namespace]; ok && l != nil {
+		return l.Wait(ctx)
+	}
+
+	return ctx.Err()
+}
+
+// returns request handler which waits for the limiter of the namespace before every attempt of the request
+func NewHandler(namespace string) request.NamedHandler {
+	return request.NamedHandler{
+		Name: "ratelimit." + namespace,
+		Fn: func(r *request.Request) {
+			if err := Wait(r.Context(), namespace); err != nil {
+				r.Error = err
+			}
+		},
+	}
+}
+
+// adds rate limiting for the namespace to the client handlers
+func Install(handlers *request.Handlers, namespace string) {
+	handlers.Sign.PushFrontNamed(NewHandler(namespace))
+}
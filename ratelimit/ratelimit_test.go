@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// returns time which Wait of the limiter takes
+func measureWait(t *testing.T, l *Limiter) time.Duration {
+	start := time.Now()
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return time.Since(start)
+}
+
+func TestLimiterBurst(t *testing.T) {
+	l := NewLimiter(10, 3)
+
+	for i := 0; i < 3; i++ {
+		if d := measureWait(t, l); d > 20*time.Millisecond {
+			t.Errorf("request %v waited %v, want no wait within burst", i, d)
+		}
+	}
+
+	// the next token is added in 100ms
+	if d := measureWait(t, l); d < 80*time.Millisecond || d > 500*time.Millisecond {
+		t.Errorf("request after burst waited %v, want about 100ms", d)
+	}
+}
+
+func TestLimiterRefill(t *testing.T) {
+	l := NewLimiter(10, 2)
+	measureWait(t, l)
+	measureWait(t, l)
+
+	// a second is passed, bucket is refilled up to burst
+	l.mu.Lock()
+	l.last = l.last.Add(-time.Second)
+	l.mu.Unlock()
+
+	for i := 0; i < 2; i++ {
+		if d := measureWait(t, l); d > 20*time.Millisecond {
+			t.Errorf("request %v waited %v, want no wait after refill", i, d)
+		}
+	}
+	if d := measureWait(t, l); d < 80*time.Millisecond {
+		t.Errorf("request after refilled burst waited %v, want about 100ms", d)
+	}
+}
+
+func TestLimiterCanceled(t *testing.T) {
+	l := NewLimiter(1, 1)
+	measureWait(t, l)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("canceled request waited %v, want return when context is done", d)
+	}
+
+	// token of the canceled request is returned to the bucket
+	l.mu.Lock()
+	tokens := l.tokens
+	l.mu.Unlock()
+	if tokens < -0.1 {
+		t.Errorf("tokens = %v, want token of canceled request to be returned", tokens)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewLimiter(0, 1).Wait(canceled); !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestWait(t *testing.T) {
+	ctx := NewContext(context.Background(), Limiters{"ec2": NewLimiter(1, 1)})
+
+	// limiter of ec2 is used, other namespaces and contexts without limiters are not limited
+	if err := Wait(ctx, "ec2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, c := range []context.Context{ctx, context.Background()} {
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			if err := Wait(c, "cloudwatch"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if d := time.Since(start); d > 20*time.Millisecond {
+			t.Errorf("requests waited %v, want no wait without limiter of the namespace", d)
+		}
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := Wait(canceled, "ec2"); !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want %v", err, context.Canceled)
+	}
+
+	r := &request.Request{HTTPRequest: &http.Request{}}
+	r.SetContext(canceled)
+	NewHandler("ec2").Fn(r)
+	if !errors.Is(r.Error, context.Canceled) {
+		t.Errorf("request error = %v, want %v", r.Error, context.Canceled)
+	}
+}
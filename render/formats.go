@@ -0,0 +1,183 @@
+package render
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// jsonRenderer writes array of objects with fields as keys in the order of fields
+type jsonRenderer struct{}
+
+func (jsonRenderer) Render(w io.Writer, t *Table) error {
+	buf := &bytes.Buffer{}
+	buf.WriteString("[")
+	for i, row := range t.Rows {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n  ")
+		if err := writeObject(buf, t.Fields, row); err != nil {
+			return err
+		}
+	}
+	if len(t.Rows) > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("]\n")
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("Error while writing JSON: %w", err)
+	}
+
+	return nil
+}
+
+// ndjsonRenderer writes one object per line
+type ndjsonRenderer struct{}
+
+func (ndjsonRenderer) Render(w io.Writer, t *Table) error {
+	buf := &bytes.Buffer{}
+	for _, row := range t.Rows {
+		if err := writeObject(buf, t.Fields, row); err != nil {
+			return err
+		}
+		buf.WriteString("\n")
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("Error while writing NDJSON: %w", err)
+	}
+
+	return nil
+}
+
+// writes JSON object of the row, encoding/json sorts keys of maps so the object is written by hand to keep order of fields
+func writeObject(buf *bytes.Buffer, fields []Field, row []Cell) error {
+	buf.WriteString("{")
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		key, err := json.Marshal(f.Name)
+		if err != nil {
+			return fmt.Errorf("Error while encoding field %v: %w", f.Name, err)
+		}
+		value, err := json.Marshal(row[i].Value)
+		if err != nil {
+			return fmt.Errorf("Error while encoding field %v: %w", f.Name, err)
+		}
+		buf.Write(key)
+		buf.WriteString(":")
+		buf.Write(value)
+	}
+	buf.WriteString("}")
+
+	return nil
+}
+
+// csvRenderer writes header with names of fields and raw values, so numbers stay numbers in spreadsheets
+type csvRenderer struct{}
+
+func (csvRenderer) Render(w io.Writer, t *Table) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(t.fieldNames()); err != nil {
+		return fmt.Errorf("Error while writing CSV: %w", err)
+	}
+
+	record := make([]string, len(t.Fields))
+	for _, row := range t.Rows {
+		for i, c := range row {
+			record[i] = formatRaw(c.Value)
+		}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("Error while writing CSV: %w", err)
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("Error while writing CSV: %w", err)
+	}
+
+	return nil
+}
+
+// tableRenderer writes aligned columns with titles and human-readable values
+type tableRenderer struct{}
+
+func (tableRenderer) Render(w io.Writer, t *Table) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	titles := make([]string, 0, len(t.Fields))
+	for _, f := range t.Fields {
+		titles = append(titles, f.Title)
+	}
+	fmt.Fprintln(tw, strings.Join(titles, "\t"))
+
+	for _, row := range t.Rows {
+		fmt.Fprintln(tw, strings.Join(texts(row, strings.NewReplacer("\t", " ", "\n", " ")), "\t"))
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("Error while writing table: %w", err)
+	}
+
+	return nil
+}
+
+// markdownRenderer writes GitHub-flavored Markdown table with titles and human-readable values
+type markdownRenderer struct{}
+
+func (markdownRenderer) Render(w io.Writer, t *Table) error {
+	buf := &bytes.Buffer{}
+	escape := strings.NewReplacer("|", "\\|", "\n", " ")
+
+	titles := make([]string, 0, len(t.Fields))
+	separators := make([]string, 0, len(t.Fields))
+	for _, f := range t.Fields {
+		titles = append(titles, escape.Replace(f.Title))
+		separators = append(separators, "---")
+	}
+	fmt.Fprintf(buf, "| %v |\n", strings.Join(titles, " | "))
+	fmt.Fprintf(buf, "| %v |\n", strings.Join(separators, " | "))
+
+	for _, row := range t.Rows {
+		fmt.Fprintf(buf, "| %v |\n", strings.Join(texts(row, escape), " | "))
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("Error while writing Markdown: %w", err)
+	}
+
+	return nil
+}
+
+// returns human-readable values of the row with replacements applied
+func texts(row []Cell, r *strings.Replacer) []string {
+	values := make([]string, 0, len(row))
+	for _, c := range row {
+		values = append(values, r.Replace(c.Text))
+	}
+
+	return values
+}
+
+// returns raw value as text, nil is empty
+func formatRaw(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case string:
+		return value
+	}
+
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,145 @@
+package render
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/vslchnk/aws_quotas_checker/runner"
+	"github.com/vslchnk/aws_quotas_checker/units"
+)
+
+// JUnitSuite is results of checking quotas of one account and region
+type JUnitSuite struct {
+	// name of the suite, e.g. account and region
+	Name    string
+	Results []runner.CheckResult
+	// error of collecting information, the suite has the only failed test case then
+	Error error
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitProblem struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// writes JUnit XML report with one test case per quota, quotas with raised alarms are failures with type of
+// the alarm severity, suppressed alarms are skipped test cases, quotas which usage can't be found
+// and failed suites are errors
+func WriteJUnit(w io.Writer, suites []JUnitSuite) error {
+	report := junitTestSuites{}
+
+	for _, s := range suites {
+		suite := junitTestSuite{Name: s.Name}
+
+		if s.Error != nil {
+			suite.Cases = append(suite.Cases, junitTestCase{
+				ClassName: s.Name,
+				Name:      "collect",
+				Error:     &junitProblem{Type: "error", Message: s.Error.Error()},
+			})
+		}
+
+		for _, result := range s.Results {
+			suite.Cases = append(suite.Cases, newJUnitTestCase(result))
+		}
+
+		for _, c := range suite.Cases {
+			suite.Tests++
+			if c.Failure != nil {
+				suite.Failures++
+			}
+			if c.Error != nil {
+				suite.Errors++
+			}
+			if c.Skipped != nil {
+				suite.Skipped++
+			}
+		}
+
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		report.Skipped += suite.Skipped
+		report.Suites = append(report.Suites, suite)
+	}
+
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Error while encoding JUnit report: %w", err)
+	}
+
+	if _, err := io.WriteString(w, xml.Header+string(data)+"\n"); err != nil {
+		return fmt.Errorf("Error while writing JUnit report: %w", err)
+	}
+
+	return nil
+}
+
+// creates test case of the quota
+func newJUnitTestCase(result runner.CheckResult) junitTestCase {
+	c := junitTestCase{
+		ClassName: result.ServiceCode,
+		Name:      strings.TrimSpace(result.QuotaCode + " " + result.QuotaName),
+	}
+
+	if result.Error != nil {
+		c.Error = &junitProblem{Type: "error", Message: result.Error.Error()}
+		return c
+	}
+
+	usage := fmt.Sprintf("usage %v of %v (%v%%)", units.FormatRate(result.Usage, result.Unit, result.Period),
+		units.FormatRate(result.Value, result.Unit, result.Period), units.FormatNumber(result.Percent))
+
+	if result.Severity == runner.SeverityOK {
+		c.SystemOut = usage
+		return c
+	}
+
+	if s := result.Silence; s != nil {
+		c.Skipped = &junitSkipped{Message: fmt.Sprintf("%v, alarm %v: %v, suppressed by %v %v", usage, result.Alarm, result.Condition, s.Kind, s.Name)}
+		c.SystemOut = usage
+		return c
+	}
+
+	c.Failure = &junitProblem{
+		Type:    result.Severity.String(),
+		Message: fmt.Sprintf("%v, alarm %v: %v", usage, result.Alarm, result.Condition),
+		Text:    fmt.Sprintf("service: %v\nquota: %v\nsource: %v\n", result.ServiceName, result.QuotaName, result.Type),
+	}
+
+	return c
+}
@@ -0,0 +1,367 @@
+package render
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/alerts"
+	"github.com/vslchnk/aws_quotas_checker/forecast"
+	"github.com/vslchnk/aws_quotas_checker/runner"
+	"github.com/vslchnk/aws_quotas_checker/units"
+)
+
+// fields of tables, names are the same as in runner.Snapshot and must not be changed
+var (
+	fieldServiceCode  = Field{"serviceCode", "SERVICE"}
+	fieldServiceName  = Field{"serviceName", "SERVICE NAME"}
+	fieldQuotaCode    = Field{"quotaCode", "QUOTA"}
+	fieldQuotaName    = Field{"quotaName", "NAME"}
+	fieldAdjustable   = Field{"adjustable", "ADJUSTABLE"}
+	fieldGlobalQuota  = Field{"globalQuota", "GLOBAL"}
+	fieldDefaultValue = Field{"defaultValue", "DEFAULT"}
+	fieldValue        = Field{"value", "VALUE"}
+	fieldUnit         = Field{"unit", "UNIT"}
+	fieldPeriod       = Field{"period", "PERIOD"}
+	fieldUsage        = Field{"usage", "USAGE"}
+	fieldPercent      = Field{"percent", "PERCENT"}
+	fieldSource       = Field{"source", "SOURCE"}
+	fieldApiUsage     = Field{"apiUsage", "API USAGE"}
+	fieldMetricUsage  = Field{"metricUsage", "METRIC USAGE"}
+	fieldDiscrepancy  = Field{"discrepancy", "DISCREPANCY"}
+	fieldError        = Field{"error", "ERROR"}
+	fieldAlarm        = Field{"alarm", "ALARM"}
+	fieldThreshold    = Field{"threshold", "THRESHOLD"}
+	fieldSeverity     = Field{"severity", "SEVERITY"}
+	fieldLimit        = Field{"limit", "LIMIT"}
+	fieldRemaining    = Field{"remaining", "REMAINING"}
+	fieldRemainingMin = Field{"remainingThreshold", "REMAINING THRESHOLD"}
+	fieldCondition    = Field{"condition", "CONDITION"}
+	fieldService      = Field{"service", "SERVICE"}
+	fieldAction       = Field{"action", "ACTION"}
+	fieldState        = Field{"state", "STATE"}
+	fieldFrom         = Field{"from", "FROM"}
+	fieldPrevious     = Field{"previousSeverity", "PREVIOUS SEVERITY"}
+	fieldSince        = Field{"since", "SINCE"}
+	fieldGrowth       = Field{"growth", "GROWTH"}
+	fieldExhaustion   = Field{"exhaustion", "EXHAUSTION"}
+	fieldDaysLeft     = Field{"daysLeft", "LEFT"}
+	fieldConfidence   = Field{"confidence", "CONFIDENCE"}
+	fieldSamples      = Field{"samples", "SAMPLES"}
+	fieldKind         = Field{"kind", "KIND"}
+	fieldSilence      = Field{"silence", "SILENCE"}
+	fieldUntil        = Field{"until", "UNTIL"}
+	fieldOwner        = Field{"owner", "OWNER"}
+	fieldComment      = Field{"comment", "COMMENT"}
+)
+
+// returns table of quotas with their values
+func Quotas(quotas []runner.ServiceQuota) *Table {
+	t := NewTable(fieldServiceCode, fieldQuotaCode, fieldValue, fieldDefaultValue, fieldUnit, fieldPeriod,
+		fieldAdjustable, fieldGlobalQuota, fieldServiceName, fieldQuotaName)
+
+	for _, q := range quotas {
+		t.Append(
+			text(q.ServiceCode),
+			text(q.QuotaCode),
+			amount(q.Value, q.Unit, q.Period),
+			amount(q.DefaultValue, q.Unit, q.Period),
+			text(q.Unit),
+			period(q.Period),
+			flag(q.Adjustable),
+			flag(q.GlobalQuota),
+			text(q.ServiceName),
+			text(q.QuotaName),
+		)
+	}
+
+	return t
+}
+
+// returns table of usage of quotas, usage and percent are empty for quotas with error
+func Usage(usage []runner.ServiceQuotaUsage) *Table {
+	t := NewTable(fieldServiceCode, fieldQuotaCode, fieldUsage, fieldValue, fieldPercent, fieldUnit, fieldPeriod,
+		fieldSource, fieldApiUsage, fieldMetricUsage, fieldDiscrepancy, fieldError, fieldServiceName, fieldQuotaName)
+
+	for _, u := range usage {
+		used, percentage, errText := amount(u.Usage, u.Unit, u.Period), percent(u.Usage, u.Value), Cell{}
+		if u.Error != nil {
+			used, percentage, errText = Cell{}, Cell{}, text(u.Error.Error())
+		}
+
+		t.Append(
+			text(u.ServiceCode),
+			text(u.QuotaCode),
+			used,
+			amount(u.Value, u.Unit, u.Period),
+			percentage,
+			text(u.Unit),
+			period(u.Period),
+			text(u.Type),
+			optionalAmount(u.ApiUsage, u.Unit, u.Period),
+			optionalAmount(u.MetricUsage, u.Unit, u.Period),
+			flag(u.Discrepancy),
+			errText,
+			text(u.ServiceName),
+			text(u.QuotaName),
+		)
+	}
+
+	return t
+}
+
+// returns table of warnings of alarms
+func Warnings(warnings []runner.Warning) *Table {
+	t := NewTable(fieldAlarm, fieldSeverity, fieldThreshold, fieldRemainingMin, fieldServiceCode, fieldQuotaCode, fieldUsage,
+		fieldLimit, fieldPercent, fieldRemaining, fieldExhaustion, fieldCondition, fieldUnit, fieldPeriod, fieldServiceName, fieldQuotaName)
+
+	for _, w := range warnings {
+		t.Append(
+			text(w.Name),
+			text(w.Severity.String()),
+			threshold(w.Threshold),
+			optionalAmount(remainingThreshold(w.RemainingThreshold), w.Unit, w.Period),
+			text(w.ServiceCode),
+			text(w.QuotaCode),
+			amount(w.Usage, w.Unit, w.Period),
+			amount(w.Limit, w.Unit, w.Period),
+			percent(w.Usage, w.Limit),
+			amount(w.Remaining, w.Unit, w.Period),
+			exhaustion(w.Forecast),
+			text(w.Condition),
+			text(w.Unit),
+			period(w.Period),
+			text(w.ServiceName),
+			text(w.QuotaName),
+		)
+	}
+
+	return t
+}
+
+// returns table of alarms which are suppressed by silences, until is the end of the silence or its current
+// window, it is empty if the silence never ends
+func Suppressed(warnings []runner.Warning) *Table {
+	t := NewTable(fieldKind, fieldSilence, fieldAlarm, fieldSeverity, fieldServiceCode, fieldQuotaCode, fieldUsage,
+		fieldLimit, fieldPercent, fieldCondition, fieldUntil, fieldOwner, fieldComment, fieldUnit, fieldPeriod, fieldQuotaName)
+
+	now := time.Now()
+	for _, w := range warnings {
+		s := w.Silence
+		if s == nil {
+			continue
+		}
+
+		until := Cell{}
+		if end, ok := s.ActiveAt(now); ok && !end.IsZero() {
+			until = timestamp(end)
+		}
+
+		t.Append(
+			text(string(s.Kind)),
+			text(s.Name),
+			text(w.Name),
+			text(w.Severity.String()),
+			text(w.ServiceCode),
+			text(w.QuotaCode),
+			amount(w.Usage, w.Unit, w.Period),
+			amount(w.Limit, w.Unit, w.Period),
+			percent(w.Usage, w.Limit),
+			text(w.Condition),
+			until,
+			optionalText(s.Owner),
+			optionalText(s.Comment),
+			text(w.Unit),
+			period(w.Period),
+			text(w.QuotaName),
+		)
+	}
+
+	return t
+}
+
+// returns table of forecasts of exhaustion of quotas, quotas without forecast are skipped, growth is per day and
+// days left are counted from now, they are empty if usage doesn't grow
+func Forecasts(results []runner.CheckResult) *Table {
+	t := NewTable(fieldServiceCode, fieldQuotaCode, fieldUsage, fieldValue, fieldPercent, fieldGrowth, fieldExhaustion,
+		fieldDaysLeft, fieldConfidence, fieldSamples, fieldUnit, fieldServiceName, fieldQuotaName)
+
+	now := time.Now()
+	for _, r := range results {
+		f := r.Forecast
+		if f == nil || r.Error != nil {
+			continue
+		}
+
+		left := Cell{}
+		if d, ok := f.Left(now); ok {
+			left = Cell{d.Hours() / 24, units.FormatDuration(d)}
+		}
+
+		t.Append(
+			text(r.ServiceCode),
+			text(r.QuotaCode),
+			amount(r.Usage, r.Unit, r.Period),
+			amount(r.Value, r.Unit, r.Period),
+			percent(r.Usage, r.Value),
+			amount(f.Growth, r.Unit, 24*time.Hour),
+			exhaustion(f),
+			left,
+			Cell{f.Confidence, units.FormatNumber(f.Confidence)},
+			Cell{f.Samples, strconv.Itoa(f.Samples)},
+			text(r.Unit),
+			text(r.ServiceName),
+			text(r.QuotaName),
+		)
+	}
+
+	return t
+}
+
+// returns table of transitions of alerts, from and previous severity are empty if they are not set
+func Transitions(transitions []alerts.Transition) *Table {
+	t := NewTable(fieldState, fieldFrom, fieldAlarm, fieldSeverity, fieldPrevious, fieldServiceCode, fieldQuotaCode,
+		fieldUsage, fieldLimit, fieldPercent, fieldRemaining, fieldCondition, fieldSince, fieldUnit, fieldPeriod, fieldQuotaName)
+
+	for _, tr := range transitions {
+		a := tr.Alert
+		previous := Cell{}
+		if tr.PreviousSeverity != runner.SeverityOK {
+			previous = text(tr.PreviousSeverity.String())
+		}
+
+		t.Append(
+			text(string(a.State)),
+			optionalText(string(tr.From)),
+			text(a.Alarm),
+			text(a.Severity.String()),
+			previous,
+			text(a.ServiceCode),
+			text(a.QuotaCode),
+			amount(a.Usage, a.Unit, a.Period),
+			amount(a.Value, a.Unit, a.Period),
+			percent(a.Usage, a.Value),
+			amount(a.Remaining, a.Unit, a.Period),
+			text(a.Condition),
+			timestamp(a.Since),
+			text(a.Unit),
+			period(a.Period),
+			text(a.QuotaName),
+		)
+	}
+
+	return t
+}
+
+// returns table of unique IAM actions sorted by service and action, actions are grouped by service as in runner.GetIam
+func Iam(actions map[string][]string) *Table {
+	t := NewTable(fieldService, fieldAction)
+
+	services := make([]string, 0, len(actions))
+	for k := range actions {
+		services = append(services, k)
+	}
+	sort.Strings(services)
+
+	for _, service := range services {
+		unique := make(map[string]bool)
+		for _, a := range actions[service] {
+			if a != "" {
+				unique[a] = true
+			}
+		}
+
+		sorted := make([]string, 0, len(unique))
+		for a := range unique {
+			sorted = append(sorted, a)
+		}
+		sort.Strings(sorted)
+
+		for _, a := range sorted {
+			t.Append(text(service), text(a))
+		}
+	}
+
+	return t
+}
+
+// returns cell of the string
+func text(s string) Cell {
+	return Cell{s, s}
+}
+
+// returns cell of the optional string, it is empty for empty string
+func optionalText(s string) Cell {
+	if s == "" {
+		return Cell{}
+	}
+
+	return text(s)
+}
+
+// returns cell of the time in UTC as RFC 3339 string, so it is sorted in order of time
+func timestamp(t time.Time) Cell {
+	return text(t.UTC().Format(time.RFC3339))
+}
+
+// returns cell of the projected exhaustion time, it is empty if there is no forecast or usage doesn't grow
+func exhaustion(f *forecast.Forecast) Cell {
+	if f == nil || f.Exhaustion == nil {
+		return Cell{}
+	}
+
+	return timestamp(*f.Exhaustion)
+}
+
+// returns cell of the boolean
+func flag(b bool) Cell {
+	return Cell{b, strconv.FormatBool(b)}
+}
+
+// returns cell of the value which is formatted with its unit
+func amount(value float64, unit string, p time.Duration) Cell {
+	return Cell{value, units.FormatRate(value, unit, p)}
+}
+
+// returns cell of the optional value, it is empty for nil
+func optionalAmount(value *float64, unit string, p time.Duration) Cell {
+	if value == nil {
+		return Cell{}
+	}
+
+	return amount(*value, unit, p)
+}
+
+// returns cell of usage percent of the limit
+func percent(usage float64, limit float64) Cell {
+	p := units.Percent(usage, limit)
+
+	return Cell{p, units.FormatNumber(p) + "%"}
+}
+
+// returns cell of the percentage threshold of the alarm, it is empty for zero threshold
+func threshold(t int) Cell {
+	if t == 0 {
+		return Cell{}
+	}
+
+	return Cell{t, strconv.Itoa(t) + "%"}
+}
+
+// returns remaining threshold of the alarm, nil for zero threshold
+func remainingThreshold(t float64) *float64 {
+	if t == 0 {
+		return nil
+	}
+
+	return &t
+}
+
+// returns cell of the period of rate-based quota, it is empty for other quotas
+func period(p time.Duration) Cell {
+	if p == 0 {
+		return Cell{}
+	}
+
+	return text(p.String())
+}
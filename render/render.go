@@ -0,0 +1,208 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// names of formats of renderers
+const (
+	FormatJSON     = "json"
+	FormatNDJSON   = "ndjson"
+	FormatCSV      = "csv"
+	FormatTable    = "table"
+	FormatMarkdown = "markdown"
+)
+
+// Renderer writes Table in some format
+type Renderer interface {
+	Render(w io.Writer, t *Table) error
+}
+
+// renderers by format
+var renderers = map[string]Renderer{
+	FormatJSON:     jsonRenderer{},
+	FormatNDJSON:   ndjsonRenderer{},
+	FormatCSV:      csvRenderer{},
+	FormatTable:    tableRenderer{},
+	FormatMarkdown: markdownRenderer{},
+}
+
+// returns renderer for the format
+func New(format string) (Renderer, error) {
+	r, ok := renderers[strings.ToLower(format)]
+	if !ok {
+		return nil, fmt.Errorf("Error while creating renderer: unknown format %q, known formats are %v", format, Formats())
+	}
+
+	return r, nil
+}
+
+// returns sorted names of known formats
+func Formats() []string {
+	formats := make([]string, 0, len(renderers))
+	for k := range renderers {
+		formats = append(formats, k)
+	}
+	sort.Strings(formats)
+
+	return formats
+}
+
+// Field is a column of Table
+type Field struct {
+	// stable name which is used as a key by JSON, NDJSON and CSV renderers, e.g. "quotaCode"
+	Name string
+	// header of the column for table and Markdown renderers, e.g. "QUOTA"
+	Title string
+}
+
+// Cell is a value of the field in the row
+type Cell struct {
+	// raw value which is used by JSON, NDJSON and CSV renderers: string, bool, float64, int or nil
+	Value interface{}
+	// human-readable value which is used by table and Markdown renderers, e.g. "1.5 TiB"
+	Text string
+}
+
+// Table is a list of rows with the same fields
+type Table struct {
+	Fields []Field
+	Rows   [][]Cell
+}
+
+// creates empty Table with the fields
+func NewTable(fields ...Field) *Table {
+	t := Table{}
+	t.Fields = fields
+	t.Rows = make([][]Cell, 0, 0)
+
+	return &t
+}
+
+// adds row of cells in the order of fields
+func (t *Table) Append(cells ...Cell) {
+	t.Rows = append(t.Rows, cells)
+}
+
+// adds field to the beginning of every row with the same cell
+func (t *Table) Prepend(f Field, c Cell) {
+	t.Fields = append([]Field{f}, t.Fields...)
+	for i := range t.Rows {
+		t.Rows[i] = append([]Cell{c}, t.Rows[i]...)
+	}
+}
+
+// adds rows of other Table, fields of the tables must be the same
+func (t *Table) Merge(other *Table) error {
+	if len(t.Fields) != len(other.Fields) {
+		return fmt.Errorf("Error while merging tables: different fields")
+	}
+	for i := range t.Fields {
+		if t.Fields[i].Name != other.Fields[i].Name {
+			return fmt.Errorf("Error while merging tables: different fields")
+		}
+	}
+
+	t.Rows = append(t.Rows, other.Rows...)
+
+	return nil
+}
+
+// sorts rows by fields in the order of keys, key is the name of the field with "-" prefix for descending order,
+// rows with equal values keep their order
+func (t *Table) Sort(keys ...string) error {
+	type sortKey struct {
+		index int
+		desc  bool
+	}
+
+	sortKeys := make([]sortKey, 0, len(keys))
+	for _, key := range keys {
+		k := sortKey{index: -1}
+		name := key
+		if strings.HasPrefix(key, "-") {
+			k.desc = true
+			name = key[1:]
+		}
+		for i, f := range t.Fields {
+			if f.Name == name {
+				k.index = i
+			}
+		}
+		if k.index < 0 {
+			return fmt.Errorf("Error while sorting: unknown field %q, known fields are %v", name, t.fieldNames())
+		}
+		sortKeys = append(sortKeys, k)
+	}
+
+	sort.SliceStable(t.Rows, func(i, j int) bool {
+		for _, k := range sortKeys {
+			c := compare(t.Rows[i][k.index].Value, t.Rows[j][k.index].Value)
+			if c == 0 {
+				continue
+			}
+
+			return (c < 0) != k.desc
+		}
+
+		return false
+	})
+
+	return nil
+}
+
+// returns names of all fields
+func (t *Table) fieldNames() []string {
+	names := make([]string, 0, len(t.Fields))
+	for _, f := range t.Fields {
+		names = append(names, f.Name)
+	}
+
+	return names
+}
+
+// compares values of cells, nil is less than any value, numbers are compared as numbers and other values as text
+func compare(a interface{}, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// returns value as float64 if it is a number
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,302 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/alerts"
+	"github.com/vslchnk/aws_quotas_checker/forecast"
+	"github.com/vslchnk/aws_quotas_checker/runner"
+)
+
+func getTestUsage() []runner.ServiceQuotaUsage {
+	api := 1536.0
+
+	return []runner.ServiceQuotaUsage{
+		{ServiceCode: "ebs", QuotaCode: "L-D18FCD1D", QuotaName: "Storage | gp2", Usage: 1536, Value: 2048, Unit: "Gigabytes", Type: "api", ApiUsage: &api},
+		{ServiceCode: "ec2", QuotaCode: "L-1216C47A", QuotaName: "Running instances", Usage: 5, Value: 5, Unit: "None", Type: "metrics"},
+		{ServiceCode: "ec2", QuotaCode: "L-0E3CBAB9", QuotaName: "Rate of requests", Usage: 10, Value: 20, Unit: "None", Period: time.Second, Type: "metrics"},
+		{ServiceCode: "vpc", QuotaCode: "L-F678F1CE", Error: errors.New("access denied")},
+	}
+}
+
+func TestRenderers(t *testing.T) {
+	table := NewTable(fieldServiceCode, fieldQuotaCode, fieldUsage)
+	table.Append(text("ebs"), text("L-D18FCD1D"), amount(1536, "Gigabytes", 0))
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{FormatJSON, "[\n  {\"serviceCode\":\"ebs\",\"quotaCode\":\"L-D18FCD1D\",\"usage\":1536}\n]\n"},
+		{FormatNDJSON, "{\"serviceCode\":\"ebs\",\"quotaCode\":\"L-D18FCD1D\",\"usage\":1536}\n"},
+		{FormatCSV, "serviceCode,quotaCode,usage\nebs,L-D18FCD1D,1536\n"},
+		{FormatTable, "SERVICE  QUOTA       USAGE\nebs      L-D18FCD1D  1.5 TiB\n"},
+		{FormatMarkdown, "| SERVICE | QUOTA | USAGE |\n| --- | --- | --- |\n| ebs | L-D18FCD1D | 1.5 TiB |\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			r, err := New(tt.format)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			buf := &bytes.Buffer{}
+			if err := r.Render(buf, table); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("output = %q, want %q", buf, tt.want)
+			}
+		})
+	}
+
+	if _, err := New("xml"); err == nil {
+		t.Errorf("expected error for unknown format")
+	}
+}
+
+func TestUsage(t *testing.T) {
+	table := Usage(getTestUsage())
+
+	buf := &bytes.Buffer{}
+	if err := (ndjsonRenderer{}).Render(buf, table); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rows := make([]map[string]interface{}, 0, 0)
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		row := make(map[string]interface{})
+		if err := json.Unmarshal(line, &row); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rows) != 4 {
+		t.Fatalf("rows = %v, want 4", len(rows))
+	}
+	if rows[0]["percent"] != 75.0 || rows[0]["apiUsage"] != 1536.0 || rows[0]["metricUsage"] != nil {
+		t.Errorf("row = %v", rows[0])
+	}
+	if rows[2]["period"] != "1s" || rows[1]["period"] != nil {
+		t.Errorf("rows = %v", rows)
+	}
+	if rows[3]["usage"] != nil || rows[3]["percent"] != nil || rows[3]["error"] != "access denied" {
+		t.Errorf("row = %v", rows[3])
+	}
+
+	buf.Reset()
+	if err := (markdownRenderer{}).Render(buf, table); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("Storage \\| gp2")) || !bytes.Contains(buf.Bytes(), []byte("10/s")) {
+		t.Errorf("output = %v", buf)
+	}
+}
+
+func TestSort(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    []string
+		want    []string
+		wantErr bool
+	}{
+		{"no keys", nil, []string{"L-D18FCD1D", "L-1216C47A", "L-0E3CBAB9", "L-F678F1CE"}, false},
+		{"descending percent", []string{"-percent"}, []string{"L-1216C47A", "L-D18FCD1D", "L-0E3CBAB9", "L-F678F1CE"}, false},
+		{"service then quota", []string{"serviceCode", "quotaCode"}, []string{"L-D18FCD1D", "L-0E3CBAB9", "L-1216C47A", "L-F678F1CE"}, false},
+		{"unknown field", []string{"name"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := Usage(getTestUsage())
+			err := table.Sort(tt.keys...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			codes := make([]string, 0, len(table.Rows))
+			for _, row := range table.Rows {
+				codes = append(codes, row[1].Text)
+			}
+			if !reflect.DeepEqual(codes, tt.want) {
+				t.Errorf("codes = %v, want %v", codes, tt.want)
+			}
+		})
+	}
+}
+
+func TestIam(t *testing.T) {
+	table := Iam(map[string][]string{
+		"vpc":    {"ec2:DescribeVpcs", "", "ec2:DescribeVpcs"},
+		"quotas": {"servicequotas:ListServiceQuotas"},
+	})
+
+	buf := &bytes.Buffer{}
+	if err := (csvRenderer{}).Render(buf, table); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "service,action\nquotas,servicequotas:ListServiceQuotas\nvpc,ec2:DescribeVpcs\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf, want)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	table := Iam(map[string][]string{"vpc": {"ec2:DescribeVpcs"}})
+	if err := table.Merge(Iam(map[string][]string{"ebs": {"ec2:DescribeVolumes"}})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(table.Rows) != 2 {
+		t.Errorf("rows = %v, want 2", table.Rows)
+	}
+
+	table.Prepend(Field{"region", "REGION"}, Cell{"us-east-1", "us-east-1"})
+	if table.Fields[0].Name != "region" || table.Rows[1][0].Text != "us-east-1" || table.Rows[1][2].Text != "ec2:DescribeVolumes" {
+		t.Errorf("table = %+v", table)
+	}
+
+	if err := table.Merge(Quotas(nil)); err == nil {
+		t.Errorf("expected error for different fields")
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	usage := getTestUsage()
+	results := []runner.CheckResult{
+		{ServiceQuotaUsage: usage[0], Percent: 75, Severity: runner.SeverityWarning, Alarm: "warning", Threshold: 70, Condition: "usage 75% >= 70%"},
+		{ServiceQuotaUsage: usage[1], Percent: 100, Severity: runner.SeverityCritical, Alarm: "critical", Threshold: 90},
+		{ServiceQuotaUsage: usage[2], Percent: 50},
+		{ServiceQuotaUsage: usage[3]},
+		{ServiceQuotaUsage: usage[2], Percent: 50, Severity: runner.SeverityWarning, Alarm: "warning", Threshold: 40, Condition: "usage 50% >= 40%", Silence: &runner.Silence{Name: "migration", Kind: runner.KindSilence}},
+	}
+
+	buf := &bytes.Buffer{}
+	err := WriteJUnit(buf, []JUnitSuite{
+		{Name: "prod us-east-1", Results: results},
+		{Name: "prod eu-west-1", Error: errors.New("expired token")},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	report := struct {
+		Tests    int `xml:"tests,attr"`
+		Failures int `xml:"failures,attr"`
+		Errors   int `xml:"errors,attr"`
+		Skipped  int `xml:"skipped,attr"`
+		Suites   []struct {
+			Name  string `xml:"name,attr"`
+			Cases []struct {
+				ClassName string `xml:"classname,attr"`
+				Name      string `xml:"name,attr"`
+				Failure   *struct {
+					Type    string `xml:"type,attr"`
+					Message string `xml:"message,attr"`
+				} `xml:"failure"`
+				Skipped *struct {
+					Message string `xml:"message,attr"`
+				} `xml:"skipped"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}{}
+	if err := xml.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if report.Tests != 6 || report.Failures != 2 || report.Errors != 2 || report.Skipped != 1 || len(report.Suites) != 2 {
+		t.Fatalf("report = %+v, want 6 tests, 2 failures, 2 errors and 1 skipped in 2 suites", report)
+	}
+
+	c := report.Suites[0].Cases[0]
+	if c.ClassName != "ebs" || c.Name != "L-D18FCD1D Storage | gp2" || c.Failure == nil || c.Failure.Type != "warning" {
+		t.Errorf("test case = %+v", c)
+	}
+	if c.Failure != nil && c.Failure.Message != "usage 1.5 TiB of 2 TiB (75%), alarm warning: usage 75% >= 70%" {
+		t.Errorf("message = %v", c.Failure.Message)
+	}
+	if report.Suites[0].Cases[2].Failure != nil {
+		t.Errorf("test case = %+v, want no failure", report.Suites[0].Cases[2])
+	}
+	if c := report.Suites[0].Cases[4]; c.Failure != nil || c.Skipped == nil || !strings.HasSuffix(c.Skipped.Message, "suppressed by silence migration") {
+		t.Errorf("test case = %+v, want skipped suppressed alarm", c)
+	}
+}
+
+func TestSuppressed(t *testing.T) {
+	end := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	table := Suppressed([]runner.Warning{
+		{ServiceCode: "vpc", QuotaCode: "L-F678F1CE", Usage: 4, Limit: 5, Name: "warning", Severity: runner.SeverityWarning,
+			Silence: &runner.Silence{Name: "migration", Kind: runner.KindAcknowledgement, End: end, Owner: "network", Comment: "VPC migration"}},
+		{ServiceCode: "ec2", QuotaCode: "L-1216C47A", Usage: 95, Limit: 100, Name: "page", Severity: runner.SeverityCritical,
+			Silence: &runner.Silence{Name: "forever", Kind: runner.KindSilence}},
+		{ServiceCode: "ec2", QuotaCode: "L-0263D0A3", Usage: 5, Limit: 5, Name: "page", Severity: runner.SeverityCritical},
+	})
+
+	buf := &bytes.Buffer{}
+	if err := (csvRenderer{}).Render(buf, table); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "kind,silence,alarm,severity,serviceCode,quotaCode,usage,limit,percent,condition,until,owner,comment,unit,period,quotaName\n" +
+		"acknowledgement,migration,warning,warning,vpc,L-F678F1CE,4,5,80,," + end.Format(time.RFC3339) + ",network,VPC migration,,,\n" +
+		"silence,forever,page,critical,ec2,L-1216C47A,95,100,95,,,,,,,\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf, want)
+	}
+}
+
+func TestTransitions(t *testing.T) {
+	since := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	table := Transitions([]alerts.Transition{
+		{Alert: alerts.Alert{State: alerts.StateFiring, Alarm: "page", Severity: runner.SeverityCritical, ServiceCode: "ec2", QuotaCode: "L-1216C47A", Usage: 95, Value: 100, Remaining: 5, Since: since}, From: alerts.StateFiring, PreviousSeverity: runner.SeverityWarning},
+		{Alert: alerts.Alert{State: alerts.StateFiring, Alarm: "warning", Severity: runner.SeverityWarning, ServiceCode: "vpc", QuotaCode: "L-F678F1CE", Usage: 4, Value: 5, Remaining: 1, Since: since}},
+	})
+
+	buf := &bytes.Buffer{}
+	if err := (csvRenderer{}).Render(buf, table); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "state,from,alarm,severity,previousSeverity,serviceCode,quotaCode,usage,limit,percent,remaining,condition,since,unit,period,quotaName\n" +
+		"firing,firing,page,critical,warning,ec2,L-1216C47A,95,100,95,5,,2024-01-01T12:00:00Z,,,\n" +
+		"firing,,warning,warning,,vpc,L-F678F1CE,4,5,80,1,,2024-01-01T12:00:00Z,,,\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf, want)
+	}
+}
+
+func TestForecasts(t *testing.T) {
+	usage := getTestUsage()
+	exhaustion := time.Now().Add(36 * time.Hour).UTC()
+	table := Forecasts([]runner.CheckResult{
+		{ServiceQuotaUsage: usage[1], Forecast: &forecast.Forecast{Growth: -1, Confidence: 0.5, Samples: 3}},
+		{ServiceQuotaUsage: usage[0], Forecast: &forecast.Forecast{Growth: 341.33, Exhaustion: &exhaustion, Confidence: 0.987, Samples: 10}},
+		{ServiceQuotaUsage: usage[2]},
+	})
+	if err := table.Sort("daysLeft"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := (tableRenderer{}).Render(buf, table); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "ec2") || !strings.Contains(lines[2], "341.33 GiB/day") || !strings.Contains(lines[2], "1.5d") || !strings.Contains(lines[2], "0.99") {
+		t.Errorf("output = %v", buf)
+	}
+}
@@ -0,0 +1,56 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vslchnk/aws_quotas_checker/errs"
+	"github.com/vslchnk/aws_quotas_checker/quotas"
+	"github.com/vslchnk/aws_quotas_checker/ratelimit"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// returns ID of the account which credentials of the session belong to
+func getAccount(ctx context.Context, sess *session.Session) (string, error) {
+	client := sts.New(sess)
+	ratelimit.Install(&client.Handlers, "sts")
+
+	res, err := client.GetCallerIdentityWithContext(ctx, nil)
+	if err != nil {
+		return "", errs.Classify(fmt.Errorf("Error while getting account: %w", err))
+	}
+
+	return aws.StringValue(res.Account), nil
+}
+
+// creates cache of the catalog of services and quotas for account, partition and region of the session
+func (r *Runner) newCatalogCache(ctx context.Context) (*quotas.Cache, error) {
+	o := r.options.CatalogCache
+
+	dir := o.Dir
+	if dir == "" {
+		var err error
+		dir, err = quotas.DefaultCacheDir()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	key := quotas.CacheKey{}
+	key.Account = r.account
+	key.Region = aws.StringValue(r.session.Config.Region)
+	key.Partition = endpoints.AwsPartitionID
+	if p, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), key.Region); ok {
+		key.Partition = p.ID()
+	}
+
+	if key.Account == "" {
+		return nil, fmt.Errorf("Error while creating catalog cache: account is unknown")
+	}
+
+	return quotas.NewCache(dir, key, o.TTL, o.Refresh), nil
+}
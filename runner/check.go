@@ -0,0 +1,431 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/expr"
+	"github.com/vslchnk/aws_quotas_checker/forecast"
+	"github.com/vslchnk/aws_quotas_checker/selector"
+	"github.com/vslchnk/aws_quotas_checker/units"
+)
+
+// Severity is importance of the alarm, bigger one is more important
+type Severity int
+
+// severities of alarms, SeverityOK means that no alarm is raised
+const (
+	SeverityOK Severity = iota
+	SeverityInfo
+	SeverityWarning
+	SeverityCritical
+)
+
+// names of severities
+var severityNames = map[Severity]string{
+	SeverityOK:       "ok",
+	SeverityInfo:     "info",
+	SeverityWarning:  "warning",
+	SeverityCritical: "critical",
+}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// returns severity by name: ok, info, warning or critical
+func ParseSeverity(name string) (Severity, error) {
+	for k, v := range severityNames {
+		if v == strings.ToLower(name) {
+			return k, nil
+		}
+	}
+
+	return SeverityOK, fmt.Errorf("Error while parsing severity: unknown severity %q, known severities are ok, info, warning and critical", name)
+}
+
+// severity is written by name in JSON and other text formats
+func (s Severity) MarshalText() ([]byte, error) {
+	if _, ok := severityNames[s]; !ok {
+		return nil, fmt.Errorf("Error while encoding severity: unknown severity %d", int(s))
+	}
+
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(data []byte) error {
+	var err error
+	*s, err = ParseSeverity(string(data))
+
+	return err
+}
+
+// Combination defines how percentage and remaining conditions of the alarm rule are combined
+type Combination string
+
+// combinations of conditions
+const (
+	// alarm is raised if any condition is met
+	CombineAny Combination = "any"
+	// alarm is raised if all conditions are met
+	CombineAll Combination = "all"
+)
+
+// AlarmRule raises the alarm when usage of matching quotas reaches threshold percents of their value, when
+// fewer than remaining units of their value are left, when usage is forecast to reach their value soon or
+// when the expression is true, rule with empty scope and tag matches all quotas
+type AlarmRule struct {
+	Name string
+	// percents of the quota value, zero means no percentage condition
+	Threshold int
+	// capacity of the quota value in its unit, zero means no remaining condition, the alarm is disabled
+	// for matching quotas if threshold, remaining, exhaustion and expression are not set
+	Remaining float64
+	// time which usage is forecast to reach the quota value within, zero means no exhaustion condition
+	Exhaustion time.Duration
+	// forecasts with lower confidence from 0 to 1 don't meet exhaustion condition
+	MinConfidence float64
+	// boolean expression over the quota, see ParseExpression, empty string means no expression condition,
+	// expressions which can't be evaluated, e.g. because of unknown growth, are not met
+	Expression string
+	expression *expr.Expr
+	// CombineAny is used if it is not set
+	Combine Combination
+	// SeverityWarning is used if it is not set
+	Severity Severity
+	// globs of service and quota codes and regular expression of quota name of matching quotas
+	Scope selector.Rule
+	// tag of the applied quota as "key" or "key=value", quotas which have only default value have no tags
+	Tag string
+	// time which conditions must be met for before the alert of the alarm is firing, see alerts package
+	For time.Duration
+	// percents and capacity which clear the firing alert, they add hysteresis so that usage around thresholds
+	// doesn't flap, zero values mean that threshold and remaining of the rule are used
+	ClearThreshold int
+	ClearRemaining float64
+}
+
+// returns specificity of the rule, rule with quota code is the most specific one, then rules with tag,
+// quota name and service code follow, rules with several fields are more specific than rules with one of them
+func (a AlarmRule) specificity() int {
+	specificity := 0
+	for i, field := range []string{a.Scope.Service, a.Scope.Name, a.Tag, a.Scope.Quota} {
+		if field != "" {
+			specificity += 1 << i
+		}
+	}
+
+	return specificity
+}
+
+// checks conditions of the rule for the usage and its forecast at the moment and returns description of met ones,
+// e.g. "usage 85% >= 80%", forecast is nil if there are not enough samples, expression is evaluated with env
+func (a AlarmRule) check(squ ServiceQuotaUsage, percent float64, f *forecast.Forecast, now time.Time, env expr.Env) (string, bool) {
+	conditions, ok := a.conditions(squ, percent, f, now, env, false)
+	if !ok {
+		return "", false
+	}
+
+	return strings.Join(conditions, " and "), true
+}
+
+// checks if conditions of the rule are still met with clear thresholds, so that firing alert of the alarm is kept,
+// exhaustion and expression conditions have no clear thresholds and are checked as they are
+func (a AlarmRule) holds(squ ServiceQuotaUsage, percent float64, f *forecast.Forecast, now time.Time, env expr.Env) bool {
+	_, ok := a.conditions(squ, percent, f, now, env, true)
+
+	return ok
+}
+
+// returns descriptions of met conditions of the rule and checks if they are combined into the alarm,
+// percentage and remaining conditions are checked with clear thresholds if clear is set
+func (a AlarmRule) conditions(squ ServiceQuotaUsage, percent float64, f *forecast.Forecast, now time.Time, env expr.Env, clear bool) ([]string, bool) {
+	if squ.Value <= 0 {
+		return nil, false
+	}
+
+	threshold, remainingThreshold := a.Threshold, a.Remaining
+	if clear {
+		threshold, remainingThreshold = a.clearThreshold(), a.clearRemaining()
+	}
+
+	conditions := make([]string, 0, 4)
+	total := 0
+
+	if a.Threshold > 0 {
+		total++
+		if percent >= float64(threshold) {
+			conditions = append(conditions, fmt.Sprintf("usage %v%% >= %v%%", units.FormatNumber(percent), threshold))
+		}
+	}
+	if a.Remaining > 0 {
+		total++
+		if remaining := squ.Value - squ.Usage; remaining < remainingThreshold {
+			conditions = append(conditions, fmt.Sprintf("remaining %v < %v",
+				units.FormatRate(remaining, squ.Unit, squ.Period), units.FormatRate(remainingThreshold, squ.Unit, squ.Period)))
+		}
+	}
+	if a.Exhaustion > 0 {
+		total++
+		if f != nil && f.Confidence >= a.MinConfidence {
+			if left, ok := f.Left(now); ok && left < a.Exhaustion {
+				conditions = append(conditions, fmt.Sprintf("exhaustion in %v < %v", units.FormatDuration(left), units.FormatDuration(a.Exhaustion)))
+			}
+		}
+	}
+	if a.expression != nil {
+		total++
+		if ok, err := a.expression.EvalBool(env); err == nil && ok {
+			conditions = append(conditions, a.Expression)
+		}
+	}
+
+	if len(conditions) == 0 || (a.Combine == CombineAll && len(conditions) < total) {
+		return nil, false
+	}
+
+	return conditions, true
+}
+
+// returns percents which clear the alert of the rule
+func (a AlarmRule) clearThreshold() int {
+	if a.ClearThreshold > 0 {
+		return a.ClearThreshold
+	}
+
+	return a.Threshold
+}
+
+// returns capacity which clears the alert of the rule
+func (a AlarmRule) clearRemaining() float64 {
+	if a.ClearRemaining > 0 {
+		return a.ClearRemaining
+	}
+
+	return a.Remaining
+}
+
+// checks if tags contain the tag of the rule
+func (a AlarmRule) matchTags(tags map[string]string) bool {
+	key, value, hasValue := strings.Cut(a.Tag, "=")
+	v, ok := tags[key]
+
+	return ok && (!hasValue || v == value)
+}
+
+// CheckResult is result of checking alarms for the quota
+type CheckResult struct {
+	ServiceQuotaUsage
+	// usage percent of the quota value
+	Percent float64
+	// capacity which is left, value minus usage
+	Remaining float64
+	// severity of the raised alarm, SeverityOK if no alarm is raised or usage is not found
+	Severity Severity
+	// forecast of exhaustion from the usage history, nil if there are not enough samples
+	Forecast *forecast.Forecast
+	// name, thresholds and met conditions of the raised alarm, empty if no alarm is raised
+	Alarm               string
+	Threshold           int
+	RemainingThreshold  float64
+	ExhaustionThreshold time.Duration
+	Condition           string
+	// combination of conditions, for-duration and clear thresholds of the raised alarm, clear thresholds
+	// are the same as thresholds if the rule has no hysteresis
+	Combine        Combination
+	For            time.Duration
+	ClearThreshold int
+	ClearRemaining float64
+	// sorted names of matching alarms which conditions are met with clear thresholds, firing alerts of these
+	// alarms are kept by alerts package, raised alarm is one of them
+	Holding []string
+	// silence which suppresses the raised alarm, nil if the alarm is not suppressed
+	Silence *Silence
+}
+
+// adds alarm for all quotas with severity, AddAlarm adds alarms with SeverityWarning
+func (r *Runner) AddAlarmWithSeverity(name string, threshold int, severity Severity) {
+	r.alarmRules = append(r.alarmRules, AlarmRule{Name: name, Threshold: threshold, Severity: severity})
+}
+
+// adds alarm rule, for every quota the most specific matching rule of every alarm name is applied,
+// the rule added later is applied if matching rules are equally specific
+func (r *Runner) AddAlarmRule(rule AlarmRule) error {
+	if rule.Name == "" {
+		return fmt.Errorf("Error while adding alarm rule: name is empty")
+	}
+	if rule.Threshold < 0 {
+		return fmt.Errorf("Error while adding alarm rule %v: threshold %v is negative", rule.Name, rule.Threshold)
+	}
+	if rule.Remaining < 0 {
+		return fmt.Errorf("Error while adding alarm rule %v: remaining %v is negative", rule.Name, rule.Remaining)
+	}
+	if rule.Exhaustion < 0 {
+		return fmt.Errorf("Error while adding alarm rule %v: exhaustion %v is negative", rule.Name, rule.Exhaustion)
+	}
+	if rule.MinConfidence < 0 || rule.MinConfidence > 1 {
+		return fmt.Errorf("Error while adding alarm rule %v: minimum confidence %v is not in range from 0 to 1", rule.Name, rule.MinConfidence)
+	}
+	if rule.Combine != "" && rule.Combine != CombineAny && rule.Combine != CombineAll {
+		return fmt.Errorf("Error while adding alarm rule %v: unknown combination %q", rule.Name, rule.Combine)
+	}
+	if rule.For < 0 {
+		return fmt.Errorf("Error while adding alarm rule %v: for-duration %v is negative", rule.Name, rule.For)
+	}
+	if rule.ClearThreshold < 0 || rule.ClearThreshold > rule.Threshold {
+		return fmt.Errorf("Error while adding alarm rule %v: clear threshold %v is not between 0 and threshold %v", rule.Name, rule.ClearThreshold, rule.Threshold)
+	}
+	if rule.ClearRemaining < 0 || (rule.ClearRemaining > 0 && rule.ClearRemaining < rule.Remaining) || (rule.ClearRemaining > 0 && rule.Remaining == 0) {
+		return fmt.Errorf("Error while adding alarm rule %v: clear remaining %v is negative, less than remaining %v or remaining is not set", rule.Name, rule.ClearRemaining, rule.Remaining)
+	}
+	if strings.HasPrefix(rule.Tag, "=") {
+		return fmt.Errorf("Error while adding alarm rule %v: key of tag %q is empty", rule.Name, rule.Tag)
+	}
+	if err := rule.Scope.Compile(); err != nil {
+		return fmt.Errorf("Error while adding alarm rule %v: %w", rule.Name, err)
+	}
+	if rule.Expression != "" {
+		e, err := ParseExpression(rule.Expression)
+		if err != nil {
+			return fmt.Errorf("Error while adding alarm rule %v: %w", rule.Name, err)
+		}
+		rule.expression = e
+	}
+
+	if rule.Severity == SeverityOK {
+		rule.Severity = SeverityWarning
+	}
+	if rule.Combine == "" {
+		rule.Combine = CombineAny
+	}
+	r.alarmRules = append(r.alarmRules, rule)
+
+	return nil
+}
+
+// checks alarms for every quota which usage is found in any source or fails
+func (r *Runner) CheckQuotas() []CheckResult {
+	return r.CheckQuotasWithContext(context.Background())
+}
+
+// checks alarms for every quota which usage is found in any source or fails, when several alarms are reached
+// the most severe one with the biggest threshold is raised, then the first one by name, quotas which usage
+// can't be found have Error set, forecasts are made from the usage history and tags of quotas are requested
+// with the context only for rules with tags, raised alarms keep the silence which suppresses them
+func (r *Runner) CheckQuotasWithContext(ctx context.Context) []CheckResult {
+	squs := r.GetQuotasUsage()
+	results := make([]CheckResult, 0, len(squs))
+	now := time.Now()
+
+	for _, squ := range squs {
+		result := CheckResult{ServiceQuotaUsage: squ}
+		if squ.Error != nil {
+			results = append(results, result)
+			continue
+		}
+
+		result.Percent = units.Percent(squ.Usage, squ.Value)
+		result.Remaining = squ.Value - squ.Usage
+		result.Forecast, _ = r.history.Forecast(squ.ServiceCode, squ.QuotaCode, squ.Value)
+
+		env := r.expressionEnv(ctx, result, now)
+		rules := r.getAlarmRules(ctx, squ)
+		names := make([]string, 0, len(rules))
+		for k := range rules {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			rule := rules[name]
+			if rule.holds(squ, result.Percent, result.Forecast, now, env) {
+				result.Holding = append(result.Holding, rule.Name)
+			}
+			condition, ok := rule.check(squ, result.Percent, result.Forecast, now, env)
+			if !ok {
+				continue
+			}
+			if rule.Severity > result.Severity || (rule.Severity == result.Severity && rule.Threshold > result.Threshold) {
+				result.Severity = rule.Severity
+				result.Alarm = rule.Name
+				result.Threshold = rule.Threshold
+				result.RemainingThreshold = rule.Remaining
+				result.ExhaustionThreshold = rule.Exhaustion
+				result.Condition = condition
+				result.Combine = rule.Combine
+				result.For = rule.For
+				result.ClearThreshold = rule.clearThreshold()
+				result.ClearRemaining = rule.clearRemaining()
+			}
+		}
+		result.Silence = r.getSilence(result, now)
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// returns the most specific matching rule of every alarm name for the quota
+func (r *Runner) getAlarmRules(ctx context.Context, squ ServiceQuotaUsage) map[string]AlarmRule {
+	q := selector.Quota{ServiceCode: squ.ServiceCode, QuotaCode: squ.QuotaCode, QuotaName: squ.QuotaName}
+	rules := make(map[string]AlarmRule)
+
+	for _, rule := range r.alarmRules {
+		if current, ok := rules[rule.Name]; ok && current.specificity() > rule.specificity() {
+			continue
+		}
+		if !rule.Scope.Match(q) {
+			continue
+		}
+		if rule.Tag != "" {
+			tags, ok := r.getQuotaTags(ctx, squ.ServiceCode, squ.QuotaCode)
+			if !ok || !rule.matchTags(tags) {
+				continue
+			}
+		}
+		rules[rule.Name] = rule
+	}
+
+	return rules
+}
+
+// returns tags of the quota, they are requested once after every update of usage, failure is kept in errors
+func (r *Runner) getQuotaTags(ctx context.Context, serviceCode string, quotaCode string) (map[string]string, bool) {
+	if tags, ok := (*r.quotaTags)[quotaCode]; ok {
+		return tags, true
+	}
+	if _, ok := (*r.quotaTagsErrors)[quotaCode]; ok {
+		return nil, false
+	}
+
+	ctx, cancel := r.withCallTimeout(ctx)
+	defer cancel()
+
+	tags, err := r.quotas.GetQuotaTagsWithContext(ctx, serviceCode, quotaCode)
+	if err != nil {
+		(*r.quotaTagsErrors)[quotaCode] = QuotaError{ServiceCode: serviceCode, QuotaCode: quotaCode, Source: "tags", Err: err}
+		return nil, false
+	}
+	(*r.quotaTags)[quotaCode] = tags
+
+	return tags, true
+}
+
+// returns the most severe result, SeverityOK if no alarm is raised, suppressed alarms are skipped
+func MaxSeverity(results []CheckResult) Severity {
+	max := SeverityOK
+	for _, result := range results {
+		if result.Severity > max && result.Silence == nil {
+			max = result.Severity
+		}
+	}
+
+	return max
+}
@@ -0,0 +1,300 @@
+package runner
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/errs"
+	"github.com/vslchnk/aws_quotas_checker/fakes"
+	"github.com/vslchnk/aws_quotas_checker/selector"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+)
+
+func TestCheckQuotas(t *testing.T) {
+	r := newTestRunner(t, newTestClients())
+	r.AddAlarmWithSeverity("page", 50, SeverityCritical)
+	r.AddAlarm("warning", 80)
+
+	tests := []struct {
+		quotaCode string
+		severity  Severity
+		alarm     string
+		threshold int
+	}{
+		// critical alarm is raised instead of warning one with bigger threshold
+		{"L-1", SeverityCritical, "page", 50},
+		{"L-3", SeverityCritical, "page", 50},
+	}
+
+	results := r.CheckQuotas()
+	if len(results) != len(r.GetQuotasUsage()) {
+		t.Errorf("results = %+v, want result for every quota", results)
+	}
+
+	for _, tt := range tests {
+		found := false
+		for _, result := range results {
+			if result.QuotaCode != tt.quotaCode {
+				continue
+			}
+			found = true
+			if result.Severity != tt.severity || result.Alarm != tt.alarm || result.Threshold != tt.threshold {
+				t.Errorf("result = %+v, want %v alarm %v", result, tt.severity, tt.alarm)
+			}
+		}
+		if !found {
+			t.Errorf("results = %+v, want result for %v", results, tt.quotaCode)
+		}
+	}
+
+	if s := MaxSeverity(results); s != SeverityCritical {
+		t.Errorf("severity = %v, want %v", s, SeverityCritical)
+	}
+	if s := MaxSeverity(nil); s != SeverityOK {
+		t.Errorf("severity = %v, want %v", s, SeverityOK)
+	}
+
+	warnings := r.CheckAlarms()
+	if len(warnings) != len(tests) || warnings[0].Severity != SeverityCritical {
+		t.Errorf("warnings = %+v, want %v critical warnings", warnings, len(tests))
+	}
+}
+
+func TestAlarmRules(t *testing.T) {
+	// usage of L-1 is 80%, L-3 is 70% and L-4 is 10%, only L-1 is applied and tagged
+	tests := []struct {
+		name  string
+		rules []AlarmRule
+		want  map[string]string
+	}{
+		{
+			"global",
+			[]AlarmRule{{Name: "warning", Threshold: 50}},
+			map[string]string{"L-1": "warning 50", "L-3": "warning 50"},
+		},
+		{
+			"service overrides global",
+			[]AlarmRule{{Name: "warning", Threshold: 5}, {Name: "warning", Threshold: 90, Scope: selector.Rule{Service: "ec2"}}},
+			map[string]string{"L-4": "warning 5"},
+		},
+		{
+			"quota overrides service",
+			[]AlarmRule{{Name: "warning", Threshold: 60, Scope: selector.Rule{Quota: "L-3"}}, {Name: "warning", Threshold: 90, Scope: selector.Rule{Service: "ec2"}}},
+			map[string]string{"L-3": "warning 60"},
+		},
+		{
+			"zero threshold disables alarm",
+			[]AlarmRule{{Name: "warning", Threshold: 5, Scope: selector.Rule{Name: "L-[0-9]"}}, {Name: "warning", Scope: selector.Rule{Service: "vpc", Name: "L-[0-9]"}}},
+			map[string]string{"L-1": "warning 5", "L-3": "warning 5"},
+		},
+		{
+			"tag overrides name",
+			[]AlarmRule{{Name: "warning", Threshold: 5, Scope: selector.Rule{Name: "L-1"}}, {Name: "warning", Threshold: 90, Tag: "team=network"}},
+			map[string]string{},
+		},
+		{
+			"tag with other value",
+			[]AlarmRule{{Name: "warning", Threshold: 5, Scope: selector.Rule{Name: "L-1"}}, {Name: "warning", Threshold: 90, Tag: "team=compute"}},
+			map[string]string{"L-1": "warning 5"},
+		},
+		{
+			"tag key",
+			[]AlarmRule{{Name: "page", Threshold: 75, Severity: SeverityCritical, Tag: "team"}, {Name: "warning", Threshold: 50}},
+			map[string]string{"L-1": "page 75", "L-3": "warning 50"},
+		},
+		{
+			"later rule overrides equally specific one",
+			[]AlarmRule{{Name: "warning", Threshold: 50}, {Name: "warning", Threshold: 75}},
+			map[string]string{"L-1": "warning 75"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClients()
+			c.quotas.AppliedQuotas = map[string]*servicequotas.ServiceQuota{"L-1": fakes.NewServiceQuota("ec2", "L-1", 5)}
+			c.quotas.Tags = map[string]map[string]string{fakes.QuotaArn("ec2", "L-1"): {"team": "network"}}
+
+			r := newTestRunner(t, c)
+			for _, rule := range tt.rules {
+				if err := r.AddAlarmRule(rule); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			got := make(map[string]string)
+			for _, w := range r.CheckAlarms() {
+				got[w.QuotaCode] = w.Name + " " + strconv.Itoa(w.Threshold)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("alarms = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemainingAlarms(t *testing.T) {
+	// L-1 has 1 of 5 left, L-3 has 3 of 10 left and L-4 has 9 of 10 left
+	tests := []struct {
+		name string
+		rule AlarmRule
+		want map[string]string
+	}{
+		{
+			"remaining",
+			AlarmRule{Name: "warning", Remaining: 2},
+			map[string]string{"L-1": "remaining 1 < 2"},
+		},
+		{
+			"any condition",
+			AlarmRule{Name: "warning", Threshold: 75, Remaining: 4},
+			map[string]string{"L-1": "usage 80% >= 75% and remaining 1 < 4", "L-3": "remaining 3 < 4"},
+		},
+		{
+			"all conditions",
+			AlarmRule{Name: "warning", Threshold: 75, Remaining: 4, Combine: CombineAll},
+			map[string]string{"L-1": "usage 80% >= 75% and remaining 1 < 4"},
+		},
+		{
+			"percentage",
+			AlarmRule{Name: "warning", Threshold: 10},
+			map[string]string{"L-1": "usage 80% >= 10%", "L-3": "usage 70% >= 10%", "L-4": "usage 10% >= 10%"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRunner(t, newTestClients())
+			if err := r.AddAlarmRule(tt.rule); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := make(map[string]string)
+			for _, w := range r.CheckAlarms() {
+				got[w.QuotaCode] = w.Condition
+				if w.RemainingThreshold != tt.rule.Remaining || w.Remaining != w.Limit-w.Usage {
+					t.Errorf("warning = %+v, want remaining threshold %v", w, tt.rule.Remaining)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("conditions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClearThresholds(t *testing.T) {
+	r := newTestRunner(t, newTestClients())
+	rules := []AlarmRule{
+		{Name: "info", Threshold: 60, Severity: SeverityInfo},
+		{Name: "warning", Threshold: 75, ClearThreshold: 65, For: time.Minute, Scope: selector.Rule{Quota: "L-1"}},
+		{Name: "ec2", Threshold: 65, ClearThreshold: 50, Expression: "service == 'ec2' and quota != 'L-3'", Combine: CombineAll, Severity: SeverityInfo},
+	}
+	for _, rule := range rules {
+		if err := r.AddAlarmRule(rule); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	got := make(map[string]CheckResult)
+	for _, result := range r.CheckQuotas() {
+		got[result.QuotaCode] = result
+	}
+
+	// L-1 is 80% used, L-3 is 70% used
+	if l1 := got["L-1"]; l1.Alarm != "warning" || l1.ClearThreshold != 65 || l1.For != time.Minute || l1.Combine != CombineAny {
+		t.Errorf("result = %+v, want warning cleared at 65%%", l1)
+	}
+	if l3 := got["L-3"]; l3.Severity != SeverityInfo || l3.ClearThreshold != 60 {
+		t.Errorf("result = %+v, want info cleared at threshold", l3)
+	}
+
+	// conditions without clear thresholds must be met for alarms which combine all conditions
+	want := map[string][]string{"L-1": {"ec2", "info", "warning"}, "L-3": {"info"}, "L-4": nil}
+	for k, v := range want {
+		if !reflect.DeepEqual(got[k].Holding, v) {
+			t.Errorf("%v: holding = %v, want %v", k, got[k].Holding, v)
+		}
+	}
+}
+
+func TestAlarmRuleTagsError(t *testing.T) {
+	c := newTestClients()
+	c.quotas.AppliedQuotas = map[string]*servicequotas.ServiceQuota{"L-1": fakes.NewServiceQuota("ec2", "L-1", 5)}
+	c.quotas.TagsErrors = map[string]error{fakes.QuotaArn("ec2", "L-1"): awserr.New("AccessDeniedException", "denied", nil)}
+
+	r := newTestRunner(t, c)
+	if err := r.AddAlarmRule(AlarmRule{Name: "warning", Threshold: 50, Tag: "team"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if warnings := r.CheckAlarms(); len(warnings) != 0 {
+		t.Errorf("warnings = %+v, want no warnings", warnings)
+	}
+	r.CheckAlarms()
+	if calls := c.quotas.Calls["ListTagsForResource"]; calls != 1 {
+		t.Errorf("calls = %v, want tags to be requested once", calls)
+	}
+
+	found := false
+	for _, e := range r.GetErrors() {
+		if e.QuotaCode == "L-1" && e.Source == "tags" {
+			found = true
+			if !errors.Is(e, errs.ErrAccessDenied) {
+				t.Errorf("error = %v, want %v", e, errs.ErrAccessDenied)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("errors = %v, want error of tags", r.GetErrors())
+	}
+
+	invalid := []AlarmRule{
+		{},
+		{Name: "a", Threshold: -1},
+		{Name: "a", Remaining: -1},
+		{Name: "a", Threshold: 1, Combine: "both"},
+		{Name: "a", Tag: "=x"},
+		{Name: "a", Threshold: 80, For: -1},
+		{Name: "a", Threshold: 80, ClearThreshold: 90},
+		{Name: "a", Threshold: 80, ClearRemaining: 5},
+		{Name: "a", Remaining: 5, ClearRemaining: 2},
+		{Name: "a", Scope: selector.Rule{Name: "("}},
+	}
+	for _, rule := range invalid {
+		if err := r.AddAlarmRule(rule); err == nil {
+			t.Errorf("expected error for rule %+v", rule)
+		}
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Severity
+		wantErr bool
+	}{
+		{"warning", SeverityWarning, false},
+		{"CRITICAL", SeverityCritical, false},
+		{"info", SeverityInfo, false},
+		{"ok", SeverityOK, false},
+		{"fatal", SeverityOK, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseSeverity(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if s != tt.want {
+				t.Errorf("severity = %v, want %v", s, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/vslchnk/aws_quotas_checker/errs"
+)
+
+// usageJob gets usage of a single quota
+type usageJob struct {
+	serviceCode string
+	quotaCode   string
+	source      string
+	get         func(ctx context.Context) (*float64, error)
+}
+
+// usageResult is a result of usageJob
+type usageResult struct {
+	usage *float64
+	err   error
+}
+
+// runs jobs with bounded number of workers, every job is limited by the call timeout,
+// results are returned in the order of jobs, error is returned if the context is done before all jobs are finished
+func (r *Runner) runUsageJobs(ctx context.Context, jobs []usageJob) ([]usageResult, error) {
+	results := make([]usageResult, len(jobs))
+
+	workers := r.options.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				if err := ctx.Err(); err != nil {
+					results[i].err = errs.Classify(err)
+					continue
+				}
+
+				callCtx, callCancel := r.withCallTimeout(ctx)
+				results[i].usage, results[i].err = jobs[i].get(callCtx)
+				callCancel()
+
+				if results[i].err != nil {
+					results[i].err = errs.Classify(fmt.Errorf("Error while getting usage for quota %v from %v: %w", jobs[i].quotaCode, jobs[i].source, results[i].err))
+				}
+			}
+		}()
+	}
+
+	for i := range jobs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results, ctx.Err()
+}
@@ -0,0 +1,90 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+)
+
+// QuotaError describes failure of getting information about the quota,
+// QuotaCode is empty if information about the whole service can't be found and ServiceCode is empty
+// if the failure is not related to any service, e.g. the history file can't be written
+type QuotaError struct {
+	ServiceCode string
+	QuotaCode   string
+	// source of information: quotas, api, metrics, tags or history
+	Source string
+	Err    error
+}
+
+func (e QuotaError) Error() string {
+	if e.ServiceCode == "" {
+		return fmt.Sprintf("%v: %v", e.Source, e.Err)
+	}
+	if e.QuotaCode == "" {
+		return fmt.Sprintf("service %v (%v): %v", e.ServiceCode, e.Source, e.Err)
+	}
+
+	return fmt.Sprintf("service %v quota %v (%v): %v", e.ServiceCode, e.QuotaCode, e.Source, e.Err)
+}
+
+func (e QuotaError) Unwrap() error {
+	return e.Err
+}
+
+// returns errors which occurred during the last update of quotas information and usage,
+// errors can be checked with errors.Is against typed errors from errs package
+func (r *Runner) GetErrors() []QuotaError {
+	quotaErrors := make([]QuotaError, 0, 0)
+
+	for service, err := range r.quotas.GetErrors() {
+		quotaErrors = append(quotaErrors, QuotaError{ServiceCode: service, Source: "quotas", Err: err})
+	}
+
+	for _, service := range r.quotas.ListLoadedServicesCodes() {
+		s, err := r.quotas.GetService(service)
+		if err != nil {
+			continue
+		}
+		quotas, _ := r.quotas.ListQuotasCodes(service)
+		for _, quota := range *quotas {
+			q, _ := s.GetServiceQuota(quota)
+			if q.ValueErr != nil {
+				quotaErrors = append(quotaErrors, QuotaError{ServiceCode: service, QuotaCode: quota, Source: "quotas", Err: q.ValueErr})
+			}
+		}
+	}
+
+	for service, err := range r.providersErrors {
+		quotaErrors = append(quotaErrors, QuotaError{ServiceCode: service, Source: "api", Err: err})
+	}
+
+	for _, quotaErr := range *r.quotaApiErrors {
+		quotaErrors = append(quotaErrors, quotaErr)
+	}
+
+	for _, quotaErr := range *r.quotaMetricErrors {
+		quotaErrors = append(quotaErrors, quotaErr)
+	}
+
+	for _, quotaErr := range *r.quotaTagsErrors {
+		quotaErrors = append(quotaErrors, quotaErr)
+	}
+
+	for _, quotaErr := range *r.historyErrors {
+		quotaErrors = append(quotaErrors, quotaErr)
+	}
+
+	sort.Slice(quotaErrors, func(i, j int) bool {
+		a, b := quotaErrors[i], quotaErrors[j]
+		if a.ServiceCode != b.ServiceCode {
+			return a.ServiceCode < b.ServiceCode
+		}
+		if a.QuotaCode != b.QuotaCode {
+			return a.QuotaCode < b.QuotaCode
+		}
+
+		return a.Source < b.Source
+	})
+
+	return quotaErrors
+}
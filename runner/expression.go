@@ -0,0 +1,116 @@
+package runner
+
+import (
+	"context"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/expr"
+	"github.com/vslchnk/aws_quotas_checker/forecast"
+)
+
+// variables of alarm expressions: usage, value and default value of the quota in its unit, "limit" is the same as
+// value, percent of the value which is used and remaining capacity, adjustable and global flags, unit, service and
+// quota codes, quota name and source of usage, growth of usage over the last 1, 7 and 30 days fitted to usage history
+// of these days, days left until exhaustion and confidence of the forecast
+var expressionVars = expr.Vars{
+	"usage":         expr.TypeNumber,
+	"value":         expr.TypeNumber,
+	"limit":         expr.TypeNumber,
+	"default_value": expr.TypeNumber,
+	"percent":       expr.TypeNumber,
+	"remaining":     expr.TypeNumber,
+	"adjustable":    expr.TypeBool,
+	"global":        expr.TypeBool,
+	"unit":          expr.TypeString,
+	"service":       expr.TypeString,
+	"quota":         expr.TypeString,
+	"name":          expr.TypeString,
+	"source":        expr.TypeString,
+	"growth_1d":     expr.TypeNumber,
+	"growth_7d":     expr.TypeNumber,
+	"growth_30d":    expr.TypeNumber,
+	"days_left":     expr.TypeNumber,
+	"confidence":    expr.TypeNumber,
+}
+
+// days of growth variables
+var growthDays = map[string]int{"growth_1d": 1, "growth_7d": 7, "growth_30d": 30}
+
+// returns boolean expression of alarm rule parsed with variables of quotas, e.g. "usage / limit > 0.8 and not adjustable"
+// or "growth_7d > 20 and remaining < 50", errors have positions of invalid parts of the expression
+func ParseExpression(source string) (*expr.Expr, error) {
+	return expr.ParseBool(source, expressionVars)
+}
+
+// returns values of variables of expressions for the result at the moment, quota information and usage history
+// are requested only for variables which are evaluated, growth is unknown if there are fewer than 3 samples over
+// its days and days left are unknown if there is no forecast or usage doesn't grow
+func (r *Runner) expressionEnv(ctx context.Context, result CheckResult, now time.Time) expr.Env {
+	return func(name string) (interface{}, bool) {
+		switch name {
+		case "usage":
+			return result.Usage, true
+		case "value", "limit":
+			return result.Value, true
+		case "percent":
+			return result.Percent, true
+		case "remaining":
+			return result.Remaining, true
+		case "unit":
+			return result.Unit, true
+		case "service":
+			return result.ServiceCode, true
+		case "quota":
+			return result.QuotaCode, true
+		case "name":
+			return result.QuotaName, true
+		case "source":
+			return result.Type, true
+		case "default_value", "adjustable", "global":
+			q, err := r.getServiceQuota(ctx, result.ServiceCode, result.QuotaCode)
+			if err != nil {
+				return nil, false
+			}
+			switch name {
+			case "adjustable":
+				return q.Adjustable, true
+			case "global":
+				return q.GlobalQuota, true
+			}
+			return q.DefaultValue, true
+		case "growth_1d", "growth_7d", "growth_30d":
+			return r.getGrowth(result.ServiceCode, result.QuotaCode, growthDays[name], now)
+		case "days_left":
+			if result.Forecast == nil {
+				return nil, false
+			}
+			left, ok := result.Forecast.Left(now)
+			return left.Hours() / 24, ok
+		case "confidence":
+			if result.Forecast == nil {
+				return nil, false
+			}
+			return result.Forecast.Confidence, true
+		}
+
+		return nil, false
+	}
+}
+
+// returns growth of usage of the quota over the last days fitted to samples of these days
+func (r *Runner) getGrowth(serviceCode string, quotaCode string, days int, now time.Time) (interface{}, bool) {
+	since := now.Add(-time.Duration(days) * 24 * time.Hour)
+	samples := make([]forecast.Sample, 0, 0)
+	for _, s := range r.history.Samples(serviceCode, quotaCode) {
+		if !s.Time.Before(since) {
+			samples = append(samples, s)
+		}
+	}
+
+	m, err := forecast.Fit(samples)
+	if err != nil {
+		return nil, false
+	}
+
+	return m.Slope * float64(days), true
+}
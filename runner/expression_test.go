@@ -0,0 +1,60 @@
+package runner
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/forecast"
+)
+
+func TestExpressionAlarms(t *testing.T) {
+	// usage of L-1 is 4 of 5, L-3 is 7 of 10 and L-4 is 1 of 10, quotas are not adjustable
+	tests := []struct {
+		expression string
+		want       []string
+	}{
+		{"usage / limit > 0.75 and not adjustable", []string{"L-1"}},
+		{`service == "vpc"`, []string{"L-4"}},
+		{"default_value == 10 and percent >= 70 and source == 'metrics'", []string{"L-3"}},
+		// usage of L-3 falls by 10 a day, L-4 has no history, so its growth is unknown
+		{"remaining < 2 or growth_7d < -50", []string{"L-1", "L-3"}},
+		{"global", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			r := newTestRunner(t, newTestClients())
+			now := time.Now()
+			for i, usage := range []float64{37, 27, 17} {
+				r.history.Add("ec2", "L-3", forecast.Sample{Time: now.Add(time.Duration(i-3) * 24 * time.Hour), Usage: usage})
+			}
+
+			if err := r.AddAlarmRule(AlarmRule{Name: "expression", Expression: tt.expression}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := make([]string, 0, 0)
+			for _, w := range r.CheckAlarms() {
+				got = append(got, w.QuotaCode)
+				if w.Condition != tt.expression {
+					t.Errorf("condition = %q, want %q", w.Condition, tt.expression)
+				}
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("warnings = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	r := newTestRunner(t, newTestClients())
+	err := r.AddAlarmRule(AlarmRule{Name: "expression", Expression: "usage > 5 and adjustible"})
+	if err == nil || !strings.Contains(err.Error(), "at position 15: unknown variable adjustible") {
+		t.Errorf("error = %v, want error with position", err)
+	}
+	if err := r.AddAlarmRule(AlarmRule{Name: "expression", Expression: "usage * 2"}); err == nil {
+		t.Errorf("expected error for expression which is not bool")
+	}
+}
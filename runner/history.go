@@ -0,0 +1,154 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/forecast"
+	"github.com/vslchnk/aws_quotas_checker/quotas"
+)
+
+// creates usage history which is kept in the file of the account and region if history option is set,
+// otherwise it is kept only in memory
+func (r *Runner) newHistory() (*forecast.History, error) {
+	o := r.options.History
+	if o == nil {
+		return forecast.NewHistory("", 0)
+	}
+
+	dir := o.Dir
+	if dir == "" {
+		cacheDir, err := quotas.DefaultCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(cacheDir, "history")
+	}
+
+	if r.account == "" {
+		return nil, fmt.Errorf("Error while creating usage history: account is unknown")
+	}
+
+	return forecast.NewHistory(filepath.Join(dir, fmt.Sprintf("%v-%v.json", r.account, r.region)), o.Retention)
+}
+
+// adds usage of all quotas to the history at the time of the last update and writes the history file
+func (r *Runner) recordHistory() {
+	if r.history == nil {
+		return
+	}
+
+	at := r.collectedAt
+	for _, squ := range r.GetQuotasUsage() {
+		if squ.Error == nil {
+			r.history.Add(squ.ServiceCode, squ.QuotaCode, forecast.Sample{Time: at, Usage: squ.Usage})
+		}
+	}
+
+	r.saveHistory()
+}
+
+// writes the history file, error of writing it is reported by GetErrors with history source until
+// the next successful write
+func (r *Runner) saveHistory() {
+	if err := r.history.Save(); err != nil {
+		(*r.historyErrors)[""] = QuotaError{Source: "history", Err: err}
+		return
+	}
+	delete(*r.historyErrors, "")
+}
+
+// adds usage from cloudwatch metrics over the lookback to the history of quotas with usage metrics,
+// rate-based quotas are skipped
+func (r *Runner) BackfillHistory(lookback time.Duration) error {
+	return r.BackfillHistoryWithContext(context.Background(), lookback)
+}
+
+// adds usage from cloudwatch metrics over the lookback to the history, requests are made with the context,
+// failures of single quotas are reported by GetErrors with history source until the next update,
+// error is returned only if backfill is cancelled
+func (r *Runner) BackfillHistoryWithContext(ctx context.Context, lookback time.Duration) error {
+	ctx, cancel := r.withOverallTimeout(ctx)
+	defer cancel()
+
+	return r.backfillHistory(ctx, lookback)
+}
+
+// adds usage from cloudwatch metrics over the lookback to the history concurrently
+func (r *Runner) backfillHistory(ctx context.Context, lookback time.Duration) error {
+	jobs := make([]usageJob, 0, 0)
+	samples := make([][]forecast.Sample, 0, 0)
+
+	for _, service := range r.quotas.ListLoadedServicesCodes() {
+		codes, err := r.quotas.ListQuotasCodesWithContext(ctx, service)
+		if err != nil {
+			continue
+		}
+		s, _ := r.quotas.GetServiceWithContext(ctx, service)
+
+		for _, quota := range *codes {
+			q, _ := s.GetServiceQuota(quota)
+			if q.UsageMetric == nil || q.GetPeriod() > 0 {
+				continue
+			}
+
+			i, metric := len(samples), q.UsageMetric
+			samples = append(samples, nil)
+			jobs = append(jobs, usageJob{
+				serviceCode: service,
+				quotaCode:   quota,
+				source:      "history",
+				get: func(ctx context.Context) (*float64, error) {
+					history, err := r.cw.GetUsageHistoryFromMetricWithContext(ctx, metric, lookback)
+					if err != nil {
+						return nil, err
+					}
+					samples[i] = history
+					count := float64(len(history))
+
+					return &count, nil
+				},
+			})
+		}
+	}
+
+	results, err := r.runUsageJobs(ctx, jobs)
+
+	for i, job := range jobs {
+		if results[i].err != nil {
+			(*r.historyErrors)[job.quotaCode] = QuotaError{ServiceCode: job.serviceCode, QuotaCode: job.quotaCode, Source: job.source, Err: results[i].err}
+			continue
+		}
+		delete(*r.historyErrors, job.quotaCode)
+		r.history.Add(job.serviceCode, job.quotaCode, samples[i]...)
+	}
+	r.saveHistory()
+
+	if err != nil {
+		return fmt.Errorf("Error while backfilling usage history: %w", err)
+	}
+
+	return nil
+}
+
+// returns samples of usage of the quota sorted by time
+func (r *Runner) GetUsageHistory(serviceCode string, quotaCode string) []forecast.Sample {
+	return r.history.Samples(serviceCode, quotaCode)
+}
+
+// returns forecast of exhaustion of the quota value from the usage history, at least 3 samples are needed
+func (r *Runner) GetForecast(serviceCode string, quotaCode string) (*forecast.Forecast, error) {
+	q, err := r.GetServiceQuota(serviceCode, quotaCode)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := r.history.Forecast(serviceCode, quotaCode, q.Value)
+	if err != nil {
+		return nil, fmt.Errorf("Error while forecasting usage of quota %v: %w", quotaCode, err)
+	}
+
+	return f, nil
+}
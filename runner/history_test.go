@@ -0,0 +1,125 @@
+package runner
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/forecast"
+
+	"github.com/aws/aws-sdk-go/aws"
+	cw "github.com/aws/aws-sdk-go/service/cloudwatch"
+)
+
+func TestForecastAlarms(t *testing.T) {
+	r := newTestRunner(t, newTestClients())
+
+	// usage of L-1 grows by one a day and reaches its value 5 in a day, L-4 has no history
+	now := time.Now()
+	for i, usage := range []float64{1, 2, 3} {
+		r.history.Add("ec2", "L-1", forecast.Sample{Time: now.Add(time.Duration(i-3) * 24 * time.Hour), Usage: usage})
+	}
+	if err := r.UpdateQuotasUsage(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if samples := r.GetUsageHistory("ec2", "L-1"); len(samples) != 5 {
+		t.Errorf("samples = %+v, want history of updates to be kept", samples)
+	}
+
+	f, err := r.GetForecast("ec2", "L-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if left, ok := f.Left(now); !ok || left > 2*24*time.Hour {
+		t.Errorf("forecast = %+v, want exhaustion in about a day", f)
+	}
+	if _, err := r.GetForecast("vpc", "L-4"); err == nil {
+		t.Errorf("expected error for quota without enough samples")
+	}
+
+	if err := r.AddAlarmRule(AlarmRule{Name: "soon", Exhaustion: 14 * 24 * time.Hour, MinConfidence: 0.5}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	warnings := r.CheckAlarms()
+	if len(warnings) != 1 || warnings[0].QuotaCode != "L-1" || !strings.HasPrefix(warnings[0].Condition, "exhaustion in ") || warnings[0].Forecast == nil {
+		t.Errorf("warnings = %+v, want exhaustion of L-1", warnings)
+	}
+
+	for _, rule := range []AlarmRule{{Name: "a", Exhaustion: -1}, {Name: "a", Exhaustion: time.Hour, MinConfidence: 2}} {
+		if err := r.AddAlarmRule(rule); err == nil {
+			t.Errorf("expected error for rule %+v", rule)
+		}
+	}
+}
+
+func TestBackfillHistory(t *testing.T) {
+	c := newTestClients()
+	now := time.Now().UTC().Truncate(time.Hour)
+	c.metrics.Datapoints["ResourceCount"] = []*cw.Datapoint{
+		{Timestamp: aws.Time(now.Add(-2 * time.Hour)), Maximum: aws.Float64(5)},
+		{Timestamp: aws.Time(now.Add(-time.Hour)), Maximum: aws.Float64(6)},
+	}
+
+	r := newTestRunner(t, c)
+	if err := r.BackfillHistory(24 * time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// two samples from metrics and one of the update
+	if samples := r.GetUsageHistory("ec2", "L-3"); len(samples) != 3 || samples[0].Usage != 5 {
+		t.Errorf("samples = %+v, want backfilled samples", samples)
+	}
+
+	c.metrics.Err = errors.New("throttled")
+	if err := r.BackfillHistory(24 * time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	found := false
+	for _, e := range r.GetErrors() {
+		found = found || (e.QuotaCode == "L-3" && e.Source == "history")
+	}
+	if !found {
+		t.Errorf("errors = %v, want error of history", r.GetErrors())
+	}
+}
+
+func TestSaveHistoryErrors(t *testing.T) {
+	r := newTestRunner(t, newTestClients())
+
+	// the history file can't be written when its directory is a file
+	dir := t.TempDir()
+	history, err := forecast.NewHistory(filepath.Join(dir, "file", "history.json"), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "file"), nil, 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r.history = history
+
+	hasError := func() bool {
+		for _, e := range r.GetErrors() {
+			if e.ServiceCode == "" && e.Source == "history" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if err := r.UpdateQuotasUsage(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !hasError() {
+		t.Errorf("errors = %v, want error of writing history", r.GetErrors())
+	}
+
+	r.history, _ = forecast.NewHistory(filepath.Join(dir, "history.json"), 0)
+	if err := r.BackfillHistory(time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hasError() {
+		t.Errorf("errors = %v, want error of writing history to be cleared", r.GetErrors())
+	}
+}
@@ -0,0 +1,242 @@
+package runner
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/ratelimit"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// number of workers which get usage of quotas concurrently by default
+const defaultWorkers = 10
+
+// Options configures runner agent
+type Options struct {
+	// limits time of collecting information
+	Timeouts Timeouts
+	// maximum number of concurrent requests for usage of quotas, values less than 1 mean one worker
+	Workers int
+	// limits rate of requests by API namespace (ec2, cloudwatch, servicequotas, ...)
+	RateLimits ratelimit.Limiters
+	// session shared by all clients, it is used as a base for other session options if set
+	Session *session.Session
+	// name of the profile from shared config and credentials files
+	Profile string
+	// credentials which are used instead of default credential chain
+	Credentials *credentials.Credentials
+	// role which is assumed with base credentials
+	AssumeRole *AssumeRole
+	// resolver of endpoints for all services
+	EndpointResolver endpoints.Resolver
+	// endpoint URLs by endpoint ID of service (ec2, monitoring, servicequotas, ...), take precedence over EndpointResolver
+	Endpoints map[string]string
+	// HTTP client which is used by all clients
+	HTTPClient *http.Client
+	// information about quotas and their usage is loaded only when it is requested for the first time
+	Lazy bool
+	// keeps catalog of services and quotas on disk if set
+	CatalogCache *CatalogCache
+	// ID of the account, it is requested from STS if it is empty
+	Account string
+	// defines which usage is used if it is found both in services API and cloudwatch metrics
+	Precedence Precedence
+	// API and metric usage are marked as discrepant if they differ more than this fraction of the bigger one
+	DiscrepancyTolerance float64
+	// time which peak usage of rate-based quotas is found over, zero means one hour
+	RateLookback time.Duration
+	// keeps usage history of quotas on disk if set, history is kept only in memory otherwise
+	History *History
+}
+
+// CatalogCache configures on-disk cache of services and their default quotas
+type CatalogCache struct {
+	// directory of cache files, default one is in the user cache directory
+	Dir string
+	// entries older than TTL are requested again, zero means that entries never expire
+	TTL time.Duration
+	// entries kept before the start are requested again
+	Refresh bool
+}
+
+// History configures on-disk usage history of quotas which forecasts are made from
+type History struct {
+	// directory of history files, default one is in the user cache directory
+	Dir string
+	// samples older than retention are dropped, zero means 30 days
+	Retention time.Duration
+	// usage from cloudwatch metrics over this time is added to the history on creation of runner agent
+	Backfill time.Duration
+}
+
+// AssumeRole describes role which is assumed with STS
+type AssumeRole struct {
+	RoleARN     string
+	ExternalID  string
+	SessionName string
+	Duration    time.Duration
+}
+
+// Option changes Options of runner agent
+type Option func(*Options)
+
+// returns default options of runner agent
+func defaultOptions() Options {
+	o := Options{}
+	o.Workers = defaultWorkers
+	o.RateLimits = make(ratelimit.Limiters)
+	o.Endpoints = make(map[string]string)
+	o.Precedence = PrecedenceAPI
+
+	return o
+}
+
+// sets timeouts of collecting information
+func WithTimeouts(timeouts Timeouts) Option {
+	return func(o *Options) {
+		o.Timeouts = timeouts
+	}
+}
+
+// sets maximum number of concurrent requests for usage of quotas
+func WithWorkers(workers int) Option {
+	return func(o *Options) {
+		o.Workers = workers
+	}
+}
+
+// limits requests to API namespace by rate requests per second with bursts up to burst requests
+func WithRateLimit(namespace string, rate float64, burst int) Option {
+	return func(o *Options) {
+		o.RateLimits[namespace] = ratelimit.NewLimiter(rate, burst)
+	}
+}
+
+// sets session shared by all clients
+func WithSession(sess *session.Session) Option {
+	return func(o *Options) {
+		o.Session = sess
+	}
+}
+
+// sets profile from shared config and credentials files
+func WithProfile(profile string) Option {
+	return func(o *Options) {
+		o.Profile = profile
+	}
+}
+
+// sets credentials which are used instead of default credential chain
+func WithCredentials(creds *credentials.Credentials) Option {
+	return func(o *Options) {
+		o.Credentials = creds
+	}
+}
+
+// assumes role with STS, external ID is optional
+func WithAssumeRole(roleARN string, externalID string) Option {
+	return func(o *Options) {
+		o.AssumeRole = &AssumeRole{RoleARN: roleARN, ExternalID: externalID}
+	}
+}
+
+// sets resolver of endpoints for all services
+func WithEndpointResolver(resolver endpoints.Resolver) Option {
+	return func(o *Options) {
+		o.EndpointResolver = resolver
+	}
+}
+
+// overrides endpoint URL for the service by its endpoint ID (ec2, monitoring, servicequotas, ...)
+func WithEndpoint(endpointID string, url string) Option {
+	return func(o *Options) {
+		o.Endpoints[endpointID] = url
+	}
+}
+
+// sets ID of the account, so it is not requested from STS
+func WithAccount(account string) Option {
+	return func(o *Options) {
+		o.Account = account
+	}
+}
+
+// sets HTTP client which is used by all clients
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *Options) {
+		o.HTTPClient = client
+	}
+}
+
+// loads information about quotas and usage of the service only when it is requested for the first time
+func WithLazyLoading() Option {
+	return func(o *Options) {
+		o.Lazy = true
+	}
+}
+
+// keeps catalog of services and quotas in the directory for ttl, empty directory means the default one
+func WithCatalogCache(dir string, ttl time.Duration) Option {
+	return func(o *Options) {
+		if o.CatalogCache == nil {
+			o.CatalogCache = &CatalogCache{}
+		}
+		o.CatalogCache.Dir = dir
+		o.CatalogCache.TTL = ttl
+	}
+}
+
+// requests catalog of services and quotas again and replaces entries of the catalog cache
+func WithCatalogRefresh() Option {
+	return func(o *Options) {
+		if o.CatalogCache == nil {
+			o.CatalogCache = &CatalogCache{}
+		}
+		o.CatalogCache.Refresh = true
+	}
+}
+
+// defines which usage is used if it is found both in services API and cloudwatch metrics
+func WithPrecedence(precedence Precedence) Option {
+	return func(o *Options) {
+		o.Precedence = precedence
+	}
+}
+
+// sets fraction of the bigger usage which API and metric usage may differ by without discrepancy
+func WithDiscrepancyTolerance(tolerance float64) Option {
+	return func(o *Options) {
+		o.DiscrepancyTolerance = tolerance
+	}
+}
+
+// sets time which peak usage of rate-based quotas is found over
+func WithRateLookback(lookback time.Duration) Option {
+	return func(o *Options) {
+		o.RateLookback = lookback
+	}
+}
+
+// keeps usage history of quotas in the directory for retention, empty directory means the default one
+func WithUsageHistory(dir string, retention time.Duration) Option {
+	return func(o *Options) {
+		if o.History == nil {
+			o.History = &History{}
+		}
+		o.History.Dir = dir
+		o.History.Retention = retention
+	}
+}
+
+// adds usage from cloudwatch metrics over the lookback to the usage history on creation of runner agent
+func WithHistoryBackfill(lookback time.Duration) Option {
+	return func(o *Options) {
+		if o.History == nil {
+			o.History = &History{}
+		}
+		o.History.Backfill = lookback
+	}
+}
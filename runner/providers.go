@@ -0,0 +1,66 @@
+package runner
+
+import (
+	"github.com/vslchnk/aws_quotas_checker/selector"
+	"github.com/vslchnk/aws_quotas_checker/services"
+	"github.com/vslchnk/aws_quotas_checker/services/autoscaling"
+	"github.com/vslchnk/aws_quotas_checker/services/cloudformation"
+	"github.com/vslchnk/aws_quotas_checker/services/ec2"
+	"github.com/vslchnk/aws_quotas_checker/services/efs"
+	"github.com/vslchnk/aws_quotas_checker/services/elasticbeanstalk"
+	"github.com/vslchnk/aws_quotas_checker/services/elb"
+	"github.com/vslchnk/aws_quotas_checker/services/s3"
+	"github.com/vslchnk/aws_quotas_checker/services/vpc"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// registry of usage providers used by runners
+var providers = newDefaultRegistry()
+
+// creates registry with providers for supported services
+func newDefaultRegistry() *services.Registry {
+	r := services.NewRegistry()
+
+	r.Register(autoscaling.GetCode(), func(sess *session.Session, sel *selector.Selector) (services.UsageProvider, error) {
+		return autoscaling.NewAutoscalingWithSession(sess, sel)
+	})
+	r.Register(cloudformation.GetCode(), func(sess *session.Session, sel *selector.Selector) (services.UsageProvider, error) {
+		return cloudformation.NewCloudformationWithSession(sess, sel)
+	})
+	r.Register(ec2.GetCode(), func(sess *session.Session, sel *selector.Selector) (services.UsageProvider, error) {
+		return ec2.NewEC2WithSession(sess, sel)
+	})
+	r.Register(efs.GetCode(), func(sess *session.Session, sel *selector.Selector) (services.UsageProvider, error) {
+		return efs.NewEFSWithSession(sess, sel)
+	})
+	r.Register(elasticbeanstalk.GetCode(), func(sess *session.Session, sel *selector.Selector) (services.UsageProvider, error) {
+		return elasticbeanstalk.NewElasticbeanstalkWithSession(sess, sel)
+	})
+	r.Register(elb.GetCode(), func(sess *session.Session, sel *selector.Selector) (services.UsageProvider, error) {
+		return elb.NewELBWithSession(sess, sel)
+	})
+	r.Register(s3.GetCode(), func(sess *session.Session, sel *selector.Selector) (services.UsageProvider, error) {
+		return s3.NewS3WithSession(sess, sel)
+	})
+	r.Register(vpc.GetCode(), func(sess *session.Session, sel *selector.Selector) (services.UsageProvider, error) {
+		return vpc.NewVPCWithSession(sess, sel)
+	})
+
+	return r
+}
+
+// registers usage provider for the service code, provider for already supported service is replaced
+func RegisterProvider(serviceCode string, factory services.ProviderFactory) {
+	providers.Register(serviceCode, factory)
+}
+
+// removes usage provider for the service code
+func UnregisterProvider(serviceCode string) {
+	providers.Unregister(serviceCode)
+}
+
+// returns sorted slice of service codes which have usage providers
+func ListProviders() []string {
+	return providers.ListCodes()
+}
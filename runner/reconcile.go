@@ -0,0 +1,73 @@
+package runner
+
+import (
+	"math"
+)
+
+// Precedence defines which usage is used if it is found both in services API and cloudwatch metrics
+type Precedence string
+
+const (
+	// usage from services API is used
+	PrecedenceAPI Precedence = "api"
+	// usage from cloudwatch metrics is used
+	PrecedenceMetrics Precedence = "metrics"
+	// the biggest usage is used
+	PrecedenceMax Precedence = "max"
+)
+
+// usageInfo is usage of the quota reconciled from all sources
+type usageInfo struct {
+	// nil if usage is found in none of the sources
+	usage  *float64
+	source string
+	api    *float64
+	metric *float64
+	// API and metric usage differ more than the tolerance
+	discrepancy bool
+	// error of the preferred source if usage is found in none of the sources
+	err error
+}
+
+// returns usage of the quota from services API and cloudwatch metrics reconciled by precedence of options
+func (r *Runner) getUsageInfo(quotaCode string) usageInfo {
+	info := usageInfo{}
+
+	if usage, ok := (*r.quotaApiUsage)[quotaCode]; ok {
+		info.api = &usage
+	}
+	if usage, ok := (*r.quotaMetricUsage)[quotaCode]; ok {
+		info.metric = &usage
+	}
+
+	switch {
+	case info.api != nil && info.metric != nil:
+		info.discrepancy = isDiscrepancy(*info.api, *info.metric, r.options.DiscrepancyTolerance)
+		info.usage, info.source = info.api, "api"
+		if r.options.Precedence == PrecedenceMetrics || (r.options.Precedence == PrecedenceMax && *info.metric > *info.api) {
+			info.usage, info.source = info.metric, "metrics"
+		}
+	case info.api != nil:
+		info.usage, info.source = info.api, "api"
+	case info.metric != nil:
+		info.usage, info.source = info.metric, "metrics"
+	default:
+		apiErr, apiFailed := (*r.quotaApiErrors)[quotaCode]
+		metricErr, metricFailed := (*r.quotaMetricErrors)[quotaCode]
+		if apiFailed && (!metricFailed || r.options.Precedence != PrecedenceMetrics) {
+			info.err = apiErr
+		} else if metricFailed {
+			info.err = metricErr
+		}
+	}
+
+	return info
+}
+
+// checks if API and metric usage differ more than the tolerance which is a fraction of the bigger usage
+func isDiscrepancy(api float64, metric float64, tolerance float64) bool {
+	diff := math.Abs(api - metric)
+	max := math.Max(api, metric)
+
+	return diff > max*tolerance
+}
@@ -0,0 +1,79 @@
+package runner
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vslchnk/aws_quotas_checker/errs"
+)
+
+func TestGetUsageInfo(t *testing.T) {
+	throttled := QuotaError{ServiceCode: "ec2", QuotaCode: "L-1", Source: "api", Err: errs.ErrThrottled}
+	denied := QuotaError{ServiceCode: "ec2", QuotaCode: "L-1", Source: "metrics", Err: errs.ErrAccessDenied}
+
+	tests := []struct {
+		name        string
+		precedence  Precedence
+		tolerance   float64
+		api         map[string]float64
+		metric      map[string]float64
+		apiErrors   map[string]QuotaError
+		metricErrs  map[string]QuotaError
+		wantUsage   *float64
+		wantSource  string
+		discrepancy bool
+		wantErr     error
+	}{
+		{name: "api only", precedence: PrecedenceMetrics, api: map[string]float64{"L-1": 3}, wantUsage: floatPtr(3), wantSource: "api"},
+		{name: "metric only", metric: map[string]float64{"L-1": 5}, apiErrors: map[string]QuotaError{"L-1": throttled}, wantUsage: floatPtr(5), wantSource: "metrics"},
+		{name: "api precedence", api: map[string]float64{"L-1": 3}, metric: map[string]float64{"L-1": 5}, wantUsage: floatPtr(3), wantSource: "api", discrepancy: true},
+		{name: "metrics precedence", precedence: PrecedenceMetrics, api: map[string]float64{"L-1": 3}, metric: map[string]float64{"L-1": 5}, wantUsage: floatPtr(5), wantSource: "metrics", discrepancy: true},
+		{name: "max precedence", precedence: PrecedenceMax, api: map[string]float64{"L-1": 6}, metric: map[string]float64{"L-1": 5}, tolerance: 0.2, wantUsage: floatPtr(6), wantSource: "api"},
+		{name: "equal usage", api: map[string]float64{"L-1": 5}, metric: map[string]float64{"L-1": 5}, wantUsage: floatPtr(5), wantSource: "api"},
+		{name: "beyond tolerance", api: map[string]float64{"L-1": 10}, metric: map[string]float64{"L-1": 8}, tolerance: 0.1, wantUsage: floatPtr(10), wantSource: "api", discrepancy: true},
+		{name: "api error", apiErrors: map[string]QuotaError{"L-1": throttled}, metricErrs: map[string]QuotaError{"L-1": denied}, wantErr: errs.ErrThrottled},
+		{name: "metric error by precedence", precedence: PrecedenceMetrics, apiErrors: map[string]QuotaError{"L-1": throttled}, metricErrs: map[string]QuotaError{"L-1": denied}, wantErr: errs.ErrAccessDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Runner{}
+			r.options = defaultOptions()
+			if tt.precedence != "" {
+				r.options.Precedence = tt.precedence
+			}
+			r.options.DiscrepancyTolerance = tt.tolerance
+			r.resetQuotasUsage()
+			for k, v := range tt.api {
+				(*r.quotaApiUsage)[k] = v
+			}
+			for k, v := range tt.metric {
+				(*r.quotaMetricUsage)[k] = v
+			}
+			for k, v := range tt.apiErrors {
+				(*r.quotaApiErrors)[k] = v
+			}
+			for k, v := range tt.metricErrs {
+				(*r.quotaMetricErrors)[k] = v
+			}
+
+			info := r.getUsageInfo("L-1")
+
+			if tt.wantErr != nil {
+				if info.usage != nil || !errors.Is(info.err, tt.wantErr) {
+					t.Errorf("usage/error = %v/%v, want error %v", info.usage, info.err, tt.wantErr)
+				}
+				return
+			}
+			if info.usage == nil || *info.usage != *tt.wantUsage || info.source != tt.wantSource {
+				t.Fatalf("usage = %v (%v), want %v (%v)", info.usage, info.source, *tt.wantUsage, tt.wantSource)
+			}
+			if info.discrepancy != tt.discrepancy {
+				t.Errorf("discrepancy = %v, want %v", info.discrepancy, tt.discrepancy)
+			}
+			if info.err != nil {
+				t.Errorf("unexpected error: %v", info.err)
+			}
+		})
+	}
+}
@@ -1,19 +1,25 @@
 package runner
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/vslchnk/aws_quotas_checker/cloudwatch"
+	"github.com/vslchnk/aws_quotas_checker/errs"
+	"github.com/vslchnk/aws_quotas_checker/forecast"
 	"github.com/vslchnk/aws_quotas_checker/quotas"
-	"github.com/vslchnk/aws_quotas_checker/services/autoscaling"
-	"github.com/vslchnk/aws_quotas_checker/services/cloudformation"
-	"github.com/vslchnk/aws_quotas_checker/services/ec2"
-	"github.com/vslchnk/aws_quotas_checker/services/efs"
-	"github.com/vslchnk/aws_quotas_checker/services/elasticbeanstalk"
-	"github.com/vslchnk/aws_quotas_checker/services/elb"
-	"github.com/vslchnk/aws_quotas_checker/services/s3"
-	"github.com/vslchnk/aws_quotas_checker/services/vpc"
+	"github.com/vslchnk/aws_quotas_checker/ratelimit"
+	"github.com/vslchnk/aws_quotas_checker/selector"
+	"github.com/vslchnk/aws_quotas_checker/services"
+	"github.com/vslchnk/aws_quotas_checker/units"
 	"github.com/vslchnk/aws_quotas_checker/utils"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
 )
 
 type ServiceQuota struct {
@@ -23,8 +29,11 @@ type ServiceQuota struct {
 	QuotaCode    string
 	DefaultValue float64
 	Value        float64
-	ServiceCode  string
-	ServiceName  string
+	Unit         string
+	// period of the rate-based quota, zero for other quotas
+	Period      time.Duration
+	ServiceCode string
+	ServiceName string
 }
 
 type ServiceQuotaUsage struct {
@@ -32,9 +41,19 @@ type ServiceQuotaUsage struct {
 	ServiceName string
 	QuotaName   string
 	QuotaCode   string
-	Usage       int
-	Value       int
-	Type        string
+	Usage       float64
+	Value       float64
+	Unit        string
+	// period of the rate-based quota, usage is the peak rate over the lookback then
+	Period time.Duration
+	Type   string
+	// usage from services API, nil if it is not found
+	ApiUsage *float64
+	// usage from cloudwatch metrics, nil if it is not found
+	MetricUsage *float64
+	// API and metric usage differ more than the tolerance
+	Discrepancy bool
+	Error       error
 }
 
 type Warning struct {
@@ -42,337 +61,407 @@ type Warning struct {
 	ServiceName string
 	QuotaName   string
 	QuotaCode   string
-	Limit       int
-	Usage       int
+	Limit       float64
+	Usage       float64
+	Unit        string
+	Period      time.Duration
 	Name        string
 	Threshold   int
+	Severity    Severity
+	// capacity which is left and remaining threshold of the alarm, zero threshold means no remaining condition
+	Remaining          float64
+	RemainingThreshold float64
+	// forecast of exhaustion, nil if there are not enough samples, and exhaustion threshold of the alarm,
+	// zero threshold means no exhaustion condition
+	Forecast            *forecast.Forecast
+	ExhaustionThreshold time.Duration
+	// conditions which raised the alarm, e.g. "usage 85% >= 80%" or "remaining 2 < 5"
+	Condition string
+	// silence which suppresses the alarm, it is set only for warnings of CheckSuppressedAlarms
+	Silence *Silence
 }
 
 type iamActions map[string][]string
 
+// Timeouts limits time of collecting information, zero value means no limit
+type Timeouts struct {
+	// limits whole collection of quotas information or usage
+	Overall time.Duration
+	// limits every single request for usage of quota
+	Call time.Duration
+}
+
 type Runner struct {
 	region            string
-	allowedServices   *map[string]*[]string
+	account           string
+	session           *session.Session
+	selector          *selector.Selector
 	quotas            *quotas.Quotas
+	cache             *quotas.Cache
 	quotaServiceCodes *[]string
-	ec2               *ec2.EC2
-	cf                *cloudformation.Cloudformation
-	elastic           *elasticbeanstalk.Elasticbeanstalk
-	as                *autoscaling.Autoscaling
-	s3                *s3.S3
-	vpc               *vpc.VPC
-	elb               *elb.ELB
-	efs               *efs.EFS
+	providers         map[string]services.UsageProvider
 	cw                *cloudwatch.CW
-	quotaMetricUsage  *map[string]int
-	quotaApiUsage     *map[string]int
-	quotaUsage        *map[string][]int
+	quotaMetricUsage  *map[string]float64
+	quotaApiUsage     *map[string]float64
+	quotaMetricErrors *map[string]QuotaError
+	quotaApiErrors    *map[string]QuotaError
+	providersErrors   map[string]error
 	quotasServiceInfo *map[string]string
-	alarms            map[string]int
+	quotaTags         *map[string]map[string]string
+	quotaTagsErrors   *map[string]QuotaError
+	history           *forecast.History
+	historyErrors     *map[string]QuotaError
+	alarmRules        []AlarmRule
+	silences          []Silence
+	options           Options
+	snapshot          *Snapshot
+	collectedAt       time.Time
 }
 
 // creates runner agent
-func NewRunner(region string, allowedServices *map[string]*[]string) (*Runner, error) {
+func NewRunner(region string, sel *selector.Selector) (*Runner, error) {
+	return NewRunnerWithContext(context.Background(), region, sel, nil)
+}
+
+// creates runner agent, requests are made with the context and limited by timeouts, nil timeouts means no limits
+func NewRunnerWithContext(ctx context.Context, region string, sel *selector.Selector, timeouts *Timeouts) (*Runner, error) {
+	if timeouts == nil {
+		return NewRunnerWithOptions(ctx, region, sel)
+	}
+
+	return NewRunnerWithOptions(ctx, region, sel, WithTimeouts(*timeouts))
+}
+
+// creates runner agent configured by options for quotas selected by selector, nil selector selects all quotas,
+// requests are made with the context
+func NewRunnerWithOptions(ctx context.Context, region string, sel *selector.Selector, opts ...Option) (*Runner, error) {
+	if err := sel.Validate(); err != nil {
+		return nil, err
+	}
+
 	r := Runner{}
 	r.region = region
-	r.allowedServices = allowedServices
-	r.alarms = make(map[string]int)
+	r.selector = sel
+	r.options = defaultOptions()
+	for _, opt := range opts {
+		opt(&r.options)
+	}
+
+	ctx, cancel := r.withOverallTimeout(ctx)
+	defer cancel()
 
 	var err error
-	r.quotas, err = quotas.NewQuota(r.region, r.allowedServices)
+	r.session, err = newSession(r.region, &r.options)
 	if err != nil {
-		return nil, fmt.Errorf("Error while creating quota client: %v", err)
+		return nil, err
 	}
-	r.quotaServiceCodes = r.quotas.ListServicesCodes()
+	r.region = aws.StringValue(r.session.Config.Region)
+
+	r.account = r.options.Account
+	if r.account == "" && (r.options.CatalogCache != nil || r.options.History != nil) {
+		// account is requested only if it is a part of the catalog cache key or name of the history file
+		r.account, err = getAccount(ctx, r.session)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r.createServicesClients()
 
-	err = r.createServicesClients()
+	r.quotas, err = r.newQuotas(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("Error while creating service clients: %v", err)
+		return nil, fmt.Errorf("Error while creating quota client: %w", err)
 	}
+	r.quotaServiceCodes = r.quotas.ListServicesCodes()
 
-	r.cw, err = cloudwatch.NewCW(r.region)
+	r.cw, err = cloudwatch.NewCWWithSession(r.session)
 	if err != nil {
-		return nil, fmt.Errorf("Error while creating cloudwatch client: %v", err)
+		return nil, fmt.Errorf("Error while creating cloudwatch client: %w", err)
 	}
+	r.cw.SetLookback(r.options.RateLookback)
 
-	r.quotaMetricUsage, err = r.getQuotaMetricUsage()
+	r.history, err = r.newHistory()
 	if err != nil {
-		return nil, fmt.Errorf("Error while getting usage for quotas from cloudwatch metrics: %v", err)
+		return nil, err
+	}
+
+	if r.options.Lazy {
+		r.resetQuotasUsage()
+		r.refreshSnapshot()
+		return &r, nil
 	}
 
-	r.quotaApiUsage, err = r.getQuotaApiUsage()
+	err = r.updateQuotasUsage(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("Error while getting usage for quotas from api: %v", err)
+		return nil, err
 	}
 
-	r.quotaUsage = utils.MergeMaps(*r.quotaMetricUsage, *r.quotaApiUsage)
-	r.quotasServiceInfo = r.createQuotasServiceInfo()
+	if o := r.options.History; o != nil && o.Backfill > 0 {
+		if err := r.backfillHistory(ctx, o.Backfill); err != nil {
+			return nil, err
+		}
+	}
 
 	return &r, nil
 }
 
-// add alarm to alarms map with key-name value-threshold
-func (r *Runner) AddAlarm(name string, threshold int) {
-	r.alarms[name] = threshold
+// sets timeouts for next updates of information, nil means no limits
+func (r *Runner) SetTimeouts(timeouts *Timeouts) {
+	r.options.Timeouts = Timeouts{}
+	if timeouts != nil {
+		r.options.Timeouts = *timeouts
+	}
 }
 
-// creates map where key is the quota code and value is the service code
-func (r *Runner) createQuotasServiceInfo() *map[string]string {
-	quotasServiceInfo := make(map[string]string)
-
-	for _, s := range *r.quotaServiceCodes {
-		quotas, _ := r.GetSupportedQuotasForService(s)
-		for _, q := range quotas {
-			quotasServiceInfo[q] = s
-		}
+// returns context limited by overall timeout which carries rate limiters
+func (r *Runner) withOverallTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx = ratelimit.NewContext(ctx, r.options.RateLimits)
+	if r.options.Timeouts.Overall > 0 {
+		return context.WithTimeout(ctx, r.options.Timeouts.Overall)
 	}
 
-	return &quotasServiceInfo
+	return context.WithCancel(ctx)
 }
 
-// creates clients to get usage from AWS services
-func (r *Runner) createServicesClients() error {
-	var err error
-
-	isServiceAllowed, allowedQuotas := r.checkIfServiceAllowed(ec2.GetCode())
-	if isServiceAllowed {
-		r.ec2, err = ec2.NewEC2(r.region, allowedQuotas)
-		if err != nil {
-			return fmt.Errorf("Error while creating ec2 client: %v", err)
-		}
-	}
-
-	isServiceAllowed, allowedQuotas = r.checkIfServiceAllowed(cloudformation.GetCode())
-	if isServiceAllowed {
-		r.cf, err = cloudformation.NewCloudformation(r.region, allowedQuotas)
-		if err != nil {
-			return fmt.Errorf("Error while creating cloudformation client: %v", err)
-		}
+// returns context limited by timeout for a single request
+func (r *Runner) withCallTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.options.Timeouts.Call > 0 {
+		return context.WithTimeout(ctx, r.options.Timeouts.Call)
 	}
 
-	isServiceAllowed, allowedQuotas = r.checkIfServiceAllowed(elasticbeanstalk.GetCode())
-	if isServiceAllowed {
-		r.elastic, err = elasticbeanstalk.NewElasticbeanstalk(r.region, allowedQuotas)
-		if err != nil {
-			return fmt.Errorf("Error while creating elasticbeanstalk client: %v", err)
-		}
-	}
+	return context.WithCancel(ctx)
+}
 
-	isServiceAllowed, allowedQuotas = r.checkIfServiceAllowed(autoscaling.GetCode())
-	if isServiceAllowed {
-		r.as, err = autoscaling.NewAutoscaling(r.region, allowedQuotas)
-		if err != nil {
-			return fmt.Errorf("Error while creating autoscaling client: %v", err)
-		}
+// creates Quotas agent which loads all services or only the list of them in lazy mode
+func (r *Runner) newQuotas(ctx context.Context) (*quotas.Quotas, error) {
+	providerQuotas := make(map[string][]string)
+	for code, p := range r.providers {
+		providerQuotas[code] = p.ListQuotasCodes()
 	}
 
-	isServiceAllowed, allowedQuotas = r.checkIfServiceAllowed(s3.GetCode())
-	if isServiceAllowed {
-		r.s3, err = s3.NewS3(r.region, allowedQuotas)
-		if err != nil {
-			return fmt.Errorf("Error while creating S3 client: %v", err)
-		}
-	}
+	opts := make([]quotas.Option, 0, 2)
+	opts = append(opts, quotas.WithProviderQuotas(providerQuotas))
 
-	isServiceAllowed, allowedQuotas = r.checkIfServiceAllowed(vpc.GetCode())
-	if isServiceAllowed {
-		r.vpc, err = vpc.NewVPC(r.region, allowedQuotas)
-		if err != nil {
-			return fmt.Errorf("Error while creating VPC client: %v", err)
+	if r.options.CatalogCache != nil {
+		if r.cache == nil {
+			var err error
+			r.cache, err = r.newCatalogCache(ctx)
+			if err != nil {
+				return nil, err
+			}
 		}
+		opts = append(opts, quotas.WithCache(r.cache))
 	}
 
-	isServiceAllowed, allowedQuotas = r.checkIfServiceAllowed(elb.GetCode())
-	if isServiceAllowed {
-		r.elb, err = elb.NewELB(r.region, allowedQuotas)
-		if err != nil {
-			return fmt.Errorf("Error while creating ELB client: %v", err)
-		}
+	if r.options.Lazy {
+		return quotas.NewLazyQuotaWithSession(ctx, r.session, r.selector, opts...)
 	}
 
-	isServiceAllowed, allowedQuotas = r.checkIfServiceAllowed(efs.GetCode())
-	if isServiceAllowed {
-		r.efs, err = efs.NewEFS(r.region, allowedQuotas)
-		if err != nil {
-			return fmt.Errorf("Error while creating EFS client: %v", err)
-		}
-	}
+	return quotas.NewQuotaWithSession(ctx, r.session, r.selector, opts...)
+}
 
-	return nil
+// adds alarm for all quotas with key-name value-threshold
+func (r *Runner) AddAlarm(name string, threshold int) {
+	r.AddAlarmWithSeverity(name, threshold, SeverityWarning)
 }
 
-// checks if service is allowed and returns result and list of allowed quotas
-func (r *Runner) checkIfServiceAllowed(serviceCode string) (bool, *[]string) {
-	isServiceAllowed := true
-	var allowedQuotas *[]string
+// creates map where key is the quota code and value is the service code, only loaded services are used
+func (r *Runner) createQuotasServiceInfo() *map[string]string {
+	quotasServiceInfo := make(map[string]string)
 
-	if r.allowedServices != nil {
-		if !utils.CheckKeyMap(*r.allowedServices, serviceCode) {
-			isServiceAllowed = false
-		} else {
-			allowedQuotas, _ = (*r.allowedServices)[serviceCode]
+	for _, s := range r.quotas.ListLoadedServicesCodes() {
+		quotas, _ := r.GetSupportedQuotasForService(s)
+		for _, q := range quotas {
+			quotasServiceInfo[q] = s
 		}
 	}
 
-	return isServiceAllowed, allowedQuotas
+	return &quotasServiceInfo
 }
 
-// returns map with the usage of quotas as a value and quota code as a key, usage is from services API
-func (r *Runner) getQuotaApiUsage() (*map[string]int, error) {
-	var err error
-	var mapPointer *map[string]int
+// creates clients to get usage from AWS services, errors of creation are kept by service code
+func (r *Runner) createServicesClients() {
+	r.providers = make(map[string]services.UsageProvider)
+	r.providersErrors = make(map[string]error)
+
+	for _, code := range providers.ListCodes() {
+		if r.selector.MatchService(code) {
+			p, err := providers.NewProvider(code, r.session, r.selector)
+			if err != nil {
+				r.providersErrors[code] = errs.Classify(fmt.Errorf("Error while creating %v client: %w", code, err))
+				continue
+			}
 
-	isServiceAllowed, _ := r.checkIfServiceAllowed(ec2.GetCode())
-	if isServiceAllowed {
-		mapPointer, err = r.ec2.GetUsage()
-		if err != nil {
-			return nil, fmt.Errorf("Error while getting usage from API for EC2 quotas: %v", err)
+			r.providers[code] = p
 		}
 	}
-	ec2UsageMap := make(map[string]int)
-	if mapPointer != nil {
-		ec2UsageMap = *mapPointer
-	}
+}
 
-	isServiceAllowed, _ = r.checkIfServiceAllowed(cloudformation.GetCode())
-	if isServiceAllowed {
-		mapPointer, err = r.cf.GetUsage()
-		if err != nil {
-			return nil, fmt.Errorf("Error while getting usage from API for EC2 quotas: %v", err)
-		}
-	}
-	cfUsageMap := make(map[string]int)
-	if mapPointer != nil {
-		cfUsageMap = *mapPointer
-	}
+// returns jobs to get usage of quotas from services API
+func (r *Runner) getQuotaApiUsageJobs() []usageJob {
+	jobs := make([]usageJob, 0, 0)
 
-	isServiceAllowed, _ = r.checkIfServiceAllowed(elasticbeanstalk.GetCode())
-	if isServiceAllowed {
-		mapPointer, err = r.elastic.GetUsage()
-		if err != nil {
-			return nil, fmt.Errorf("Error while getting usage from API for ElasticBeanstalk quotas: %v", err)
-		}
-	}
-	elasticUsageMap := make(map[string]int)
-	if mapPointer != nil {
-		elasticUsageMap = *mapPointer
+	codes := make([]string, 0, len(r.providers))
+	for code := range r.providers {
+		codes = append(codes, code)
 	}
+	sort.Strings(codes)
 
-	isServiceAllowed, _ = r.checkIfServiceAllowed(autoscaling.GetCode())
-	if isServiceAllowed {
-		mapPointer, err = r.as.GetUsage()
-		if err != nil {
-			return nil, fmt.Errorf("Error while getting usage from API for AutoScaling quotas: %v", err)
-		}
-	}
-	autoscalingUsageMap := make(map[string]int)
-	if mapPointer != nil {
-		autoscalingUsageMap = *mapPointer
-	}
+	for _, code := range codes {
+		p := r.providers[code]
 
-	isServiceAllowed, _ = r.checkIfServiceAllowed(s3.GetCode())
-	if isServiceAllowed {
-		mapPointer, err = r.s3.GetUsage()
-		if err != nil {
-			return nil, fmt.Errorf("Error while getting usage from API for S3 quotas: %v", err)
+		for _, quota := range p.ListQuotasCodes() {
+			jobs = append(jobs, newApiUsageJob(p, quota))
 		}
 	}
-	s3UsageMap := make(map[string]int)
-	if mapPointer != nil {
-		s3UsageMap = *mapPointer
-	}
 
-	isServiceAllowed, _ = r.checkIfServiceAllowed(vpc.GetCode())
-	if isServiceAllowed {
-		mapPointer, err = r.vpc.GetUsage()
-		if err != nil {
-			return nil, fmt.Errorf("Error while getting usage from API for VPC quotas: %v", err)
-		}
-	}
-	vpcUsageMap := make(map[string]int)
-	if mapPointer != nil {
-		vpcUsageMap = *mapPointer
-	}
+	return jobs
+}
 
-	isServiceAllowed, _ = r.checkIfServiceAllowed(elb.GetCode())
-	if isServiceAllowed {
-		mapPointer, err = r.elb.GetUsage()
-		if err != nil {
-			return nil, fmt.Errorf("Error while getting usage from API for ELB quotas: %v", err)
-		}
-	}
-	elbUsageMap := make(map[string]int)
-	if mapPointer != nil {
-		elbUsageMap = *mapPointer
+// returns job to get usage of the quota from the provider
+func newApiUsageJob(p services.UsageProvider, quotaCode string) usageJob {
+	return usageJob{
+		serviceCode: p.GetCode(),
+		quotaCode:   quotaCode,
+		source:      "api",
+		get: func(ctx context.Context) (*float64, error) {
+			return p.GetQuotaUsageWithContext(ctx, quotaCode)
+		},
 	}
+}
 
-	isServiceAllowed, _ = r.checkIfServiceAllowed(efs.GetCode())
-	if isServiceAllowed {
-		mapPointer, err = r.efs.GetUsage()
-		if err != nil {
-			return nil, fmt.Errorf("Error while getting usage from API for EFS quotas: %v", err)
-		}
-	}
-	efsUsageMap := make(map[string]int)
-	if mapPointer != nil {
-		efsUsageMap = *mapPointer
-	}
+// returns job to get usage of the quota from the cloudwatch metric, peak rate is used for rate-based quotas
+func (r *Runner) newMetricUsageJob(serviceCode string, quotaCode string, metric *servicequotas.MetricInfo, period time.Duration) usageJob {
+	return usageJob{
+		serviceCode: serviceCode,
+		quotaCode:   quotaCode,
+		source:      "metrics",
+		get: func(ctx context.Context) (*float64, error) {
+			if period > 0 {
+				return r.cw.GetRateUsageFromMetricWithContext(ctx, metric, period)
+			}
 
-	return utils.MergeMapsUniqueKeys(ec2UsageMap, cfUsageMap, elasticUsageMap, autoscalingUsageMap, s3UsageMap, vpcUsageMap, elbUsageMap, efsUsageMap), nil
+			return r.cw.GetUsageFromMetricWithContext(ctx, metric)
+		},
+	}
 }
 
-// returns map with the usage of quotas as a value and quota code as a key, usage is from cloudwatch metrics
-func (r *Runner) getQuotaMetricUsage() (*map[string]int, error) {
-	quotaMetricUsage := make(map[string]int)
+// returns jobs to get usage of quotas from cloudwatch metrics, services which quotas can't be loaded are skipped
+func (r *Runner) getQuotaMetricUsageJobs(ctx context.Context) ([]usageJob, error) {
+	jobs := make([]usageJob, 0, 0)
 
 	for _, service := range *r.quotaServiceCodes {
-		s, _ := r.quotas.GetService(service)
-		quotas, err := r.quotas.ListQuotasCodes(service)
+		quotas, err := r.quotas.ListQuotasCodesWithContext(ctx, service)
 		if err != nil {
-			return nil, fmt.Errorf("Error while listing quotas codes: %v", err)
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("Error while listing quotas codes: %w", err)
+			}
+			continue
 		}
+		s, _ := r.quotas.GetServiceWithContext(ctx, service)
 
 		for _, quota := range *quotas {
 			q, _ := s.GetServiceQuota(quota)
 
 			if q.UsageMetric != nil {
-				usage, err := r.cw.GetUsageFromMetric(q.UsageMetric)
-				if err != nil {
-					return nil, fmt.Errorf("Error while getting usage metric: %v", err)
-				}
-
-				quotaMetricUsage[quota] = *usage
+				jobs = append(jobs, r.newMetricUsageJob(service, quota, q.UsageMetric, q.GetPeriod()))
 			}
 		}
 	}
 
-	return &quotaMetricUsage, nil
+	return jobs, nil
 }
 
 // updates usage for quotas
 func (r *Runner) UpdateQuotasUsage() error {
-	var err error
-	r.quotaMetricUsage, err = r.getQuotaMetricUsage()
+	return r.UpdateQuotasUsageWithContext(context.Background())
+}
+
+// updates usage for quotas, requests are made with the context and limited by timeouts
+func (r *Runner) UpdateQuotasUsageWithContext(ctx context.Context) error {
+	ctx, cancel := r.withOverallTimeout(ctx)
+	defer cancel()
+
+	return r.updateQuotasUsage(ctx)
+}
+
+// gets usage for quotas from cloudwatch metrics and services API concurrently,
+// errors of single quotas are kept with partial results, error is returned only if collection is cancelled
+func (r *Runner) updateQuotasUsage(ctx context.Context) error {
+	metricJobs, err := r.getQuotaMetricUsageJobs(ctx)
 	if err != nil {
-		return fmt.Errorf("Error while getting usage for quotas from cloudwatch metrics: %v", err)
+		return fmt.Errorf("Error while getting usage for quotas from cloudwatch metrics: %w", err)
 	}
 
-	r.quotaApiUsage, err = r.getQuotaApiUsage()
+	jobs := append(metricJobs, r.getQuotaApiUsageJobs()...)
+	results, err := r.runUsageJobs(ctx, jobs)
+
+	r.resetQuotasUsage()
+	r.storeUsageResults(jobs, results)
+	r.quotaServiceCodes = r.quotas.ListServicesCodes()
+	r.quotasServiceInfo = r.createQuotasServiceInfo()
+	r.recordHistory()
+	r.refreshSnapshot()
+
 	if err != nil {
-		return fmt.Errorf("Error while getting usage for quotas from api: %v", err)
+		return fmt.Errorf("Error while getting usage for quotas: %w", err)
 	}
 
-	r.quotaUsage = utils.MergeMaps(*r.quotaMetricUsage, *r.quotaApiUsage)
-
 	return nil
 }
 
+// drops usage of all quotas and errors of getting it
+func (r *Runner) resetQuotasUsage() {
+	r.quotaMetricUsage = &map[string]float64{}
+	r.quotaApiUsage = &map[string]float64{}
+	r.quotaMetricErrors = &map[string]QuotaError{}
+	r.quotaApiErrors = &map[string]QuotaError{}
+	r.quotaTags = &map[string]map[string]string{}
+	r.quotaTagsErrors = &map[string]QuotaError{}
+	r.historyErrors = &map[string]QuotaError{}
+	r.quotasServiceInfo = &map[string]string{}
+}
+
+// keeps usage or error of every job by quota code and time of the collection
+func (r *Runner) storeUsageResults(jobs []usageJob, results []usageResult) {
+	r.collectedAt = time.Now().UTC()
+	for i, job := range jobs {
+		usageMap, errorsMap := *r.quotaApiUsage, *r.quotaApiErrors
+		if job.source == "metrics" {
+			usageMap, errorsMap = *r.quotaMetricUsage, *r.quotaMetricErrors
+		}
+
+		if results[i].err != nil {
+			errorsMap[job.quotaCode] = QuotaError{ServiceCode: job.serviceCode, QuotaCode: job.quotaCode, Source: job.source, Err: results[i].err}
+			delete(usageMap, job.quotaCode)
+		} else {
+			usageMap[job.quotaCode] = *results[i].usage
+			delete(errorsMap, job.quotaCode)
+		}
+	}
+}
+
 // updates info for quotas
-func (r *Runner) UpdateQuotasInfo() (err error) {
-	r.quotas, err = quotas.NewQuota(r.region, r.allowedServices)
+func (r *Runner) UpdateQuotasInfo() error {
+	return r.UpdateQuotasInfoWithContext(context.Background())
+}
+
+// updates info for quotas, requests are made with the context and limited by overall timeout
+func (r *Runner) UpdateQuotasInfoWithContext(ctx context.Context) error {
+	ctx, cancel := r.withOverallTimeout(ctx)
+	defer cancel()
+
+	q, err := r.newQuotas(ctx)
+	if err != nil {
+		return fmt.Errorf("Error while creating quota client: %w", err)
+	}
+
+	r.quotas = q
 	r.quotaServiceCodes = r.quotas.ListServicesCodes()
+	r.quotasServiceInfo = r.createQuotasServiceInfo()
+	r.refreshSnapshot()
 
-	return
+	return nil
 }
 
 // returns slice of service codes from quotas
@@ -381,14 +470,14 @@ func (r *Runner) ListSupportedServicesFromQuotas() []string {
 }
 
 // returns service name by its code
-func (r *Runner) GetServiceName(serviceCode string) string {
+func (r *Runner) GetServiceName(serviceCode string) (string, error) {
 	s, err := r.quotas.GetService(serviceCode)
 
 	if err != nil {
-		utils.ExitErrorf("Error while getting service: %v", err)
+		return "", fmt.Errorf("Error while getting service: %w", err)
 	}
 
-	return s.GetServiceName()
+	return s.GetServiceName(), nil
 }
 
 // returns slice of supported quotas codes for service code
@@ -396,7 +485,7 @@ func (r *Runner) GetSupportedQuotasForService(serviceCode string) ([]string, err
 	res, err := r.quotas.ListQuotasCodes(serviceCode)
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while listing quotas: %v", err)
+		return nil, fmt.Errorf("Error while listing quotas: %w", err)
 	}
 
 	return *res, nil
@@ -404,16 +493,21 @@ func (r *Runner) GetSupportedQuotasForService(serviceCode string) ([]string, err
 
 // returns ServiceQuota object by service code and quota code
 func (r *Runner) GetServiceQuota(serviceCode string, quotaCode string) (*ServiceQuota, error) {
-	s, err := r.quotas.GetService(serviceCode)
+	return r.getServiceQuota(context.Background(), serviceCode, quotaCode)
+}
+
+// returns ServiceQuota object by service code and quota code, quotas of the service are loaded with the context if needed
+func (r *Runner) getServiceQuota(ctx context.Context, serviceCode string, quotaCode string) (*ServiceQuota, error) {
+	s, err := r.quotas.GetServiceWithContext(ctx, serviceCode)
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while getting service: %v", err)
+		return nil, fmt.Errorf("Error while getting service: %w", err)
 	}
 
 	q, err := s.GetServiceQuota(quotaCode)
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while getting service quota: %v", err)
+		return nil, fmt.Errorf("Error while getting service quota: %w", err)
 	}
 
 	sq := ServiceQuota{}
@@ -423,81 +517,243 @@ func (r *Runner) GetServiceQuota(serviceCode string, quotaCode string) (*Service
 	sq.QuotaCode = *q.QuotaCode
 	sq.DefaultValue = *q.Value
 	sq.Value = *q.ValueApplied
+	sq.Unit = aws.StringValue(q.Unit)
+	sq.Period = q.GetPeriod()
 	sq.ServiceCode = *q.ServiceCode
 	sq.ServiceName = *q.ServiceName
 
 	return &sq, nil
 }
 
-// returns slice of ServiceQuotaUsage objects
+// returns usage of the quota, it is requested only for the first time or after invalidation
+func (r *Runner) GetQuotaUsage(serviceCode string, quotaCode string) (*ServiceQuotaUsage, error) {
+	return r.GetQuotaUsageWithContext(context.Background(), serviceCode, quotaCode)
+}
+
+// returns usage of the quota, it is requested with the context only for the first time or after invalidation,
+// usage from services API takes precedence over usage from cloudwatch metrics
+func (r *Runner) GetQuotaUsageWithContext(ctx context.Context, serviceCode string, quotaCode string) (*ServiceQuotaUsage, error) {
+	ctx, cancel := r.withOverallTimeout(ctx)
+	defer cancel()
+
+	q, err := r.getServiceQuota(ctx, serviceCode, quotaCode)
+	if err != nil {
+		return nil, err
+	}
+	(*r.quotasServiceInfo)[quotaCode] = serviceCode
+
+	_, apiFound := (*r.quotaApiUsage)[quotaCode]
+	_, metricFound := (*r.quotaMetricUsage)[quotaCode]
+	if !apiFound && !metricFound {
+		jobs := make([]usageJob, 0, 2)
+
+		s, _ := r.quotas.GetServiceWithContext(ctx, serviceCode)
+		if info, _ := s.GetServiceQuota(quotaCode); info.UsageMetric != nil {
+			jobs = append(jobs, r.newMetricUsageJob(serviceCode, quotaCode, info.UsageMetric, info.GetPeriod()))
+		}
+		if p, ok := r.providers[serviceCode]; ok && utils.Find(p.ListQuotasCodes(), quotaCode) {
+			jobs = append(jobs, newApiUsageJob(p, quotaCode))
+		}
+
+		if len(jobs) == 0 {
+			return nil, fmt.Errorf("%w: %v", errs.ErrNotSupported, quotaCode)
+		}
+
+		results, err := r.runUsageJobs(ctx, jobs)
+		r.storeUsageResults(jobs, results)
+		r.refreshSnapshot()
+		if err != nil {
+			return nil, fmt.Errorf("Error while getting usage for quota: %w", err)
+		}
+	}
+
+	squ := r.newServiceQuotaUsage(q)
+
+	return &squ, nil
+}
+
+// returns ServiceQuotaUsage of the quota with usage reconciled from all sources
+func (r *Runner) newServiceQuotaUsage(q *ServiceQuota) ServiceQuotaUsage {
+	info := r.getUsageInfo(q.QuotaCode)
+
+	squ := ServiceQuotaUsage{}
+	squ.ServiceCode = q.ServiceCode
+	squ.ServiceName = q.ServiceName
+	squ.QuotaName = q.QuotaName
+	squ.QuotaCode = q.QuotaCode
+	squ.Value = q.Value
+	squ.Unit = q.Unit
+	squ.Period = q.Period
+	squ.Type = info.source
+	squ.ApiUsage = info.api
+	squ.MetricUsage = info.metric
+	squ.Discrepancy = info.discrepancy
+	if info.usage != nil {
+		squ.Usage = *info.usage
+	}
+	if info.err != nil {
+		squ.Error = info.err
+	}
+
+	return squ
+}
+
+// drops loaded information about quotas of the service and their usage, it is requested again on the next request
+func (r *Runner) Invalidate(serviceCode string) {
+	codes := make([]string, 0, 0)
+	for quota, service := range *r.quotasServiceInfo {
+		if service == serviceCode {
+			codes = append(codes, quota)
+		}
+	}
+	if p, ok := r.providers[serviceCode]; ok {
+		codes = append(codes, p.ListQuotasCodes()...)
+	}
+
+	for _, quota := range codes {
+		delete(*r.quotaApiUsage, quota)
+		delete(*r.quotaMetricUsage, quota)
+		delete(*r.quotaApiErrors, quota)
+		delete(*r.quotaMetricErrors, quota)
+		delete(*r.quotasServiceInfo, quota)
+	}
+
+	r.quotas.Invalidate(serviceCode)
+	r.quotaServiceCodes = r.quotas.ListServicesCodes()
+	r.refreshSnapshot()
+}
+
+// drops loaded information about all quotas and their usage, it is requested again on the next request
+func (r *Runner) InvalidateAll() {
+	r.resetQuotasUsage()
+
+	r.quotas.InvalidateAll()
+	r.quotaServiceCodes = r.quotas.ListServicesCodes()
+	r.refreshSnapshot()
+}
+
+// returns slice of ServiceQuotaUsage objects sorted by quota code for quotas which usage is found in any source,
+// quotas which usage can't be found in any source have Error set
 func (r *Runner) GetQuotasUsage() []ServiceQuotaUsage {
-	squs := make([]ServiceQuotaUsage, 0, len(*r.quotaApiUsage))
-
-	for k, v := range *r.quotaApiUsage {
-		squ := ServiceQuotaUsage{}
-
-		serviceCode, _ := (*r.quotasServiceInfo)[k]
-		q, _ := r.GetServiceQuota(serviceCode, k)
-		var infoType string
-		if _, ok := (*r.quotaUsage)[k]; ok {
-			infoType = "api"
-		} else if _, ok := (*r.quotaMetricUsage)[k]; ok {
-			infoType = "metrics"
+	codes := make([]string, 0, len(*r.quotaApiUsage)+len(*r.quotaMetricUsage))
+	for _, m := range []map[string]float64{*r.quotaApiUsage, *r.quotaMetricUsage} {
+		for k := range m {
+			codes = append(codes, k)
+		}
+	}
+	for _, m := range []map[string]QuotaError{*r.quotaApiErrors, *r.quotaMetricErrors} {
+		for k := range m {
+			codes = append(codes, k)
 		}
+	}
+	sort.Strings(codes)
 
-		squ.ServiceCode = serviceCode
-		squ.ServiceName = r.GetServiceName(serviceCode)
-		squ.QuotaName = q.QuotaName
-		squ.QuotaCode = k
-		squ.Usage = v
-		squ.Value = int(q.Value)
-		squ.Type = infoType
+	squs := make([]ServiceQuotaUsage, 0, len(codes))
+
+	for i, k := range codes {
+		if i > 0 && codes[i-1] == k {
+			continue
+		}
 
-		squs = append(squs, squ)
+		serviceCode, ok := (*r.quotasServiceInfo)[k]
+		if !ok {
+			continue
+		}
+		q, err := r.GetServiceQuota(serviceCode, k)
+		if err != nil {
+			continue
+		}
+
+		squs = append(squs, r.newServiceQuotaUsage(q))
 	}
 
 	return squs
 }
 
-// checks for alarms and returns slice of warnings objects
+// checks for alarms and returns slice of warnings objects, suppressed alarms are skipped
 func (r *Runner) CheckAlarms() []Warning {
+	return r.CheckAlarmsWithContext(context.Background())
+}
+
+// checks for alarms and returns slice of warnings objects, tags of quotas are requested with the context,
+// suppressed alarms are skipped
+func (r *Runner) CheckAlarmsWithContext(ctx context.Context) []Warning {
+	return WarningsFrom(r.CheckQuotasWithContext(ctx))
+}
+
+// checks for alarms and returns slice of warnings of alarms which are suppressed by silences
+func (r *Runner) CheckSuppressedAlarms() []Warning {
+	return r.CheckSuppressedAlarmsWithContext(context.Background())
+}
+
+// checks for alarms and returns slice of warnings of alarms which are suppressed by silences,
+// tags of quotas are requested with the context
+func (r *Runner) CheckSuppressedAlarmsWithContext(ctx context.Context) []Warning {
+	return SuppressedWarningsFrom(r.CheckQuotasWithContext(ctx))
+}
+
+// returns warnings of raised alarms of results of CheckQuotas which are not suppressed, so that callers which need
+// both results and warnings check quotas once
+func WarningsFrom(results []CheckResult) []Warning {
 	warnings := make([]Warning, 0, 0)
 
-	squs := r.GetQuotasUsage()
-
-	for _, squ := range squs {
-		warning := Warning{}
-		max := 0
-		warning.Limit = squ.Value
-		warning.Usage = squ.Usage
-		warning.ServiceCode = squ.ServiceCode
-		warning.ServiceName = squ.ServiceName
-		warning.QuotaName = squ.QuotaName
-		warning.QuotaCode = squ.QuotaCode
-		found := false
-		for k, v := range r.alarms {
-			percents := float64(squ.Usage) * 100.0 / float64(squ.Value)
-			if percents >= float64(v) && v > max {
-				found = true
-				max = v
-				warning.Name = k
-				warning.Threshold = v
-			}
+	for _, result := range results {
+		if result.Severity == SeverityOK || result.Silence != nil {
+			continue
 		}
-		if found {
-			warnings = append(warnings, warning)
+		warnings = append(warnings, newWarning(result))
+	}
+
+	return warnings
+}
+
+// returns warnings of raised alarms of results of CheckQuotas which are suppressed by silences
+func SuppressedWarningsFrom(results []CheckResult) []Warning {
+	warnings := make([]Warning, 0, 0)
+
+	for _, result := range results {
+		if result.Severity == SeverityOK || result.Silence == nil {
+			continue
 		}
+		warning := newWarning(result)
+		warning.Silence = result.Silence
+		warnings = append(warnings, warning)
 	}
 
 	return warnings
 }
 
+// creates warning of the raised alarm of the result
+func newWarning(result CheckResult) Warning {
+	warning := Warning{}
+	warning.Limit = result.Value
+	warning.Usage = result.Usage
+	warning.Unit = result.Unit
+	warning.Period = result.Period
+	warning.ServiceCode = result.ServiceCode
+	warning.ServiceName = result.ServiceName
+	warning.QuotaName = result.QuotaName
+	warning.QuotaCode = result.QuotaCode
+	warning.Name = result.Alarm
+	warning.Threshold = result.Threshold
+	warning.Severity = result.Severity
+	warning.Remaining = result.Remaining
+	warning.RemainingThreshold = result.RemainingThreshold
+	warning.Forecast = result.Forecast
+	warning.ExhaustionThreshold = result.ExhaustionThreshold
+	warning.Condition = result.Condition
+
+	return warning
+}
+
 // prints Warning object
 func (w Warning) Print() {
-	fmt.Println("Limit: ", w.Limit)
-	fmt.Println("Usage: ", w.Usage)
+	fmt.Println("Limit: ", units.FormatRate(w.Limit, w.Unit, w.Period))
+	fmt.Println("Usage: ", units.FormatRate(w.Usage, w.Unit, w.Period))
 	fmt.Println("Name: ", w.Name)
 	fmt.Println("Threshold: ", w.Threshold)
+	fmt.Println("Severity: ", w.Severity)
+	fmt.Println("Condition: ", w.Condition)
 	fmt.Println("Service code: ", w.ServiceCode)
 	fmt.Println("Service name: ", w.ServiceName)
 	fmt.Println("Quota name: ", w.QuotaName)
@@ -510,9 +766,21 @@ func (squ ServiceQuotaUsage) Print() {
 	fmt.Println("ServiceName: ", squ.ServiceName)
 	fmt.Println("QuotaName: ", squ.QuotaName)
 	fmt.Println("QuotaCode: ", squ.QuotaCode)
-	fmt.Println("Usage: ", squ.Usage)
-	fmt.Println("Value: ", squ.Value)
+	fmt.Println("Usage: ", units.FormatRate(squ.Usage, squ.Unit, squ.Period))
+	fmt.Println("Value: ", units.FormatRate(squ.Value, squ.Unit, squ.Period))
 	fmt.Println("Type: ", squ.Type)
+	if squ.ApiUsage != nil {
+		fmt.Println("ApiUsage: ", units.FormatRate(*squ.ApiUsage, squ.Unit, squ.Period))
+	}
+	if squ.MetricUsage != nil {
+		fmt.Println("MetricUsage: ", units.FormatRate(*squ.MetricUsage, squ.Unit, squ.Period))
+	}
+	if squ.Discrepancy {
+		fmt.Println("Discrepancy: ", squ.Discrepancy)
+	}
+	if squ.Error != nil {
+		fmt.Println("Error: ", squ.Error)
+	}
 }
 
 // prints ServiceQuota object
@@ -521,54 +789,44 @@ func (sq *ServiceQuota) Print() {
 	fmt.Println("GlobalQuota: ", sq.GlobalQuota)
 	fmt.Println("QuotaName: ", sq.QuotaName)
 	fmt.Println("QuotaCode: ", sq.QuotaCode)
-	fmt.Println("DefaultValue: ", sq.DefaultValue)
-	fmt.Println("Value: ", sq.Value)
+	fmt.Println("DefaultValue: ", units.FormatRate(sq.DefaultValue, sq.Unit, sq.Period))
+	fmt.Println("Value: ", units.FormatRate(sq.Value, sq.Unit, sq.Period))
 	fmt.Println("ServiceCode: ", sq.ServiceCode)
 	fmt.Println("ServiceName: ", sq.ServiceName)
 }
 
-// returns summary of actions which are needed to work with the packages
-func GetIam(allowedServices *map[string]*[]string) iamActions {
+// returns summary of actions which are needed to work with the packages for quotas selected by selector
+func GetIam(sel *selector.Selector) iamActions {
 	serviceActions := make(iamActions)
 
 	serviceActions["quotas"] = quotas.GetIam()
-	serviceActions["cloudwatch"] = quotas.GetIam()
-
-	actionsWithQuotas := make(map[string]map[string]string)
-	actionsWithQuotas["autoscaling"] = autoscaling.GetIam()
-	actionsWithQuotas["cloudformation"] = cloudformation.GetIam()
-	actionsWithQuotas["ec2"] = ec2.GetIam()
-	actionsWithQuotas["elasticfilesystem"] = efs.GetIam()
-	actionsWithQuotas["elasticbeanstalk"] = elasticbeanstalk.GetIam()
-	actionsWithQuotas["elasticloadbalancing"] = elb.GetIam()
-	actionsWithQuotas["s3"] = s3.GetIam()
-	actionsWithQuotas["vpc"] = vpc.GetIam()
-
-	for service, quotas := range actionsWithQuotas {
-		isServiceAllowed := true
-		var allowedQuotas *[]string
-		var serviceAvailable bool
-
-		if allowedServices != nil {
-			if allowedQuotas, serviceAvailable = (*allowedServices)[service]; !serviceAvailable {
-				isServiceAllowed = false
-			}
+	serviceActions["cloudwatch"] = cloudwatch.GetIam()
+
+	// session is needed only to create providers, requests are not made
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            aws.Config{Credentials: credentials.AnonymousCredentials},
+		SharedConfigState: session.SharedConfigDisable,
+	})
+	if err != nil {
+		return serviceActions
+	}
+
+	for _, service := range providers.ListCodes() {
+		if !sel.MatchService(service) {
+			continue
 		}
 
-		if isServiceAllowed {
-			actions := make([]string, 0, 0)
-			for quota, action := range quotas {
-				isQuotaAllowed := true
-				if allowedQuotas != nil && !utils.Find(*allowedQuotas, quota) {
-					isQuotaAllowed = false
-				}
-
-				if isQuotaAllowed {
-					actions = append(actions, action)
-				}
-			}
-			serviceActions[service] = actions
+		p, err := providers.NewProvider(service, sess, sel)
+		if err != nil {
+			continue
+		}
+
+		actions := make([]string, 0, 0)
+		quotaActions := p.GetIam()
+		for _, quota := range p.ListQuotasCodes() {
+			actions = append(actions, quotaActions[quota])
 		}
+		serviceActions[service] = actions
 	}
 
 	return serviceActions
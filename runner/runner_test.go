@@ -0,0 +1,350 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/cloudwatch"
+	"github.com/vslchnk/aws_quotas_checker/errs"
+	"github.com/vslchnk/aws_quotas_checker/fakes"
+	"github.com/vslchnk/aws_quotas_checker/quotas"
+	"github.com/vslchnk/aws_quotas_checker/services"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	cw "github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+)
+
+// fake clients and providers of the test runner
+type testClients struct {
+	quotas    *fakes.ServiceQuotas
+	metrics   *fakes.CloudWatch
+	providers []*fakes.Provider
+}
+
+func newTestClients() *testClients {
+	metricQuota := fakes.NewServiceQuota("ec2", "L-3", 10)
+	metricQuota.UsageMetric = &servicequotas.MetricInfo{
+		MetricName:                    aws.String("ResourceCount"),
+		MetricNamespace:               aws.String("AWS/Usage"),
+		MetricStatisticRecommendation: aws.String("Maximum"),
+	}
+
+	c := testClients{}
+	c.quotas = &fakes.ServiceQuotas{
+		ServicesPages: [][]*servicequotas.ServiceInfo{{fakes.NewServiceInfo("ec2"), fakes.NewServiceInfo("vpc")}},
+		DefaultQuotasPages: map[string][][]*servicequotas.ServiceQuota{
+			"ec2": {{fakes.NewServiceQuota("ec2", "L-1", 5), fakes.NewServiceQuota("ec2", "L-2", 5), metricQuota}},
+			"vpc": {{fakes.NewServiceQuota("vpc", "L-4", 10)}},
+		},
+	}
+	c.metrics = &fakes.CloudWatch{Datapoints: map[string][]*cw.Datapoint{
+		"ResourceCount": {{Maximum: aws.Float64(7)}},
+	}}
+	c.providers = []*fakes.Provider{
+		{
+			Code:   "ec2",
+			Usage:  map[string]float64{"L-1": 4},
+			Errors: map[string]error{"L-2": awserr.New("RequestLimitExceeded", "rate exceeded", nil)},
+		},
+		{Code: "vpc", Usage: map[string]float64{"L-4": 1}},
+		{Code: "unknown", Usage: map[string]float64{"L-5": 1}},
+	}
+
+	return &c
+}
+
+// creates runner with fake clients and providers the same way as NewRunnerWithOptions does
+func newTestRunner(t *testing.T, c *testClients, opts ...Option) *Runner {
+	t.Helper()
+
+	r := Runner{}
+	r.region = "us-east-2"
+	r.account = "123456789012"
+	r.options = defaultOptions()
+	for _, opt := range opts {
+		opt(&r.options)
+	}
+
+	r.providers = make(map[string]services.UsageProvider)
+	r.providersErrors = make(map[string]error)
+	providerQuotas := make(map[string][]string)
+	for _, p := range c.providers {
+		r.providers[p.GetCode()] = p
+		providerQuotas[p.GetCode()] = p.ListQuotasCodes()
+	}
+
+	var err error
+	if r.options.Lazy {
+		r.quotas, err = quotas.NewLazyQuotaWithClient(context.Background(), c.quotas, r.selector, quotas.WithProviderQuotas(providerQuotas))
+	} else {
+		r.quotas, err = quotas.NewQuotaWithClient(context.Background(), c.quotas, r.selector, quotas.WithProviderQuotas(providerQuotas))
+	}
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r.quotaServiceCodes = r.quotas.ListServicesCodes()
+
+	r.cw = cloudwatch.NewCWWithClient(c.metrics)
+	r.cw.SetLookback(r.options.RateLookback)
+
+	if r.history, err = r.newHistory(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if r.options.Lazy {
+		r.resetQuotasUsage()
+		r.refreshSnapshot()
+		return &r
+	}
+
+	if err := r.updateQuotasUsage(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return &r
+}
+
+func TestUpdateQuotasUsageMerging(t *testing.T) {
+	for _, workers := range []int{0, 1, 4} {
+		r := newTestRunner(t, newTestClients(), WithWorkers(workers))
+
+		wantApi := map[string]float64{"L-1": 4, "L-4": 1, "L-5": 1}
+		wantMetric := map[string]float64{"L-3": 7}
+		if !reflect.DeepEqual(*r.quotaApiUsage, wantApi) || !reflect.DeepEqual(*r.quotaMetricUsage, wantMetric) {
+			t.Errorf("workers %v: usage = %v/%v, want %v/%v", workers, *r.quotaApiUsage, *r.quotaMetricUsage, wantApi, wantMetric)
+		}
+	}
+}
+
+func TestGetQuotasUsage(t *testing.T) {
+	r := newTestRunner(t, newTestClients())
+
+	tests := []struct {
+		quotaCode string
+		usage     float64
+		value     float64
+		wantErr   error
+	}{
+		{quotaCode: "L-1", usage: 4, value: 5},
+		{quotaCode: "L-2", wantErr: errs.ErrThrottled},
+		{quotaCode: "L-3", usage: 7, value: 10},
+		{quotaCode: "L-4", usage: 1, value: 10},
+	}
+
+	squs := r.GetQuotasUsage()
+	if len(squs) != len(tests) {
+		t.Fatalf("usage = %+v, want %v quotas", squs, len(tests))
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.quotaCode, func(t *testing.T) {
+			squ := squs[i]
+			if squ.QuotaCode != tt.quotaCode {
+				t.Fatalf("quota code = %v, want %v", squ.QuotaCode, tt.quotaCode)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(squ.Error, tt.wantErr) {
+					t.Errorf("error = %v, want %v", squ.Error, tt.wantErr)
+				}
+				return
+			}
+			if squ.Error != nil {
+				t.Errorf("unexpected error: %v", squ.Error)
+			}
+			if squ.Usage != tt.usage || squ.Value != tt.value {
+				t.Errorf("usage/value = %v/%v, want %v/%v", squ.Usage, squ.Value, tt.usage, tt.value)
+			}
+		})
+	}
+}
+
+func TestGetErrors(t *testing.T) {
+	r := newTestRunner(t, newTestClients())
+
+	quotaErrors := r.GetErrors()
+	if len(quotaErrors) != 1 {
+		t.Fatalf("errors = %v, want 1 error", quotaErrors)
+	}
+
+	e := quotaErrors[0]
+	if e.ServiceCode != "ec2" || e.QuotaCode != "L-2" || e.Source != "api" {
+		t.Errorf("error = %+v, want api error of ec2 quota L-2", e)
+	}
+	if !errors.Is(e, errs.ErrThrottled) {
+		t.Errorf("error = %v, want %v", e, errs.ErrThrottled)
+	}
+}
+
+func TestCheckAlarms(t *testing.T) {
+	r := newTestRunner(t, newTestClients())
+	r.AddAlarm("warning", 50)
+	r.AddAlarm("critical", 80)
+
+	tests := []struct {
+		quotaCode string
+		name      string
+		threshold int
+	}{
+		{"L-1", "critical", 80},
+		// usage of L-3 is found only in cloudwatch metrics
+		{"L-3", "warning", 50},
+	}
+
+	warnings := r.CheckAlarms()
+	if len(warnings) != len(tests) {
+		t.Fatalf("warnings = %+v, want %v warnings", warnings, len(tests))
+	}
+
+	for i, tt := range tests {
+		w := warnings[i]
+		if w.QuotaCode != tt.quotaCode || w.Name != tt.name || w.Threshold != tt.threshold {
+			t.Errorf("warning = %+v, want %v warning for %v", w, tt.name, tt.quotaCode)
+		}
+	}
+
+	if got := WarningsFrom(r.CheckQuotas()); !reflect.DeepEqual(got, warnings) {
+		t.Errorf("warnings of results = %+v, want %+v", got, warnings)
+	}
+}
+
+func TestUpdateQuotasUsageCanceled(t *testing.T) {
+	r := newTestRunner(t, newTestClients())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := r.updateQuotasUsage(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want %v", err, context.Canceled)
+	}
+	if !errors.Is((*r.quotaApiErrors)["L-1"], errs.ErrCanceled) {
+		t.Errorf("error of L-1 = %v, want %v", (*r.quotaApiErrors)["L-1"], errs.ErrCanceled)
+	}
+}
+
+func TestLazyGetQuotaUsage(t *testing.T) {
+	c := newTestClients()
+	r := newTestRunner(t, c, WithLazyLoading())
+	ec2 := c.providers[0]
+
+	steps := []struct {
+		name      string
+		quotaCode string
+		// runs before getting usage
+		before    func()
+		wantUsage float64
+		wantType  string
+		wantErr   error
+		// number of usage requests of the quota to the provider after the step
+		wantCalls int
+	}{
+		{name: "first request", quotaCode: "L-1", wantUsage: 4, wantType: "api", wantCalls: 1},
+		{name: "memoized", quotaCode: "L-1", wantUsage: 4, wantType: "api", wantCalls: 1},
+		{name: "invalidated", quotaCode: "L-1", before: func() { r.Invalidate("ec2") }, wantUsage: 4, wantType: "api", wantCalls: 2},
+		{name: "metric only", quotaCode: "L-3", wantUsage: 7, wantType: "metrics"},
+		{name: "error", quotaCode: "L-2", wantErr: errs.ErrThrottled, wantCalls: 1},
+		{name: "error is not memoized", quotaCode: "L-2", wantErr: errs.ErrThrottled, wantCalls: 2},
+	}
+
+	for _, step := range steps {
+		if step.before != nil {
+			step.before()
+		}
+
+		squ, err := r.GetQuotaUsage("ec2", step.quotaCode)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", step.name, err)
+		}
+		if step.wantErr != nil {
+			if !errors.Is(squ.Error, step.wantErr) {
+				t.Errorf("%v: error = %v, want %v", step.name, squ.Error, step.wantErr)
+			}
+		} else if squ.Usage != step.wantUsage || squ.Type != step.wantType {
+			t.Errorf("%v: usage = %v (%v), want %v (%v)", step.name, squ.Usage, squ.Type, step.wantUsage, step.wantType)
+		}
+		if calls := ec2.Calls[step.quotaCode]; calls != step.wantCalls {
+			t.Errorf("%v: calls = %v, want %v", step.name, calls, step.wantCalls)
+		}
+	}
+
+	if calls := c.quotas.Calls["ListAWSDefaultServiceQuotas"]; calls != 2 {
+		t.Errorf("quotas of services were listed %v times, want 2 for ec2 only", calls)
+	}
+
+	if _, err := r.GetQuotaUsage("vpc", "L-0"); !errors.Is(err, errs.ErrUnknownQuota) {
+		t.Errorf("error = %v, want %v", err, errs.ErrUnknownQuota)
+	}
+}
+
+func TestFractionalUsage(t *testing.T) {
+	c := newTestClients()
+	c.metrics.Datapoints["ResourceCount"][0].Maximum = aws.Float64(7.5)
+	metricQuota := c.quotas.DefaultQuotasPages["ec2"][0][2]
+	metricQuota.Unit = aws.String("Gigabytes")
+
+	r := newTestRunner(t, c)
+	r.AddAlarm("warning", 75)
+	r.AddAlarm("critical", 76)
+
+	squ, err := r.GetQuotaUsage("ec2", "L-3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if squ.Usage != 7.5 || squ.Value != 10 || squ.Unit != "Gigabytes" {
+		t.Errorf("usage = %v/%v %v, want 7.5/10 Gigabytes", squ.Usage, squ.Value, squ.Unit)
+	}
+
+	warnings := r.CheckAlarms()
+	if len(warnings) != 2 || warnings[1].QuotaCode != "L-3" || warnings[1].Name != "warning" || warnings[1].Unit != "Gigabytes" {
+		t.Errorf("warnings = %+v, want warning for 75%% of L-3", warnings)
+	}
+}
+
+func TestRateQuotaUsage(t *testing.T) {
+	c := newTestClients()
+	rateQuota := fakes.NewServiceQuota("vpc", "L-6", 50)
+	rateQuota.Period = &servicequotas.QuotaPeriod{PeriodValue: aws.Int64(1), PeriodUnit: aws.String("SECOND")}
+	rateQuota.UsageMetric = &servicequotas.MetricInfo{
+		MetricName:                    aws.String("CallCount"),
+		MetricNamespace:               aws.String("AWS/Usage"),
+		MetricStatisticRecommendation: aws.String("Sum"),
+	}
+	c.quotas.DefaultQuotasPages["vpc"][0] = append(c.quotas.DefaultQuotasPages["vpc"][0], rateQuota)
+	c.metrics.Datapoints["CallCount"] = []*cw.Datapoint{{Sum: aws.Float64(600)}, {Sum: aws.Float64(1800)}, {Sum: aws.Float64(60)}}
+
+	r := newTestRunner(t, c, WithRateLookback(2*time.Hour))
+	r.AddAlarm("warning", 60)
+
+	squ, err := r.GetQuotaUsage("vpc", "L-6")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if squ.Usage != 30 || squ.Period != time.Second || squ.Type != "metrics" {
+		t.Errorf("usage = %v per %v from %v, want peak 30 per second from metrics", squ.Usage, squ.Period, squ.Type)
+	}
+
+	var input *cw.GetMetricStatisticsInput
+	for _, in := range c.metrics.Inputs {
+		if aws.StringValue(in.MetricName) == "CallCount" {
+			input = in
+		}
+	}
+	if input == nil || aws.Int64Value(input.Period) != 60 || input.EndTime.Sub(*input.StartTime) != 2*time.Hour {
+		t.Errorf("input = %v, want 60 seconds period over 2 hours", input)
+	}
+
+	warnings := r.CheckAlarms()
+	if len(warnings) != 3 || warnings[2].QuotaCode != "L-6" || warnings[2].Period != time.Second {
+		t.Errorf("warnings = %+v, want warning for L-6", warnings)
+	}
+
+	q, err := r.Snapshot().GetQuota("vpc", "L-6")
+	if err != nil || q.Period != "1s" {
+		t.Errorf("snapshot quota = %+v, %v, want period 1s", q, err)
+	}
+}
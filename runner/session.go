@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// creates session for the region which is shared by all clients of runner agent
+func newSession(region string, o *Options) (*session.Session, error) {
+	config := aws.Config{}
+	if region != "" {
+		config.Region = aws.String(region)
+	}
+	if o.Credentials != nil {
+		config.Credentials = o.Credentials
+	}
+	if o.HTTPClient != nil {
+		config.HTTPClient = o.HTTPClient
+	}
+	if o.EndpointResolver != nil || len(o.Endpoints) > 0 {
+		config.EndpointResolver = newEndpointResolver(o.EndpointResolver, o.Endpoints)
+	}
+
+	var sess *session.Session
+	var err error
+
+	if o.Session != nil {
+		sess = o.Session.Copy(&config)
+	} else {
+		sharedConfigState := session.SharedConfigStateFromEnv
+		if o.Profile != "" {
+			sharedConfigState = session.SharedConfigEnable
+		}
+
+		sess, err = session.NewSessionWithOptions(session.Options{
+			Config:            config,
+			Profile:           o.Profile,
+			SharedConfigState: sharedConfigState,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Error while creating session: %w", err)
+		}
+	}
+
+	if o.AssumeRole != nil {
+		role := *o.AssumeRole
+		creds := stscreds.NewCredentials(sess, role.RoleARN, func(p *stscreds.AssumeRoleProvider) {
+			if role.ExternalID != "" {
+				p.ExternalID = aws.String(role.ExternalID)
+			}
+			if role.SessionName != "" {
+				p.RoleSessionName = role.SessionName
+			}
+			if role.Duration > 0 {
+				p.Duration = role.Duration
+			}
+		})
+		sess = sess.Copy(&aws.Config{Credentials: creds})
+	}
+
+	return sess, nil
+}
+
+// returns resolver which uses URLs by endpoint ID and falls back to the base resolver or the default one
+func newEndpointResolver(base endpoints.Resolver, urls map[string]string) endpoints.Resolver {
+	if base == nil {
+		base = endpoints.DefaultResolver()
+	}
+
+	overrides := make(map[string]string, len(urls))
+	for k, v := range urls {
+		overrides[k] = v
+	}
+
+	return endpoints.ResolverFunc(func(service, region string, opts ...func(*endpoints.Options)) (endpoints.ResolvedEndpoint, error) {
+		if url, ok := overrides[service]; ok {
+			return endpoints.ResolvedEndpoint{
+				URL:           url,
+				SigningRegion: region,
+			}, nil
+		}
+
+		return base.EndpointFor(service, region, opts...)
+	})
+}
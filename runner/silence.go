@@ -0,0 +1,210 @@
+package runner
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/selector"
+)
+
+// SilenceKind is kind of the silence which is shown in reports of suppressed alarms
+type SilenceKind string
+
+// kinds of silences
+const (
+	// silence is active between start and end, e.g. during planned migration
+	KindSilence SilenceKind = "silence"
+	// silence is active only within recurring maintenance windows between start and end
+	KindMaintenance SilenceKind = "maintenance"
+	// raised alarms are acknowledged by the owner, it is the same as silence but owner is required
+	KindAcknowledgement SilenceKind = "acknowledgement"
+)
+
+// Window is recurring time window, e.g. every Saturday from 02:00 for 4 hours
+type Window struct {
+	// days of the week when windows start, windows start every day if it is empty
+	Weekdays []time.Weekday
+	// time of the day when windows start as offset from midnight, it is less than 24 hours
+	Start    time.Duration
+	Duration time.Duration
+	// location of the start time, UTC is used if it is nil
+	Location *time.Location
+}
+
+// returns end of the window which contains the time
+func (w Window) contains(t time.Time) (time.Time, bool) {
+	loc := w.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+
+	// windows which started on previous days can still be open
+	for days := 0; days <= int(w.Duration/(24*time.Hour))+1; days++ {
+		day := time.Date(t.Year(), t.Month(), t.Day()-days, 0, 0, 0, 0, loc)
+		if !w.startsOn(day.Weekday()) {
+			continue
+		}
+
+		start := day.Add(w.Start)
+		end := start.Add(w.Duration)
+		if !t.Before(start) && t.Before(end) {
+			return end, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// checks if windows start on the day of the week
+func (w Window) startsOn(day time.Weekday) bool {
+	if len(w.Weekdays) == 0 {
+		return true
+	}
+	for _, d := range w.Weekdays {
+		if d == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Silence suppresses alarms of matching quotas while it is active, suppressed alarms are still checked and
+// reported as suppressed but they don't define MaxSeverity, they are skipped by CheckAlarms and alerts of them
+// are not fired or resolved
+type Silence struct {
+	// unique name of the silence
+	Name string
+	// KindMaintenance is used if window is set, KindSilence otherwise
+	Kind SilenceKind
+	// globs of service and quota codes and regular expression of quota name of silenced quotas
+	Scope selector.Rule
+	// glob of names of silenced alarms, alarms with any name are silenced if it is empty
+	Alarm string
+	// silence is active from start until end, zero start means that it is active already and
+	// zero end means that it never expires
+	Start time.Time
+	End   time.Time
+	// recurring windows of maintenance, it is required for maintenance and is not allowed for other kinds
+	Window *Window
+	// who created the silence and why, owner is required for acknowledgements
+	Owner   string
+	Comment string
+	// the most severe suppressed alarm, e.g. acknowledged warning doesn't suppress critical alarm of the quota,
+	// SeverityOK means alarms of any severity
+	Severity Severity
+}
+
+// returns true if the silence is active at the time and time when it ends, zero time if it never ends
+func (s Silence) ActiveAt(t time.Time) (time.Time, bool) {
+	if !s.Start.IsZero() && t.Before(s.Start) {
+		return time.Time{}, false
+	}
+	if !s.End.IsZero() && !t.Before(s.End) {
+		return time.Time{}, false
+	}
+	if s.Window == nil {
+		return s.End, true
+	}
+
+	end, ok := s.Window.contains(t)
+	if !ok {
+		return time.Time{}, false
+	}
+	if !s.End.IsZero() && s.End.Before(end) {
+		end = s.End
+	}
+
+	return end, true
+}
+
+// checks if the raised alarm of the result is suppressed by the silence at the time
+func (s Silence) suppresses(result CheckResult, t time.Time) bool {
+	if result.Severity == SeverityOK || (s.Severity != SeverityOK && result.Severity > s.Severity) {
+		return false
+	}
+	if s.Alarm != "" {
+		if ok, _ := path.Match(s.Alarm, result.Alarm); !ok {
+			return false
+		}
+	}
+	if !s.Scope.Match(selector.Quota{ServiceCode: result.ServiceCode, QuotaCode: result.QuotaCode, QuotaName: result.QuotaName}) {
+		return false
+	}
+	_, ok := s.ActiveAt(t)
+
+	return ok
+}
+
+// adds silence, alarms are suppressed by the first added matching silence which is active at the time of the check
+func (r *Runner) AddSilence(s Silence) error {
+	if s.Name == "" {
+		return fmt.Errorf("Error while adding silence: name is empty")
+	}
+	for _, current := range r.silences {
+		if current.Name == s.Name {
+			return fmt.Errorf("Error while adding silence %v: duplicated name", s.Name)
+		}
+	}
+	if s.Kind == "" {
+		s.Kind = KindSilence
+		if s.Window != nil {
+			s.Kind = KindMaintenance
+		}
+	}
+	switch s.Kind {
+	case KindSilence, KindAcknowledgement:
+		if s.Window != nil {
+			return fmt.Errorf("Error while adding silence %v: window is allowed only for maintenance", s.Name)
+		}
+	case KindMaintenance:
+		if s.Window == nil {
+			return fmt.Errorf("Error while adding silence %v: window of maintenance is not set", s.Name)
+		}
+	default:
+		return fmt.Errorf("Error while adding silence %v: unknown kind %q", s.Name, s.Kind)
+	}
+	if s.Kind == KindAcknowledgement && s.Owner == "" {
+		return fmt.Errorf("Error while adding silence %v: owner of acknowledgement is empty", s.Name)
+	}
+	if !s.Start.IsZero() && !s.End.IsZero() && !s.End.After(s.Start) {
+		return fmt.Errorf("Error while adding silence %v: end %v is not after start %v", s.Name, s.End, s.Start)
+	}
+	if w := s.Window; w != nil {
+		if w.Start < 0 || w.Start >= 24*time.Hour {
+			return fmt.Errorf("Error while adding silence %v: start of window %v is not between 0 and 24h", s.Name, w.Start)
+		}
+		if w.Duration <= 0 || w.Duration > 7*24*time.Hour {
+			return fmt.Errorf("Error while adding silence %v: duration of window %v is not between 0 and 7 days", s.Name, w.Duration)
+		}
+	}
+	if _, err := path.Match(s.Alarm, ""); err != nil {
+		return fmt.Errorf("Error while adding silence %v: invalid alarm glob %q: %w", s.Name, s.Alarm, err)
+	}
+	if err := s.Scope.Compile(); err != nil {
+		return fmt.Errorf("Error while adding silence %v: %w", s.Name, err)
+	}
+
+	r.silences = append(r.silences, s)
+
+	return nil
+}
+
+// returns silences in order of adding
+func (r *Runner) GetSilences() []Silence {
+	return append([]Silence{}, r.silences...)
+}
+
+// returns the first silence which suppresses the raised alarm of the result at the time, nil if there is no one
+func (r *Runner) getSilence(result CheckResult, t time.Time) *Silence {
+	for i := range r.silences {
+		if r.silences[i].suppresses(result, t) {
+			s := r.silences[i]
+			return &s
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/selector"
+)
+
+func TestSilenceActiveAt(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone database is not available: %v", err)
+	}
+	// Saturday
+	at := func(day int, hour int, min int) time.Time {
+		return time.Date(2024, 1, 6+day, hour, min, 0, 0, time.UTC)
+	}
+	weekend := &Window{Weekdays: []time.Weekday{time.Saturday}, Start: 22 * time.Hour, Duration: 4 * time.Hour}
+
+	tests := []struct {
+		name    string
+		silence Silence
+		t       time.Time
+		want    bool
+		wantEnd time.Time
+	}{
+		{"without times", Silence{}, at(0, 12, 0), true, time.Time{}},
+		{"before start", Silence{Start: at(0, 12, 0), End: at(0, 14, 0)}, at(0, 11, 59), false, time.Time{}},
+		{"between start and end", Silence{Start: at(0, 12, 0), End: at(0, 14, 0)}, at(0, 12, 0), true, at(0, 14, 0)},
+		{"at end", Silence{Start: at(0, 12, 0), End: at(0, 14, 0)}, at(0, 14, 0), false, time.Time{}},
+		{"within window", Silence{Window: weekend}, at(0, 23, 0), true, at(1, 2, 0)},
+		{"window after midnight", Silence{Window: weekend}, at(1, 1, 30), true, at(1, 2, 0)},
+		{"after window", Silence{Window: weekend}, at(1, 2, 0), false, time.Time{}},
+		{"other day", Silence{Window: weekend}, at(2, 23, 0), false, time.Time{}},
+		{"next week", Silence{Window: weekend}, at(7, 22, 0), true, at(8, 2, 0)},
+		{"window ends after end", Silence{Window: weekend, End: at(1, 1, 0)}, at(0, 23, 0), true, at(1, 1, 0)},
+		{"every day", Silence{Window: &Window{Start: time.Hour, Duration: time.Hour}}, at(3, 1, 30), true, at(3, 2, 0)},
+		// 02:00 in Berlin is 01:00 UTC in winter
+		{"location", Silence{Window: &Window{Start: 2 * time.Hour, Duration: time.Hour, Location: berlin}}, at(0, 1, 30), true, at(0, 2, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			end, ok := tt.silence.ActiveAt(tt.t)
+			if ok != tt.want || !end.Equal(tt.wantEnd) {
+				t.Errorf("active = %v until %v, want %v until %v", ok, end, tt.want, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestSilences(t *testing.T) {
+	// usage of L-1 is 80% and L-3 is 70%
+	tests := []struct {
+		name     string
+		silences []Silence
+		want     map[string]string
+	}{
+		{
+			"quota",
+			[]Silence{{Name: "migration", Scope: selector.Rule{Quota: "L-1"}}},
+			map[string]string{"L-1": "migration"},
+		},
+		{
+			"service and alarm",
+			[]Silence{{Name: "migration", Scope: selector.Rule{Service: "ec2"}, Alarm: "crit*"}},
+			map[string]string{"L-1": "migration"},
+		},
+		{
+			"expired",
+			[]Silence{{Name: "migration", End: time.Now().Add(-time.Minute)}},
+			map[string]string{},
+		},
+		{
+			"acknowledged warning doesn't suppress critical alarm",
+			[]Silence{{Name: "ack", Kind: KindAcknowledgement, Owner: "network", Severity: SeverityWarning}},
+			map[string]string{"L-3": "ack"},
+		},
+		{
+			"the first silence",
+			[]Silence{{Name: "first", Scope: selector.Rule{Quota: "L-3"}}, {Name: "second"}},
+			map[string]string{"L-1": "second", "L-3": "first"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRunner(t, newTestClients())
+			r.AddAlarm("warning", 50)
+			r.AddAlarmWithSeverity("critical", 75, SeverityCritical)
+			for _, s := range tt.silences {
+				if err := r.AddSilence(s); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			results := r.CheckQuotas()
+			got := make(map[string]string)
+			for _, result := range results {
+				if result.Silence != nil {
+					got[result.QuotaCode] = result.Silence.Name
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("suppressed = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("suppressed = %v, want %v", got, tt.want)
+				}
+			}
+
+			if len(r.CheckAlarms())+len(r.CheckSuppressedAlarms()) != 2 {
+				t.Errorf("warnings = %+v, suppressed = %+v, want 2 alarms", r.CheckAlarms(), r.CheckSuppressedAlarms())
+			}
+			if _, ok := tt.want["L-1"]; ok && MaxSeverity(results) == SeverityCritical {
+				t.Errorf("severity = %v, want suppressed critical alarm to be skipped", MaxSeverity(results))
+			}
+		})
+	}
+}
+
+func TestAddSilence(t *testing.T) {
+	now := time.Now()
+	window := &Window{Start: time.Hour, Duration: time.Hour}
+
+	tests := []struct {
+		name    string
+		silence Silence
+		wantErr bool
+	}{
+		{"silence", Silence{Name: "migration", Start: now, End: now.Add(time.Hour)}, false},
+		{"maintenance", Silence{Name: "maintenance", Window: window}, false},
+		{"empty name", Silence{}, true},
+		{"end before start", Silence{Name: "migration", Start: now, End: now.Add(-time.Hour)}, true},
+		{"maintenance without window", Silence{Name: "maintenance", Kind: KindMaintenance}, true},
+		{"acknowledgement with window", Silence{Name: "ack", Kind: KindAcknowledgement, Owner: "network", Window: window}, true},
+		{"acknowledgement without owner", Silence{Name: "ack", Kind: KindAcknowledgement}, true},
+		{"unknown kind", Silence{Name: "mute", Kind: "mute"}, true},
+		{"long window", Silence{Name: "maintenance", Window: &Window{Duration: 8 * 24 * time.Hour}}, true},
+		{"late window", Silence{Name: "maintenance", Window: &Window{Start: 24 * time.Hour, Duration: time.Hour}}, true},
+		{"invalid alarm", Silence{Name: "migration", Alarm: "["}, true},
+		{"invalid scope", Silence{Name: "migration", Scope: selector.Rule{Name: "("}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Runner{}
+			if err := r.AddSilence(tt.silence); (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	r := Runner{}
+	r.AddSilence(Silence{Name: "maintenance", Window: window})
+	if err := r.AddSilence(Silence{Name: "maintenance"}); err == nil {
+		t.Errorf("error = nil, want error for duplicated name")
+	}
+	if s := r.GetSilences(); len(s) != 1 || s[0].Kind != KindMaintenance {
+		t.Errorf("silences = %+v, want maintenance", s)
+	}
+}
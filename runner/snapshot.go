@@ -0,0 +1,205 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/vslchnk/aws_quotas_checker/errs"
+)
+
+// Snapshot is information about quotas and their usage collected at one moment,
+// runner creates a new one on every update and never changes already created ones,
+// CollectedAt is time of the last collection of usage, it is zero if usage has not been collected yet
+type Snapshot struct {
+	Account     string          `json:"account,omitempty"`
+	Region      string          `json:"region"`
+	CollectedAt time.Time       `json:"collectedAt"`
+	Quotas      []SnapshotQuota `json:"quotas"`
+	Errors      []SnapshotError `json:"errors,omitempty"`
+}
+
+// SnapshotQuota is information about the quota and its usage in Snapshot
+type SnapshotQuota struct {
+	ServiceCode  string  `json:"serviceCode"`
+	ServiceName  string  `json:"serviceName"`
+	QuotaCode    string  `json:"quotaCode"`
+	QuotaName    string  `json:"quotaName"`
+	Adjustable   bool    `json:"adjustable"`
+	GlobalQuota  bool    `json:"globalQuota"`
+	DefaultValue float64 `json:"defaultValue"`
+	Value        float64 `json:"value"`
+	// unit of value and usage from Service Quotas
+	Unit string `json:"unit,omitempty"`
+	// period of the rate-based quota, e.g. "1s", empty for other quotas
+	Period string `json:"period,omitempty"`
+	// nil if usage is not found
+	Usage *float64 `json:"usage,omitempty"`
+	// source of usage: api or metrics
+	Source string `json:"source,omitempty"`
+	// usage from services API, nil if it is not found
+	ApiUsage *float64 `json:"apiUsage,omitempty"`
+	// usage from cloudwatch metrics, nil if it is not found
+	MetricUsage *float64 `json:"metricUsage,omitempty"`
+	// API and metric usage differ more than the tolerance
+	Discrepancy bool `json:"discrepancy,omitempty"`
+}
+
+// SnapshotError is serializable QuotaError in Snapshot,
+// it can be checked with errors.Is against typed errors from errs package
+type SnapshotError struct {
+	ServiceCode string `json:"serviceCode"`
+	QuotaCode   string `json:"quotaCode,omitempty"`
+	Source      string `json:"source"`
+	// name of the typed error from errs package, empty if error is not typed
+	Kind    string `json:"kind,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e SnapshotError) Error() string {
+	return e.Message
+}
+
+func (e SnapshotError) Is(target error) bool {
+	kind := errs.FromName(e.Kind)
+
+	return kind != nil && kind == target
+}
+
+// returns Snapshot created by the last update, it is empty if usage has not been collected yet
+func (r *Runner) Snapshot() Snapshot {
+	if r.snapshot == nil {
+		return Snapshot{Account: r.account, Region: r.region, Quotas: []SnapshotQuota{}}
+	}
+
+	return r.snapshot.copy()
+}
+
+// creates Snapshot from the current information about quotas and usage
+func (r *Runner) refreshSnapshot() {
+	s := Snapshot{}
+	s.Account = r.account
+	s.Region = r.region
+	s.CollectedAt = r.collectedAt
+	s.Quotas = make([]SnapshotQuota, 0, 0)
+	s.Errors = make([]SnapshotError, 0, 0)
+
+	for _, service := range r.quotas.ListLoadedServicesCodes() {
+		quotas, err := r.GetSupportedQuotasForService(service)
+		if err != nil {
+			continue
+		}
+
+		for _, quota := range quotas {
+			q, err := r.GetServiceQuota(service, quota)
+			if err != nil {
+				continue
+			}
+
+			sq := SnapshotQuota{}
+			sq.ServiceCode = q.ServiceCode
+			sq.ServiceName = q.ServiceName
+			sq.QuotaCode = q.QuotaCode
+			sq.QuotaName = q.QuotaName
+			sq.Adjustable = q.Adjustable
+			sq.GlobalQuota = q.GlobalQuota
+			sq.DefaultValue = q.DefaultValue
+			sq.Value = q.Value
+			sq.Unit = q.Unit
+			if q.Period > 0 {
+				sq.Period = q.Period.String()
+			}
+
+			info := r.getUsageInfo(quota)
+			sq.Usage = info.usage
+			sq.Source = info.source
+			sq.ApiUsage = info.api
+			sq.MetricUsage = info.metric
+			sq.Discrepancy = info.discrepancy
+
+			s.Quotas = append(s.Quotas, sq)
+		}
+	}
+
+	sort.Slice(s.Quotas, func(i, j int) bool {
+		if s.Quotas[i].ServiceCode != s.Quotas[j].ServiceCode {
+			return s.Quotas[i].ServiceCode < s.Quotas[j].ServiceCode
+		}
+
+		return s.Quotas[i].QuotaCode < s.Quotas[j].QuotaCode
+	})
+
+	for _, e := range r.GetErrors() {
+		s.Errors = append(s.Errors, SnapshotError{
+			ServiceCode: e.ServiceCode,
+			QuotaCode:   e.QuotaCode,
+			Source:      e.Source,
+			Kind:        errs.Name(e.Err),
+			Message:     e.Err.Error(),
+		})
+	}
+
+	r.snapshot = &s
+}
+
+// returns deep copy of Snapshot
+func (s Snapshot) copy() Snapshot {
+	c := s
+	c.Quotas = make([]SnapshotQuota, len(s.Quotas))
+	for i, q := range s.Quotas {
+		c.Quotas[i] = q
+		c.Quotas[i].Usage = copyFloat(q.Usage)
+		c.Quotas[i].ApiUsage = copyFloat(q.ApiUsage)
+		c.Quotas[i].MetricUsage = copyFloat(q.MetricUsage)
+	}
+	c.Errors = append([]SnapshotError{}, s.Errors...)
+
+	return c
+}
+
+// returns copy of the value which pointer points to, nil is returned for nil pointer
+func copyFloat(p *float64) *float64 {
+	if p == nil {
+		return nil
+	}
+	v := *p
+
+	return &v
+}
+
+// returns quota of Snapshot by service code and quota code
+func (s Snapshot) GetQuota(serviceCode string, quotaCode string) (*SnapshotQuota, error) {
+	for i := range s.Quotas {
+		if s.Quotas[i].ServiceCode == serviceCode && s.Quotas[i].QuotaCode == quotaCode {
+			q := s.Quotas[i]
+			return &q, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %v", errs.ErrUnknownQuota, quotaCode)
+}
+
+// returns JSON encoding of Snapshot
+func (s Snapshot) MarshalIndent() ([]byte, error) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("Error while encoding snapshot: %w", err)
+	}
+
+	return data, nil
+}
+
+// returns Snapshot decoded from JSON
+func ParseSnapshot(data []byte) (*Snapshot, error) {
+	s := Snapshot{}
+
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("Error while decoding snapshot: %w", err)
+	}
+	if s.Quotas == nil {
+		s.Quotas = []SnapshotQuota{}
+	}
+
+	return &s, nil
+}
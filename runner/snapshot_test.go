@@ -0,0 +1,117 @@
+package runner
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/vslchnk/aws_quotas_checker/errs"
+)
+
+func TestSnapshot(t *testing.T) {
+	r := newTestRunner(t, newTestClients())
+	s := r.Snapshot()
+
+	if s.Account != "123456789012" || s.Region != "us-east-2" || s.CollectedAt.IsZero() {
+		t.Errorf("snapshot = %v/%v/%v, want account, region and collection time", s.Account, s.Region, s.CollectedAt)
+	}
+
+	tests := []struct {
+		serviceCode string
+		quotaCode   string
+		// nil if usage is not found
+		usage  *float64
+		source string
+	}{
+		{"ec2", "L-1", floatPtr(4), "api"},
+		{"ec2", "L-2", nil, ""},
+		{"ec2", "L-3", floatPtr(7), "metrics"},
+		{"vpc", "L-4", floatPtr(1), "api"},
+	}
+
+	if len(s.Quotas) != len(tests) {
+		t.Fatalf("quotas = %+v, want %v quotas", s.Quotas, len(tests))
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.quotaCode, func(t *testing.T) {
+			q, err := s.GetQuota(tt.serviceCode, tt.quotaCode)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(q.Usage, tt.usage) || q.Source != tt.source {
+				t.Errorf("usage = %v (%v), want %v (%v)", q.Usage, q.Source, tt.usage, tt.source)
+			}
+		})
+	}
+
+	if len(s.Errors) != 1 || !errors.Is(s.Errors[0], errs.ErrThrottled) {
+		t.Errorf("errors = %+v, want throttled error of L-2", s.Errors)
+	}
+}
+
+func TestSnapshotIsImmutable(t *testing.T) {
+	r := newTestRunner(t, newTestClients())
+
+	s := r.Snapshot()
+	*s.Quotas[0].Usage = 100
+	s.Quotas[1].QuotaCode = "L-0"
+
+	if again := r.Snapshot(); *again.Quotas[0].Usage != 4 || again.Quotas[1].QuotaCode != "L-2" {
+		t.Errorf("snapshot of runner is changed by its copy: %+v", again.Quotas)
+	}
+
+	before := r.Snapshot()
+	if err := r.UpdateQuotasUsage(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	after := r.Snapshot()
+
+	if after.CollectedAt.Before(before.CollectedAt) {
+		t.Errorf("collection time = %v, want not before %v", after.CollectedAt, before.CollectedAt)
+	}
+
+	// snapshots of invalidation keep time of the last collection
+	r.Invalidate("ec2")
+	if s := r.Snapshot(); !s.CollectedAt.Equal(after.CollectedAt) {
+		t.Errorf("collection time after invalidation = %v, want %v", s.CollectedAt, after.CollectedAt)
+	}
+	r.InvalidateAll()
+	if s := r.Snapshot(); !s.CollectedAt.Equal(after.CollectedAt) {
+		t.Errorf("collection time after invalidation of all quotas = %v, want %v", s.CollectedAt, after.CollectedAt)
+	}
+}
+
+func TestSnapshotJSON(t *testing.T) {
+	r := newTestRunner(t, newTestClients())
+	s := r.Snapshot()
+
+	data, err := s.MarshalIndent()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	parsed, err := ParseSnapshot(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !parsed.CollectedAt.Equal(s.CollectedAt) {
+		t.Errorf("collection time = %v, want %v", parsed.CollectedAt, s.CollectedAt)
+	}
+	parsed.CollectedAt = s.CollectedAt
+	if !reflect.DeepEqual(*parsed, s) {
+		t.Errorf("parsed snapshot = %+v, want %+v", *parsed, s)
+	}
+	if !errors.Is(parsed.Errors[0], errs.ErrThrottled) {
+		t.Errorf("error = %v, want %v after decoding", parsed.Errors[0], errs.ErrThrottled)
+	}
+
+	if _, err := ParseSnapshot([]byte("{")); err == nil {
+		t.Errorf("error is expected for broken JSON")
+	}
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
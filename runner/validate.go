@@ -0,0 +1,171 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/vslchnk/aws_quotas_checker/errs"
+	"github.com/vslchnk/aws_quotas_checker/utils"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+)
+
+// maximum number of suggestions for the unknown code
+const maxSuggestions = 3
+
+// ValidationError describes code of the selector which doesn't match Service Quotas catalog,
+// QuotaCode is empty if the service is unknown
+type ValidationError struct {
+	ServiceCode string
+	QuotaCode   string
+	// ErrUnknownService, ErrUnknownQuota, ErrNotSupported if usage of the quota can't be found or error of listing quotas
+	Err error
+	// similar codes from the catalog
+	Suggestions []string
+}
+
+func (e ValidationError) Error() string {
+	msg := fmt.Sprintf("service %v: %v", e.ServiceCode, e.Err)
+	if e.QuotaCode != "" {
+		msg = fmt.Sprintf("service %v quota %v: %v", e.ServiceCode, e.QuotaCode, e.Err)
+	}
+
+	if len(e.Suggestions) > 0 {
+		msg += fmt.Sprintf(", did you mean %v?", strings.Join(e.Suggestions, " or "))
+	}
+
+	return msg
+}
+
+func (e ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// returns errors for codes of the selector which are not found in Service Quotas catalog
+// and for selected quotas which usage can't be found
+func (r *Runner) Validate() ([]ValidationError, error) {
+	return r.ValidateWithContext(context.Background())
+}
+
+// returns errors for codes of the selector which are not found in Service Quotas catalog
+// and for selected quotas which usage can't be found, catalog is requested with the context,
+// only codes without wildcards are checked, error is returned only if the context is done
+func (r *Runner) ValidateWithContext(ctx context.Context) ([]ValidationError, error) {
+	ctx, cancel := r.withOverallTimeout(ctx)
+	defer cancel()
+
+	validationErrors := make([]ValidationError, 0, 0)
+	if r.selector == nil {
+		return validationErrors, nil
+	}
+
+	catalog := r.quotas.ListCatalogServicesCodes()
+	serviceQuotas := make(map[string][]*servicequotas.ServiceQuota)
+	unknownServices := make(map[string]bool)
+	checked := make(map[string]bool)
+
+	for _, rule := range append(r.selectorRules(true), r.selectorRules(false)...) {
+		if rule.service == "" || isPattern(rule.service) || checked[rule.service+":"+rule.quota] {
+			continue
+		}
+		checked[rule.service+":"+rule.quota] = true
+
+		if !utils.Find(catalog, rule.service) {
+			if !unknownServices[rule.service] {
+				unknownServices[rule.service] = true
+				validationErrors = append(validationErrors, ValidationError{
+					ServiceCode: rule.service,
+					Err:         fmt.Errorf("%w: %v", errs.ErrUnknownService, rule.service),
+					Suggestions: utils.Suggest(rule.service, catalog, maxSuggestions),
+				})
+			}
+			continue
+		}
+
+		if rule.quota == "" || isPattern(rule.quota) {
+			continue
+		}
+
+		quotas, ok := serviceQuotas[rule.service]
+		if !ok {
+			var err error
+			quotas, err = r.quotas.ListCatalogQuotasWithContext(ctx, rule.service)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, fmt.Errorf("Error while validating selector: %w", ctx.Err())
+				}
+				validationErrors = append(validationErrors, ValidationError{ServiceCode: rule.service, QuotaCode: rule.quota, Err: err})
+				continue
+			}
+			serviceQuotas[rule.service] = quotas
+		}
+
+		if e := r.validateQuota(rule, quotas); e != nil {
+			validationErrors = append(validationErrors, *e)
+		}
+	}
+
+	return validationErrors, nil
+}
+
+// selectorRule is a service code and a quota code of the selector rule
+type selectorRule struct {
+	service string
+	quota   string
+	include bool
+}
+
+// returns codes of included or excluded rules of the selector
+func (r *Runner) selectorRules(include bool) []selectorRule {
+	rules := r.selector.Exclude
+	if include {
+		rules = r.selector.Include
+	}
+
+	res := make([]selectorRule, 0, len(rules))
+	for _, rule := range rules {
+		res = append(res, selectorRule{service: rule.Service, quota: rule.Quota, include: include})
+	}
+
+	return res
+}
+
+// returns error if the quota of the rule is not found in the catalog of the service,
+// or if usage of the included quota can't be found in services API or cloudwatch metrics
+func (r *Runner) validateQuota(rule selectorRule, quotas []*servicequotas.ServiceQuota) *ValidationError {
+	codes := make([]string, 0, len(quotas))
+	for _, q := range quotas {
+		code := aws.StringValue(q.QuotaCode)
+		if code != rule.quota {
+			codes = append(codes, code)
+			continue
+		}
+
+		if !rule.include || q.UsageMetric != nil {
+			return nil
+		}
+		if p, ok := r.providers[rule.service]; ok && utils.Find(p.ListQuotasCodes(), code) {
+			return nil
+		}
+
+		return &ValidationError{
+			ServiceCode: rule.service,
+			QuotaCode:   rule.quota,
+			Err:         fmt.Errorf("%w: usage of %v can't be found in services API or cloudwatch metrics", errs.ErrNotSupported, code),
+		}
+	}
+
+	return &ValidationError{
+		ServiceCode: rule.service,
+		QuotaCode:   rule.quota,
+		Err:         fmt.Errorf("%w: %v", errs.ErrUnknownQuota, rule.quota),
+		Suggestions: utils.Suggest(rule.quota, codes, maxSuggestions),
+	}
+}
+
+// checks if the code of the selector rule contains wildcards
+func isPattern(code string) bool {
+	return strings.ContainsAny(code, "*?[\\")
+}
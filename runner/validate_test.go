@@ -0,0 +1,55 @@
+package runner
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/vslchnk/aws_quotas_checker/errs"
+	"github.com/vslchnk/aws_quotas_checker/fakes"
+	"github.com/vslchnk/aws_quotas_checker/selector"
+)
+
+func TestValidate(t *testing.T) {
+	c := newTestClients()
+	c.quotas.DefaultQuotasPages["vpc"][0] = append(c.quotas.DefaultQuotasPages["vpc"][0], fakes.NewServiceQuota("vpc", "L-7", 5))
+
+	r := newTestRunner(t, c)
+
+	sel, err := selector.Parse("ec2:L-1", "ec2:L-3", "ec2:L-9", "ecc2", "ec2:L-*", "vpc:L-7", "!vpc:L-4", "!vpc:L-8", "*:L-10", "ecc2:L-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r.selector = sel
+
+	validationErrors, err := r.Validate()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		serviceCode string
+		quotaCode   string
+		err         error
+		suggestions []string
+	}{
+		{"ec2", "L-9", errs.ErrUnknownQuota, []string{"L-1", "L-2", "L-3"}},
+		{"ecc2", "", errs.ErrUnknownService, []string{"ec2"}},
+		{"vpc", "L-7", errs.ErrNotSupported, nil},
+		{"vpc", "L-8", errs.ErrUnknownQuota, []string{"L-4", "L-7"}},
+	}
+
+	if len(validationErrors) != len(tests) {
+		t.Fatalf("errors = %v, want %v errors", validationErrors, len(tests))
+	}
+	for i, tt := range tests {
+		e := validationErrors[i]
+		if e.ServiceCode != tt.serviceCode || e.QuotaCode != tt.quotaCode || !errors.Is(e, tt.err) || !reflect.DeepEqual(e.Suggestions, tt.suggestions) {
+			t.Errorf("error %v = %+v, want %v %v %v %v", i, e, tt.serviceCode, tt.quotaCode, tt.err, tt.suggestions)
+		}
+	}
+
+	if msg := validationErrors[1].Error(); msg != "service ecc2: No service with such code: ecc2, did you mean ec2?" {
+		t.Errorf("message = %q", msg)
+	}
+}
@@ -0,0 +1,315 @@
+package selector
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Selector selects services and quotas, nil Selector selects all of them
+type Selector struct {
+	// quotas matching any rule are selected, all quotas are selected if it is empty
+	Include []Rule `json:"include,omitempty" yaml:"include,omitempty"`
+	// quotas matching any rule are not selected even if they match included rules
+	Exclude []Rule `json:"exclude,omitempty" yaml:"exclude,omitempty"`
+	// selects only adjustable or only not adjustable quotas if set
+	Adjustable *bool `json:"adjustable,omitempty" yaml:"adjustable,omitempty"`
+	// selects only global or only regional quotas if set
+	Global *bool `json:"global,omitempty" yaml:"global,omitempty"`
+	// selects only quotas which usage can be found in services API or cloudwatch metrics
+	WithUsage bool `json:"withUsage,omitempty" yaml:"withUsage,omitempty"`
+}
+
+// Rule matches quotas by service code, quota code and quota name, empty fields match everything
+type Rule struct {
+	// glob of the service code, e.g. "ec2" or "elastic*"
+	Service string `json:"service,omitempty" yaml:"service,omitempty"`
+	// glob of the quota code, e.g. "L-1216C47A" or "L-12*"
+	Quota string `json:"quota,omitempty" yaml:"quota,omitempty"`
+	// regular expression of the quota name, e.g. "(?i)on-demand"
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// compiled Name, it is set by Compile, Parse and Validate of Selector, so that Match doesn't compile it
+	name *regexp.Regexp
+}
+
+// Quota is information about the quota which is matched by Selector
+type Quota struct {
+	ServiceCode string
+	QuotaCode   string
+	QuotaName   string
+	Adjustable  bool
+	Global      bool
+	// usage of the quota can be found in services API or cloudwatch metrics
+	HasUsage bool
+}
+
+// returns Selector for the map where key is the service code and value is the slice of quotas codes,
+// nil value selects all quotas of the service, nil map selects all services
+func FromMap(allowedServices *map[string]*[]string) *Selector {
+	if allowedServices == nil {
+		return nil
+	}
+
+	s := Selector{}
+	s.Include = make([]Rule, 0, len(*allowedServices))
+
+	codes := make([]string, 0, len(*allowedServices))
+	for k := range *allowedServices {
+		codes = append(codes, k)
+	}
+	sort.Strings(codes)
+
+	for _, service := range codes {
+		quotas := (*allowedServices)[service]
+		if quotas == nil {
+			s.Include = append(s.Include, Rule{Service: service})
+			continue
+		}
+
+		for _, quota := range *quotas {
+			s.Include = append(s.Include, Rule{Service: service, Quota: quota})
+		}
+	}
+
+	// empty map or services without quotas select nothing
+	if len(s.Include) == 0 {
+		s.Exclude = []Rule{{}}
+	}
+
+	return &s
+}
+
+// returns Selector parsed from terms, every term is one of:
+// "service[:quota][~name]" includes quotas, "!service[:quota][~name]" excludes them,
+// "adjustable=true|false", "global=true|false" and "usage=true" select quotas by their properties
+func Parse(terms ...string) (*Selector, error) {
+	s := Selector{}
+
+	for _, term := range terms {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		// "=" in the name of the rule, e.g. "ec2~a=b", doesn't make the term a property
+		if key, value, ok := strings.Cut(term, "="); ok && !strings.Contains(key, "~") {
+			flag, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("Error while parsing selector term %q: %w", term, err)
+			}
+
+			switch key {
+			case "adjustable":
+				s.Adjustable = &flag
+			case "global":
+				s.Global = &flag
+			case "usage":
+				s.WithUsage = flag
+			default:
+				return nil, fmt.Errorf("Error while parsing selector term %q: unknown property %v", term, key)
+			}
+			continue
+		}
+
+		exclude := strings.HasPrefix(term, "!")
+		rule := ParseRule(strings.TrimPrefix(term, "!"))
+
+		if exclude {
+			s.Exclude = append(s.Exclude, rule)
+		} else {
+			s.Include = append(s.Include, rule)
+		}
+	}
+
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// returns Rule parsed from "service[:quota][~name]"
+func ParseRule(term string) Rule {
+	rule := Rule{}
+
+	term, rule.Name, _ = strings.Cut(term, "~")
+	rule.Service, rule.Quota, _ = strings.Cut(term, ":")
+
+	return rule
+}
+
+// checks that globs and regular expressions of all rules are valid and compiles the regular expressions
+func (s *Selector) Validate() error {
+	if s == nil {
+		return nil
+	}
+
+	for _, rules := range [][]Rule{s.Include, s.Exclude} {
+		for i := range rules {
+			if err := rules[i].Compile(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checks that globs and regular expression of the rule are valid
+func (r Rule) Validate() error {
+	_, err := r.compile()
+
+	return err
+}
+
+// checks the rule and keeps its compiled regular expression for matching
+func (r *Rule) Compile() error {
+	name, err := r.compile()
+	if err != nil {
+		return err
+	}
+	r.name = name
+
+	return nil
+}
+
+// checks globs of the rule and returns its compiled regular expression, nil if the rule has no name
+func (r Rule) compile() (*regexp.Regexp, error) {
+	if _, err := path.Match(r.Service, ""); err != nil {
+		return nil, fmt.Errorf("Error while validating service pattern %q: %w", r.Service, err)
+	}
+	if _, err := path.Match(r.Quota, ""); err != nil {
+		return nil, fmt.Errorf("Error while validating quota pattern %q: %w", r.Quota, err)
+	}
+	if r.Name == "" {
+		return nil, nil
+	}
+
+	name, err := regexp.Compile(r.Name)
+	if err != nil {
+		return nil, fmt.Errorf("Error while validating quota name pattern %q: %w", r.Name, err)
+	}
+
+	return name, nil
+}
+
+// checks if any quota of the service can be selected
+func (s *Selector) MatchService(serviceCode string) bool {
+	if s == nil {
+		return true
+	}
+
+	for _, r := range s.Exclude {
+		if r.Quota == "" && r.Name == "" && matchGlob(r.Service, serviceCode) {
+			return false
+		}
+	}
+
+	if len(s.Include) == 0 {
+		return true
+	}
+
+	for _, r := range s.Include {
+		if matchGlob(r.Service, serviceCode) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checks if the quota can be selected when only its code is known, e.g. by usage providers,
+// rules and properties which need other information about the quota are treated as matching
+func (s *Selector) MatchQuotaCode(serviceCode string, quotaCode string) bool {
+	if s == nil {
+		return true
+	}
+
+	for _, r := range s.Exclude {
+		if r.Name == "" && matchGlob(r.Service, serviceCode) && matchGlob(r.Quota, quotaCode) {
+			return false
+		}
+	}
+
+	if len(s.Include) == 0 {
+		return true
+	}
+
+	for _, r := range s.Include {
+		if matchGlob(r.Service, serviceCode) && matchGlob(r.Quota, quotaCode) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checks if the quota is selected
+func (s *Selector) MatchQuota(q Quota) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.Adjustable != nil && *s.Adjustable != q.Adjustable {
+		return false
+	}
+	if s.Global != nil && *s.Global != q.Global {
+		return false
+	}
+	if s.WithUsage && !q.HasUsage {
+		return false
+	}
+
+	for _, r := range s.Exclude {
+		if r.Match(q) {
+			return false
+		}
+	}
+
+	if len(s.Include) == 0 {
+		return true
+	}
+
+	for _, r := range s.Include {
+		if r.Match(q) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checks if the quota matches all fields of the rule
+func (r Rule) Match(q Quota) bool {
+	if !matchGlob(r.Service, q.ServiceCode) || !matchGlob(r.Quota, q.QuotaCode) {
+		return false
+	}
+
+	if r.Name == "" {
+		return true
+	}
+
+	// rules which are not compiled, e.g. created as literals, are compiled on every match
+	name := r.name
+	if name == nil || name.String() != r.Name {
+		var err error
+		if name, err = regexp.Compile(r.Name); err != nil {
+			return false
+		}
+	}
+
+	return name.MatchString(q.QuotaName)
+}
+
+// checks if the value matches the glob, empty glob matches everything
+func matchGlob(pattern string, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, err := path.Match(pattern, value)
+
+	return err == nil && ok
+}
@@ -0,0 +1,133 @@
+package selector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	yes := true
+	no := false
+
+	tests := []struct {
+		name    string
+		terms   []string
+		want    *Selector
+		wantErr bool
+	}{
+		{"empty", nil, &Selector{}, false},
+		{"service", []string{"ec2"}, &Selector{Include: []Rule{{Service: "ec2"}}}, false},
+		{"quota", []string{" ec2:L-12* "}, &Selector{Include: []Rule{{Service: "ec2", Quota: "L-12*"}}}, false},
+		{"name", []string{"*:*~(?i)on-demand"}, &Selector{Include: []Rule{{Service: "*", Quota: "*", Name: "(?i)on-demand"}}}, false},
+		{"exclude", []string{"!s3", "!ec2~Spot"}, &Selector{Exclude: []Rule{{Service: "s3"}, {Service: "ec2", Name: "Spot"}}}, false},
+		{"name with =", []string{"~a=b", "!ec2~(?i)size=1"}, &Selector{Include: []Rule{{Name: "a=b"}}, Exclude: []Rule{{Service: "ec2", Name: "(?i)size=1"}}}, false},
+		{"properties", []string{"adjustable=true", "global=false", "usage=true"}, &Selector{Adjustable: &yes, Global: &no, WithUsage: true}, false},
+		{"unknown property", []string{"regional=true"}, nil, true},
+		{"invalid flag", []string{"adjustable=maybe"}, nil, true},
+		{"invalid glob", []string{"ec2:L-[1"}, nil, true},
+		{"invalid regexp", []string{"ec2~("}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.terms...)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			// regular expressions of parsed rules are compiled
+			if err := tt.want.Validate(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selector = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	yes := true
+	onDemand := Quota{ServiceCode: "ec2", QuotaCode: "L-1216C47A", QuotaName: "Running On-Demand Standard instances", Adjustable: true}
+	vpcs := Quota{ServiceCode: "vpc", QuotaCode: "L-F678F1CE", QuotaName: "VPCs per Region", HasUsage: true}
+
+	tests := []struct {
+		name     string
+		selector *Selector
+		quota    Quota
+		service  bool
+		code     bool
+		want     bool
+	}{
+		{"nil", nil, onDemand, true, true, true},
+		{"service", &Selector{Include: []Rule{{Service: "ec2"}}}, vpcs, false, false, false},
+		{"quota glob", &Selector{Include: []Rule{{Service: "ec2", Quota: "L-12*"}}}, onDemand, true, true, true},
+		{"name", &Selector{Include: []Rule{{Name: "On-Demand"}}}, vpcs, true, true, false},
+		{"excluded service", &Selector{Exclude: []Rule{{Service: "vpc"}}}, vpcs, false, false, false},
+		{"excluded name", &Selector{Exclude: []Rule{{Service: "ec2", Name: "Standard"}}}, onDemand, true, true, false},
+		{"adjustable", &Selector{Adjustable: &yes}, vpcs, true, true, false},
+		{"with usage", &Selector{WithUsage: true}, vpcs, true, true, true},
+		{"without usage", &Selector{WithUsage: true}, onDemand, true, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.selector.MatchService(tt.quota.ServiceCode); got != tt.service {
+				t.Errorf("MatchService = %v, want %v", got, tt.service)
+			}
+			if got := tt.selector.MatchQuotaCode(tt.quota.ServiceCode, tt.quota.QuotaCode); got != tt.code {
+				t.Errorf("MatchQuotaCode = %v, want %v", got, tt.code)
+			}
+			if got := tt.selector.MatchQuota(tt.quota); got != tt.want {
+				t.Errorf("MatchQuota = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	spot := Quota{ServiceCode: "ec2", QuotaCode: "L-34B43A08", QuotaName: "All Standard Spot Instance Requests"}
+
+	r := Rule{Service: "ec2", Name: "(?i)spot"}
+	if err := r.Compile(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.name == nil || !r.Match(spot) {
+		t.Errorf("rule = %+v, want compiled rule matching %v", r, spot.QuotaName)
+	}
+
+	// name which is changed after compiling is matched by itself
+	r.Name = "On-Demand"
+	if r.Match(spot) {
+		t.Errorf("rule = %+v, want no match of %v", r, spot.QuotaName)
+	}
+
+	if err := (&Rule{Name: "("}).Compile(); err == nil {
+		t.Errorf("expected error for invalid regular expression")
+	}
+}
+
+func TestFromMap(t *testing.T) {
+	if FromMap(nil) != nil {
+		t.Errorf("selector for nil map is not nil")
+	}
+
+	allowed := map[string]*[]string{
+		"ec2": {"L-1", "L-2"},
+		"vpc": nil,
+	}
+	want := &Selector{Include: []Rule{{Service: "ec2", Quota: "L-1"}, {Service: "ec2", Quota: "L-2"}, {Service: "vpc"}}}
+	if got := FromMap(&allowed); !reflect.DeepEqual(got, want) {
+		t.Errorf("selector = %+v, want %+v", got, want)
+	}
+
+	empty := FromMap(&map[string]*[]string{"ec2": {}})
+	if empty.MatchService("ec2") || empty.MatchQuota(Quota{ServiceCode: "ec2", QuotaCode: "L-1"}) {
+		t.Errorf("selector for empty map matches quotas")
+	}
+}
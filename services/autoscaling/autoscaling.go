@@ -1,67 +1,130 @@
 package autoscaling
 
 import (
+	"context"
 	"fmt"
+	"sort"
 
-	"github.com/vslchnk/aws_quotas_checker/utils"
+	"github.com/vslchnk/aws_quotas_checker/errs"
+	"github.com/vslchnk/aws_quotas_checker/ratelimit"
+	"github.com/vslchnk/aws_quotas_checker/selector"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
 )
 
-type getUsageFunc func(client *autoscaling.AutoScaling) (*int, error)
+type getUsageFunc func(ctx context.Context, client autoscalingiface.AutoScalingAPI) (*float64, error)
 
 type usageFuncMap map[string]getUsageFunc
 
 type Autoscaling struct {
-	region        string
-	client        *autoscaling.AutoScaling
-	usageFuncs    *usageFuncMap
-	allowedQuotas *[]string
+	region     string
+	client     autoscalingiface.AutoScalingAPI
+	usageFuncs *usageFuncMap
+	selector   *selector.Selector
 }
 
 // creates autoscaling agent
-func NewAutoscaling(region string, allowedQuotas *[]string) (*Autoscaling, error) {
-	a := Autoscaling{}
-	a.region = region
-	a.allowedQuotas = allowedQuotas
-
+func NewAutoscaling(region string, sel *selector.Selector) (*Autoscaling, error) {
 	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(a.region)},
+		Region: aws.String(region)},
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while creating session: %v", err)
+		return nil, fmt.Errorf("Error while creating session: %w", err)
 	}
 
-	a.client = autoscaling.New(sess)
+	return NewAutoscalingWithSession(sess, sel)
+}
+
+// creates autoscaling agent with clients from the shared session
+func NewAutoscalingWithSession(sess *session.Session, sel *selector.Selector) (*Autoscaling, error) {
+	client := autoscaling.New(sess)
+	ratelimit.Install(&client.Handlers, "autoscaling")
+
+	a := NewAutoscalingWithClient(client, sel)
+	a.region = aws.StringValue(sess.Config.Region)
+
+	return a, nil
+}
+
+// creates autoscaling agent with the client, any implementation of AWS API can be used
+func NewAutoscalingWithClient(client autoscalingiface.AutoScalingAPI, sel *selector.Selector) *Autoscaling {
+	a := Autoscaling{}
+	a.selector = sel
+	a.client = client
 	a.usageFuncs = createUsageFuncMap()
 
-	return &a, nil
+	return &a
 }
 
 // returns map of usage where key is the quotas code and value is the usage
-func (a *Autoscaling) GetUsage() (*map[string]int, error) {
-	usageMap := make(map[string]int)
+func (a *Autoscaling) GetUsage() (*map[string]float64, error) {
+	return a.GetUsageWithContext(context.Background())
+}
+
+// returns map of usage where key is the quotas code and value is the usage, requests are made with the context
+func (a *Autoscaling) GetUsageWithContext(ctx context.Context) (*map[string]float64, error) {
+	usageMap := make(map[string]float64)
 
-	for k, v := range *a.usageFuncs {
-		isQuotaAllowed := true
-		if a.allowedQuotas != nil && !utils.Find(*a.allowedQuotas, k) {
-			isQuotaAllowed = false
+	for _, k := range a.ListQuotasCodes() {
+		usage, err := a.GetQuotaUsageWithContext(ctx, k)
+		if err != nil {
+			return nil, err
 		}
-		if isQuotaAllowed {
-			usage, err := v(a.client)
 
-			if err != nil {
-				return nil, fmt.Errorf("Error while getting usage of Autoscaling quota: %v", err)
-			}
+		usageMap[k] = *usage
+	}
+
+	return &usageMap, nil
+}
+
+// returns usage of the quota by its code, requests are made with the context
+func (a *Autoscaling) GetQuotaUsageWithContext(ctx context.Context, quotaCode string) (*float64, error) {
+	f, ok := (*a.usageFuncs)[quotaCode]
+	if !ok || !a.selector.MatchQuotaCode(GetCode(), quotaCode) {
+		return nil, fmt.Errorf("%w: %v", errs.ErrNotSupported, quotaCode)
+	}
+
+	usage, err := f(ctx, a.client)
+	if err != nil {
+		return nil, fmt.Errorf("Error while getting usage of Autoscaling quota: %w", err)
+	}
+
+	return usage, nil
+}
 
-			usageMap[k] = *usage
+// returns sorted slice of allowed quotas codes which usage can be found
+func (a *Autoscaling) ListQuotasCodes() []string {
+	codes := make([]string, 0, len(*a.usageFuncs))
+
+	for k := range *a.usageFuncs {
+		if a.selector.MatchQuotaCode(GetCode(), k) {
+			codes = append(codes, k)
 		}
 	}
+	sort.Strings(codes)
 
-	return &usageMap, nil
+	return codes
+}
+
+// returns code for service in quotas
+func (a *Autoscaling) GetCode() string {
+	return GetCode()
+}
+
+// returns actions for IAM policy for allowed quotas, every action is associated with the quota code
+func (a *Autoscaling) GetIam() map[string]string {
+	actions := make(map[string]string)
+	all := GetIam()
+
+	for _, k := range a.ListQuotasCodes() {
+		actions[k] = all[k]
+	}
+
+	return actions
 }
 
 // creates map to associate quota code with func to calculate its usage
@@ -74,38 +137,38 @@ func createUsageFuncMap() *usageFuncMap {
 	return &usageFuncs
 }
 
-func getUsageAutoscalingGroups(client *autoscaling.AutoScaling) (*int, error) {
+func getUsageAutoscalingGroups(ctx context.Context, client autoscalingiface.AutoScalingAPI) (*float64, error) {
 	groups := make([]*autoscaling.Group, 0, 0)
 
-	err := client.DescribeAutoScalingGroupsPages(nil,
+	err := client.DescribeAutoScalingGroupsPagesWithContext(ctx, nil,
 		func(page *autoscaling.DescribeAutoScalingGroupsOutput, lastPage bool) bool {
 			groups = append(groups, page.AutoScalingGroups...)
 			return true
 		})
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing autoscaling groups: %v", err)
+		return nil, fmt.Errorf("Error while describing autoscaling groups: %w", err)
 	}
 
-	l := len(groups)
+	l := float64(len(groups))
 
 	return &l, nil
 }
 
-func getUsageLaunchConfigurations(client *autoscaling.AutoScaling) (*int, error) {
+func getUsageLaunchConfigurations(ctx context.Context, client autoscalingiface.AutoScalingAPI) (*float64, error) {
 	configurations := make([]*autoscaling.LaunchConfiguration, 0, 0)
 
-	err := client.DescribeLaunchConfigurationsPages(nil,
+	err := client.DescribeLaunchConfigurationsPagesWithContext(ctx, nil,
 		func(page *autoscaling.DescribeLaunchConfigurationsOutput, lastPage bool) bool {
 			configurations = append(configurations, page.LaunchConfigurations...)
 			return true
 		})
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing launch configurations: %v", err)
+		return nil, fmt.Errorf("Error while describing launch configurations: %w", err)
 	}
 
-	l := len(configurations)
+	l := float64(len(configurations))
 
 	return &l, nil
 }
@@ -1,67 +1,130 @@
 package cloudformation
 
 import (
+	"context"
 	"fmt"
+	"sort"
 
-	"github.com/vslchnk/aws_quotas_checker/utils"
+	"github.com/vslchnk/aws_quotas_checker/errs"
+	"github.com/vslchnk/aws_quotas_checker/ratelimit"
+	"github.com/vslchnk/aws_quotas_checker/selector"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
 )
 
-type getUsageFunc func(client *cloudformation.CloudFormation) (*int, error)
+type getUsageFunc func(ctx context.Context, client cloudformationiface.CloudFormationAPI) (*float64, error)
 
 type usageFuncMap map[string]getUsageFunc
 
 type Cloudformation struct {
-	region        string
-	client        *cloudformation.CloudFormation
-	usageFuncs    *usageFuncMap
-	allowedQuotas *[]string
+	region     string
+	client     cloudformationiface.CloudFormationAPI
+	usageFuncs *usageFuncMap
+	selector   *selector.Selector
 }
 
 // creates CloudFormation agent
-func NewCloudformation(region string, allowedQuotas *[]string) (*Cloudformation, error) {
-	c := Cloudformation{}
-	c.region = region
-	c.allowedQuotas = allowedQuotas
-
+func NewCloudformation(region string, sel *selector.Selector) (*Cloudformation, error) {
 	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(c.region)},
+		Region: aws.String(region)},
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while creating session: %v", err)
+		return nil, fmt.Errorf("Error while creating session: %w", err)
 	}
 
-	c.client = cloudformation.New(sess)
+	return NewCloudformationWithSession(sess, sel)
+}
+
+// creates CloudFormation agent with clients from the shared session
+func NewCloudformationWithSession(sess *session.Session, sel *selector.Selector) (*Cloudformation, error) {
+	client := cloudformation.New(sess)
+	ratelimit.Install(&client.Handlers, "cloudformation")
+
+	c := NewCloudformationWithClient(client, sel)
+	c.region = aws.StringValue(sess.Config.Region)
+
+	return c, nil
+}
+
+// creates CloudFormation agent with the client, any implementation of AWS API can be used
+func NewCloudformationWithClient(client cloudformationiface.CloudFormationAPI, sel *selector.Selector) *Cloudformation {
+	c := Cloudformation{}
+	c.selector = sel
+	c.client = client
 	c.usageFuncs = createUsageFuncMap()
 
-	return &c, nil
+	return &c
 }
 
 // returns map of usage where key is the quotas code and value is the usage
-func (c *Cloudformation) GetUsage() (*map[string]int, error) {
-	usageMap := make(map[string]int)
+func (c *Cloudformation) GetUsage() (*map[string]float64, error) {
+	return c.GetUsageWithContext(context.Background())
+}
+
+// returns map of usage where key is the quotas code and value is the usage, requests are made with the context
+func (c *Cloudformation) GetUsageWithContext(ctx context.Context) (*map[string]float64, error) {
+	usageMap := make(map[string]float64)
 
-	for k, v := range *c.usageFuncs {
-		isQuotaAllowed := true
-		if c.allowedQuotas != nil && !utils.Find(*c.allowedQuotas, k) {
-			isQuotaAllowed = false
+	for _, k := range c.ListQuotasCodes() {
+		usage, err := c.GetQuotaUsageWithContext(ctx, k)
+		if err != nil {
+			return nil, err
 		}
-		if isQuotaAllowed {
-			usage, err := v(c.client)
 
-			if err != nil {
-				return nil, fmt.Errorf("Error while getting usage of Cloudformation quota: %v", err)
-			}
+		usageMap[k] = *usage
+	}
+
+	return &usageMap, nil
+}
+
+// returns usage of the quota by its code, requests are made with the context
+func (c *Cloudformation) GetQuotaUsageWithContext(ctx context.Context, quotaCode string) (*float64, error) {
+	f, ok := (*c.usageFuncs)[quotaCode]
+	if !ok || !c.selector.MatchQuotaCode(GetCode(), quotaCode) {
+		return nil, fmt.Errorf("%w: %v", errs.ErrNotSupported, quotaCode)
+	}
+
+	usage, err := f(ctx, c.client)
+	if err != nil {
+		return nil, fmt.Errorf("Error while getting usage of Cloudformation quota: %w", err)
+	}
+
+	return usage, nil
+}
 
-			usageMap[k] = *usage
+// returns sorted slice of allowed quotas codes which usage can be found
+func (c *Cloudformation) ListQuotasCodes() []string {
+	codes := make([]string, 0, len(*c.usageFuncs))
+
+	for k := range *c.usageFuncs {
+		if c.selector.MatchQuotaCode(GetCode(), k) {
+			codes = append(codes, k)
 		}
 	}
+	sort.Strings(codes)
 
-	return &usageMap, nil
+	return codes
+}
+
+// returns code for service in quotas
+func (c *Cloudformation) GetCode() string {
+	return GetCode()
+}
+
+// returns actions for IAM policy for allowed quotas, every action is associated with the quota code
+func (c *Cloudformation) GetIam() map[string]string {
+	actions := make(map[string]string)
+	all := GetIam()
+
+	for _, k := range c.ListQuotasCodes() {
+		actions[k] = all[k]
+	}
+
+	return actions
 }
 
 // creates map to associate quota code with func to calculate its usage
@@ -74,42 +137,42 @@ func createUsageFuncMap() *usageFuncMap {
 	return &usageFuncs
 }
 
-func getUsageStacks(client *cloudformation.CloudFormation) (*int, error) {
+func getUsageStacks(ctx context.Context, client cloudformationiface.CloudFormationAPI) (*float64, error) {
 	stacks := make([]*cloudformation.Stack, 0, 0)
 
-	err := client.DescribeStacksPages(nil,
+	err := client.DescribeStacksPagesWithContext(ctx, nil,
 		func(page *cloudformation.DescribeStacksOutput, lastPage bool) bool {
 			stacks = append(stacks, page.Stacks...)
 			return true
 		})
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing cloudformation stacks: %v", err)
+		return nil, fmt.Errorf("Error while describing cloudformation stacks: %w", err)
 	}
 
-	l := len(stacks)
+	l := float64(len(stacks))
 
 	return &l, nil
 }
 
-func getUsageStackSets(client *cloudformation.CloudFormation) (*int, error) {
+func getUsageStackSets(ctx context.Context, client cloudformationiface.CloudFormationAPI) (*float64, error) {
 	sets := make([]*cloudformation.StackSetSummary, 0, 0)
 
 	params := &cloudformation.ListStackSetsInput{
 		Status: aws.String("ACTIVE"),
 	}
 
-	err := client.ListStackSetsPages(params,
+	err := client.ListStackSetsPagesWithContext(ctx, params,
 		func(page *cloudformation.ListStackSetsOutput, lastPage bool) bool {
 			sets = append(sets, page.Summaries...)
 			return true
 		})
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing cloudformation stack sets: %v", err)
+		return nil, fmt.Errorf("Error while describing cloudformation stack sets: %w", err)
 	}
 
-	l := len(sets)
+	l := float64(len(sets))
 
 	return &l, nil
 }
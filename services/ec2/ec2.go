@@ -1,68 +1,131 @@
 package ec2
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"strings"
 
-	"github.com/vslchnk/aws_quotas_checker/utils"
+	"github.com/vslchnk/aws_quotas_checker/errs"
+	"github.com/vslchnk/aws_quotas_checker/ratelimit"
+	"github.com/vslchnk/aws_quotas_checker/selector"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 )
 
-type getUsageFunc func(client *ec2.EC2) (*int, error)
+type getUsageFunc func(ctx context.Context, client ec2iface.EC2API) (*float64, error)
 
 type usageFuncMap map[string]getUsageFunc
 
 type EC2 struct {
-	region        string
-	client        *ec2.EC2
-	usageFuncs    *usageFuncMap
-	allowedQuotas *[]string
+	region     string
+	client     ec2iface.EC2API
+	usageFuncs *usageFuncMap
+	selector   *selector.Selector
 }
 
 // creates EC2 agent
-func NewEC2(region string, allowedQuotas *[]string) (*EC2, error) {
-	e := EC2{}
-	e.region = region
-	e.allowedQuotas = allowedQuotas
-
+func NewEC2(region string, sel *selector.Selector) (*EC2, error) {
 	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(e.region)},
+		Region: aws.String(region)},
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while creating session: %v", err)
+		return nil, fmt.Errorf("Error while creating session: %w", err)
 	}
 
-	e.client = ec2.New(sess)
+	return NewEC2WithSession(sess, sel)
+}
+
+// creates EC2 agent with clients from the shared session
+func NewEC2WithSession(sess *session.Session, sel *selector.Selector) (*EC2, error) {
+	client := ec2.New(sess)
+	ratelimit.Install(&client.Handlers, "ec2")
+
+	e := NewEC2WithClient(client, sel)
+	e.region = aws.StringValue(sess.Config.Region)
+
+	return e, nil
+}
+
+// creates EC2 agent with the client, any implementation of AWS API can be used
+func NewEC2WithClient(client ec2iface.EC2API, sel *selector.Selector) *EC2 {
+	e := EC2{}
+	e.selector = sel
+	e.client = client
 	e.usageFuncs = createUsageFuncMap()
 
-	return &e, nil
+	return &e
 }
 
 // returns map of usage where key is the quotas code and value is the usage
-func (e *EC2) GetUsage() (*map[string]int, error) {
-	usageMap := make(map[string]int)
+func (e *EC2) GetUsage() (*map[string]float64, error) {
+	return e.GetUsageWithContext(context.Background())
+}
+
+// returns map of usage where key is the quotas code and value is the usage, requests are made with the context
+func (e *EC2) GetUsageWithContext(ctx context.Context) (*map[string]float64, error) {
+	usageMap := make(map[string]float64)
 
-	for k, v := range *e.usageFuncs {
-		isQuotaAllowed := true
-		if e.allowedQuotas != nil && !utils.Find(*e.allowedQuotas, k) {
-			isQuotaAllowed = false
+	for _, k := range e.ListQuotasCodes() {
+		usage, err := e.GetQuotaUsageWithContext(ctx, k)
+		if err != nil {
+			return nil, err
 		}
-		if isQuotaAllowed {
-			usage, err := v(e.client)
 
-			if err != nil {
-				return nil, fmt.Errorf("Error while getting usage of EC2 quota: %v", err)
-			}
+		usageMap[k] = *usage
+	}
+
+	return &usageMap, nil
+}
+
+// returns usage of the quota by its code, requests are made with the context
+func (e *EC2) GetQuotaUsageWithContext(ctx context.Context, quotaCode string) (*float64, error) {
+	f, ok := (*e.usageFuncs)[quotaCode]
+	if !ok || !e.selector.MatchQuotaCode(GetCode(), quotaCode) {
+		return nil, fmt.Errorf("%w: %v", errs.ErrNotSupported, quotaCode)
+	}
+
+	usage, err := f(ctx, e.client)
+	if err != nil {
+		return nil, fmt.Errorf("Error while getting usage of EC2 quota: %w", err)
+	}
+
+	return usage, nil
+}
+
+// returns sorted slice of allowed quotas codes which usage can be found
+func (e *EC2) ListQuotasCodes() []string {
+	codes := make([]string, 0, len(*e.usageFuncs))
 
-			usageMap[k] = *usage
+	for k := range *e.usageFuncs {
+		if e.selector.MatchQuotaCode(GetCode(), k) {
+			codes = append(codes, k)
 		}
 	}
+	sort.Strings(codes)
 
-	return &usageMap, nil
+	return codes
+}
+
+// returns code for service in quotas
+func (e *EC2) GetCode() string {
+	return GetCode()
+}
+
+// returns actions for IAM policy for allowed quotas, every action is associated with the quota code
+func (e *EC2) GetIam() map[string]string {
+	actions := make(map[string]string)
+	all := GetIam()
+
+	for _, k := range e.ListQuotasCodes() {
+		actions[k] = all[k]
+	}
+
+	return actions
 }
 
 // creates map to associate quota code with func to calculate its usage
@@ -109,197 +172,197 @@ func createUsageFuncMap() *usageFuncMap {
 	return &usageFuncs
 }
 
-func getUsageDedicatedA1Hosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "a1")
+func getUsageDedicatedA1Hosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "a1")
 }
 
-func getUsageDedicatedC4Hosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "c4")
+func getUsageDedicatedC4Hosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "c4")
 }
 
-func getUsageDedicatedC5Hosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "c5")
+func getUsageDedicatedC5Hosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "c5")
 }
 
-func getUsageDedicatedC5DHosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "c5d")
+func getUsageDedicatedC5DHosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "c5d")
 }
 
-func getUsageDedicatedC5NHosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "c5n")
+func getUsageDedicatedC5NHosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "c5n")
 }
 
-func getUsageDedicatedD2Hosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "d2")
+func getUsageDedicatedD2Hosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "d2")
 }
 
-func getUsageDedicatedG3Hosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "g3")
+func getUsageDedicatedG3Hosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "g3")
 }
 
-func getUsageDedicatedG3SHosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "g3s")
+func getUsageDedicatedG3SHosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "g3s")
 }
 
-func getUsageDedicatedG4DNHosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "g4dn")
+func getUsageDedicatedG4DNHosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "g4dn")
 }
 
-func getUsageDedicatedH1Hosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "h1")
+func getUsageDedicatedH1Hosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "h1")
 }
 
-func getUsageDedicatedI2Hosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "i2")
+func getUsageDedicatedI2Hosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "i2")
 }
 
-func getUsageDedicatedI3Hosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "i3")
+func getUsageDedicatedI3Hosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "i3")
 }
 
-func getUsageDedicatedI3ENHosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "i3en")
+func getUsageDedicatedI3ENHosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "i3en")
 }
 
-func getUsageDedicatedM4Hosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "m4")
+func getUsageDedicatedM4Hosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "m4")
 }
 
-func getUsageDedicatedM5Hosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "m5")
+func getUsageDedicatedM5Hosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "m5")
 }
 
-func getUsageDedicatedM5AHosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "m5a")
+func getUsageDedicatedM5AHosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "m5a")
 }
 
-func getUsageDedicatedM5ADHosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "m5ad")
+func getUsageDedicatedM5ADHosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "m5ad")
 }
 
-func getUsageDedicatedM5DHosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "m5d")
+func getUsageDedicatedM5DHosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "m5d")
 }
 
-func getUsageDedicatedM5DNHosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "m5dn")
+func getUsageDedicatedM5DNHosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "m5dn")
 }
 
-func getUsageDedicatedM5NHosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "m5n")
+func getUsageDedicatedM5NHosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "m5n")
 }
 
-func getUsageDedicatedM6GHosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "m6g")
+func getUsageDedicatedM6GHosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "m6g")
 }
 
-func getUsageDedicatedP2Hosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "p2")
+func getUsageDedicatedP2Hosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "p2")
 }
 
-func getUsageDedicatedP3Hosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "p3")
+func getUsageDedicatedP3Hosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "p3")
 }
 
-func getUsageDedicatedR3Hosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "r3")
+func getUsageDedicatedR3Hosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "r3")
 }
 
-func getUsageDedicatedR4Hosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "r4")
+func getUsageDedicatedR4Hosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "r4")
 }
 
-func getUsageDedicatedR5Hosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "r5")
+func getUsageDedicatedR5Hosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "r5")
 }
 
-func getUsageDedicatedR5AHosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "r5a")
+func getUsageDedicatedR5AHosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "r5a")
 }
 
-func getUsageDedicatedR5ADHosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "r5ad")
+func getUsageDedicatedR5ADHosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "r5ad")
 }
 
-func getUsageDedicatedR5DHosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "r5d")
+func getUsageDedicatedR5DHosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "r5d")
 }
 
-func getUsageDedicatedR5DNHosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "r5dn")
+func getUsageDedicatedR5DNHosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "r5dn")
 }
 
-func getUsageDedicatedR5NHosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "r5n")
+func getUsageDedicatedR5NHosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "r5n")
 }
 
-func getUsageDedicatedX1Hosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "x1")
+func getUsageDedicatedX1Hosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "x1")
 }
 
-func getUsageDedicatedX1EHosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "x1e")
+func getUsageDedicatedX1EHosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "x1e")
 }
 
-func getUsageDedicatedZ1DHosts(client *ec2.EC2) (*int, error) {
-	return getDedicatedHostsUsage(client, "z1d")
+func getUsageDedicatedZ1DHosts(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getDedicatedHostsUsage(ctx, client, "z1d")
 }
 
-func getUsageVpnGateways(client *ec2.EC2) (*int, error) {
-	res, err := client.DescribeVpnGateways(nil)
+func getUsageVpnGateways(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	res, err := client.DescribeVpnGatewaysWithContext(ctx, nil)
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing VPN gateways: %v", err)
+		return nil, fmt.Errorf("Error while describing VPN gateways: %w", err)
 	}
 
-	l := len(res.VpnGateways)
+	l := float64(len(res.VpnGateways))
 
 	return &l, nil
 }
 
-func getUsageVpnConnections(client *ec2.EC2) (*int, error) {
-	res, err := client.DescribeVpnConnections(nil)
+func getUsageVpnConnections(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	res, err := client.DescribeVpnConnectionsWithContext(ctx, nil)
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing VPN connections: %v", err)
+		return nil, fmt.Errorf("Error while describing VPN connections: %w", err)
 	}
 
-	l := len(res.VpnConnections)
+	l := float64(len(res.VpnConnections))
 
 	return &l, nil
 }
 
-func getUsageTransitGateways(client *ec2.EC2) (*int, error) {
+func getUsageTransitGateways(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
 	gateways := make([]*ec2.TransitGateway, 0, 0)
 
-	err := client.DescribeTransitGatewaysPages(nil,
+	err := client.DescribeTransitGatewaysPagesWithContext(ctx, nil,
 		func(page *ec2.DescribeTransitGatewaysOutput, lastPage bool) bool {
 			gateways = append(gateways, page.TransitGateways...)
 			return true
 		})
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing transit gateways: %v", err)
+		return nil, fmt.Errorf("Error while describing transit gateways: %w", err)
 	}
 
-	l := len(gateways)
+	l := float64(len(gateways))
 
 	return &l, nil
 }
 
-func getUsageCustomerGateways(client *ec2.EC2) (*int, error) {
-	res, err := client.DescribeCustomerGateways(nil)
+func getUsageCustomerGateways(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	res, err := client.DescribeCustomerGatewaysWithContext(ctx, nil)
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing customer gateways: %v", err)
+		return nil, fmt.Errorf("Error while describing customer gateways: %w", err)
 	}
 
-	l := len(res.CustomerGateways)
+	l := float64(len(res.CustomerGateways))
 
 	return &l, nil
 }
 
-func getUsageEIPVPC(client *ec2.EC2) (*int, error) {
+func getUsageEIPVPC(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
 	params := &ec2.DescribeAddressesInput{
 		Filters: []*ec2.Filter{
 			{
@@ -311,33 +374,37 @@ func getUsageEIPVPC(client *ec2.EC2) (*int, error) {
 		},
 	}
 
-	res, err := client.DescribeAddresses(params)
+	res, err := client.DescribeAddressesWithContext(ctx, params)
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing EC2-VPC EIPs: %v", err)
+		return nil, fmt.Errorf("Error while describing EC2-VPC EIPs: %w", err)
 	}
 
-	l := len(res.Addresses)
+	l := float64(len(res.Addresses))
 
 	return &l, nil
 }
 
-func getDedicatedHostsUsage(client *ec2.EC2, family string) (*int, error) {
-	count := 0
+func getDedicatedHostsUsage(ctx context.Context, client ec2iface.EC2API, family string) (*float64, error) {
+	count := 0.0
 	hosts := make([]*ec2.Host, 0, 0)
 
-	err := client.DescribeHostsPages(nil,
+	err := client.DescribeHostsPagesWithContext(ctx, nil,
 		func(page *ec2.DescribeHostsOutput, lastPage bool) bool {
 			hosts = append(hosts, page.Hosts...)
 			return true
 		})
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing dedicated hosts: %v", err)
+		return nil, fmt.Errorf("Error while describing dedicated hosts: %w", err)
 	}
 
 	for _, host := range hosts {
-		if (host.HostProperties.InstanceType != nil && strings.Contains(*host.HostProperties.InstanceType, family+".")) || (host.HostProperties.InstanceFamily != nil && *host.HostProperties.InstanceFamily == family) {
+		if host.HostProperties == nil {
+			continue
+		}
+
+		if strings.HasPrefix(aws.StringValue(host.HostProperties.InstanceType), family+".") || aws.StringValue(host.HostProperties.InstanceFamily) == family {
 			count++
 		}
 	}
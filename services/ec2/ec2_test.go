@@ -0,0 +1,147 @@
+package ec2
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/vslchnk/aws_quotas_checker/errs"
+	"github.com/vslchnk/aws_quotas_checker/fakes"
+	"github.com/vslchnk/aws_quotas_checker/selector"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func host(instanceType string, instanceFamily string) *ec2.Host {
+	p := &ec2.HostProperties{}
+	if instanceType != "" {
+		p.InstanceType = aws.String(instanceType)
+	}
+	if instanceFamily != "" {
+		p.InstanceFamily = aws.String(instanceFamily)
+	}
+
+	return &ec2.Host{HostProperties: p}
+}
+
+func TestGetDedicatedHostsUsage(t *testing.T) {
+	pages := [][]*ec2.Host{
+		{host("m5.large", ""), host("m5d.large", ""), host("", "m5")},
+		{host("", "m5d"), host("am5.large", ""), {}},
+		{host("c5.xlarge", "c5")},
+	}
+
+	tests := []struct {
+		family string
+		want   float64
+	}{
+		{"m5", 2},
+		{"m5d", 2},
+		{"c5", 1},
+		{"r5", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.family, func(t *testing.T) {
+			client := &fakes.EC2{HostsPages: pages}
+
+			usage, err := getDedicatedHostsUsage(context.Background(), client, tt.family)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *usage != tt.want {
+				t.Errorf("usage = %v, want %v", *usage, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetUsageEIPVPC(t *testing.T) {
+	client := &fakes.EC2{Addresses: []*ec2.Address{
+		{Domain: aws.String("vpc")},
+		{Domain: aws.String("standard")},
+		{Domain: aws.String("vpc")},
+	}}
+
+	usage, err := getUsageEIPVPC(context.Background(), client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *usage != 2 {
+		t.Errorf("usage = %v, want 2", *usage)
+	}
+}
+
+func TestGetQuotaUsageWithContext(t *testing.T) {
+	apiErr := errors.New("api error")
+
+	tests := []struct {
+		name      string
+		client    *fakes.EC2
+		selector  *selector.Selector
+		quotaCode string
+		want      float64
+		wantErr   error
+	}{
+		{
+			name:      "supported quota",
+			client:    &fakes.EC2{VpnGateways: []*ec2.VpnGateway{{}, {}}},
+			quotaCode: "L-7029FAB6",
+			want:      2,
+		},
+		{
+			name:      "unknown quota",
+			client:    &fakes.EC2{},
+			quotaCode: "L-00000000",
+			wantErr:   errs.ErrNotSupported,
+		},
+		{
+			name:      "not allowed quota",
+			client:    &fakes.EC2{},
+			selector:  &selector.Selector{Include: []selector.Rule{{Service: "ec2", Quota: "L-949445B0"}}},
+			quotaCode: "L-7029FAB6",
+			wantErr:   errs.ErrNotSupported,
+		},
+		{
+			name:      "API error",
+			client:    &fakes.EC2{Err: apiErr},
+			quotaCode: "L-7029FAB6",
+			wantErr:   apiErr,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEC2WithClient(tt.client, tt.selector)
+
+			usage, err := e.GetQuotaUsageWithContext(context.Background(), tt.quotaCode)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *usage != tt.want {
+				t.Errorf("usage = %v, want %v", *usage, tt.want)
+			}
+		})
+	}
+}
+
+func TestListQuotasCodes(t *testing.T) {
+	sel, err := selector.Parse("ec2:L-7029FAB6", "ec2:L-00000000", "ec2:L-0263*", "!ec2:L-0263D0A4", "vpc:L-949445B0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e := NewEC2WithClient(&fakes.EC2{}, sel)
+
+	got := e.ListQuotasCodes()
+	want := []string{"L-0263D0A3", "L-7029FAB6"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("codes = %v, want %v", got, want)
+	}
+}
@@ -1,67 +1,130 @@
 package efs
 
 import (
+	"context"
 	"fmt"
+	"sort"
 
-	"github.com/vslchnk/aws_quotas_checker/utils"
+	"github.com/vslchnk/aws_quotas_checker/errs"
+	"github.com/vslchnk/aws_quotas_checker/ratelimit"
+	"github.com/vslchnk/aws_quotas_checker/selector"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/efs"
+	"github.com/aws/aws-sdk-go/service/efs/efsiface"
 )
 
-type getUsageFunc func(client *efs.EFS) (*int, error)
+type getUsageFunc func(ctx context.Context, client efsiface.EFSAPI) (*float64, error)
 
 type usageFuncMap map[string]getUsageFunc
 
 type EFS struct {
-	region        string
-	client        *efs.EFS
-	usageFuncs    *usageFuncMap
-	allowedQuotas *[]string
+	region     string
+	client     efsiface.EFSAPI
+	usageFuncs *usageFuncMap
+	selector   *selector.Selector
 }
 
 // creates EFS agent
-func NewEFS(region string, allowedQuotas *[]string) (*EFS, error) {
-	e := EFS{}
-	e.region = region
-	e.allowedQuotas = allowedQuotas
-
+func NewEFS(region string, sel *selector.Selector) (*EFS, error) {
 	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(e.region)},
+		Region: aws.String(region)},
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while creating session: %v", err)
+		return nil, fmt.Errorf("Error while creating session: %w", err)
 	}
 
-	e.client = efs.New(sess)
+	return NewEFSWithSession(sess, sel)
+}
+
+// creates EFS agent with clients from the shared session
+func NewEFSWithSession(sess *session.Session, sel *selector.Selector) (*EFS, error) {
+	client := efs.New(sess)
+	ratelimit.Install(&client.Handlers, "elasticfilesystem")
+
+	e := NewEFSWithClient(client, sel)
+	e.region = aws.StringValue(sess.Config.Region)
+
+	return e, nil
+}
+
+// creates EFS agent with the client, any implementation of AWS API can be used
+func NewEFSWithClient(client efsiface.EFSAPI, sel *selector.Selector) *EFS {
+	e := EFS{}
+	e.selector = sel
+	e.client = client
 	e.usageFuncs = createUsageFuncMap()
 
-	return &e, nil
+	return &e
 }
 
 // returns map of usage where key is the quotas code and value is the usage
-func (e *EFS) GetUsage() (*map[string]int, error) {
-	usageMap := make(map[string]int)
+func (e *EFS) GetUsage() (*map[string]float64, error) {
+	return e.GetUsageWithContext(context.Background())
+}
+
+// returns map of usage where key is the quotas code and value is the usage, requests are made with the context
+func (e *EFS) GetUsageWithContext(ctx context.Context) (*map[string]float64, error) {
+	usageMap := make(map[string]float64)
 
-	for k, v := range *e.usageFuncs {
-		isQuotaAllowed := true
-		if e.allowedQuotas != nil && !utils.Find(*e.allowedQuotas, k) {
-			isQuotaAllowed = false
+	for _, k := range e.ListQuotasCodes() {
+		usage, err := e.GetQuotaUsageWithContext(ctx, k)
+		if err != nil {
+			return nil, err
 		}
-		if isQuotaAllowed {
-			usage, err := v(e.client)
 
-			if err != nil {
-				return nil, fmt.Errorf("Error while getting usage of EFS quota: %v", err)
-			}
+		usageMap[k] = *usage
+	}
+
+	return &usageMap, nil
+}
+
+// returns usage of the quota by its code, requests are made with the context
+func (e *EFS) GetQuotaUsageWithContext(ctx context.Context, quotaCode string) (*float64, error) {
+	f, ok := (*e.usageFuncs)[quotaCode]
+	if !ok || !e.selector.MatchQuotaCode(GetCode(), quotaCode) {
+		return nil, fmt.Errorf("%w: %v", errs.ErrNotSupported, quotaCode)
+	}
+
+	usage, err := f(ctx, e.client)
+	if err != nil {
+		return nil, fmt.Errorf("Error while getting usage of EFS quota: %w", err)
+	}
+
+	return usage, nil
+}
 
-			usageMap[k] = *usage
+// returns sorted slice of allowed quotas codes which usage can be found
+func (e *EFS) ListQuotasCodes() []string {
+	codes := make([]string, 0, len(*e.usageFuncs))
+
+	for k := range *e.usageFuncs {
+		if e.selector.MatchQuotaCode(GetCode(), k) {
+			codes = append(codes, k)
 		}
 	}
+	sort.Strings(codes)
 
-	return &usageMap, nil
+	return codes
+}
+
+// returns code for service in quotas
+func (e *EFS) GetCode() string {
+	return GetCode()
+}
+
+// returns actions for IAM policy for allowed quotas, every action is associated with the quota code
+func (e *EFS) GetIam() map[string]string {
+	actions := make(map[string]string)
+	all := GetIam()
+
+	for _, k := range e.ListQuotasCodes() {
+		actions[k] = all[k]
+	}
+
+	return actions
 }
 
 // creates map to associate quota code with func to calculate its usage
@@ -73,20 +136,20 @@ func createUsageFuncMap() *usageFuncMap {
 	return &usageFuncs
 }
 
-func getUsageFileSystems(client *efs.EFS) (*int, error) {
+func getUsageFileSystems(ctx context.Context, client efsiface.EFSAPI) (*float64, error) {
 	fs := make([]*efs.FileSystemDescription, 0, 0)
 
-	err := client.DescribeFileSystemsPages(nil,
+	err := client.DescribeFileSystemsPagesWithContext(ctx, nil,
 		func(page *efs.DescribeFileSystemsOutput, lastPage bool) bool {
 			fs = append(fs, page.FileSystems...)
 			return true
 		})
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing elastic file systems: %v", err)
+		return nil, fmt.Errorf("Error while describing elastic file systems: %w", err)
 	}
 
-	l := len(fs)
+	l := float64(len(fs))
 
 	return &l, nil
 }
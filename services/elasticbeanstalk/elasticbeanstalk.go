@@ -1,67 +1,130 @@
 package elasticbeanstalk
 
 import (
+	"context"
 	"fmt"
+	"sort"
 
-	"github.com/vslchnk/aws_quotas_checker/utils"
+	"github.com/vslchnk/aws_quotas_checker/errs"
+	"github.com/vslchnk/aws_quotas_checker/ratelimit"
+	"github.com/vslchnk/aws_quotas_checker/selector"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/elasticbeanstalk"
+	"github.com/aws/aws-sdk-go/service/elasticbeanstalk/elasticbeanstalkiface"
 )
 
-type getUsageFunc func(client *elasticbeanstalk.ElasticBeanstalk) (*int, error)
+type getUsageFunc func(ctx context.Context, client elasticbeanstalkiface.ElasticBeanstalkAPI) (*float64, error)
 
 type usageFuncMap map[string]getUsageFunc
 
 type Elasticbeanstalk struct {
-	region        string
-	client        *elasticbeanstalk.ElasticBeanstalk
-	usageFuncs    *usageFuncMap
-	allowedQuotas *[]string
+	region     string
+	client     elasticbeanstalkiface.ElasticBeanstalkAPI
+	usageFuncs *usageFuncMap
+	selector   *selector.Selector
 }
 
 // creates Elasticbeanstalk agent
-func NewElasticbeanstalk(region string, allowedQuotas *[]string) (*Elasticbeanstalk, error) {
-	e := Elasticbeanstalk{}
-	e.region = region
-	e.allowedQuotas = allowedQuotas
-
+func NewElasticbeanstalk(region string, sel *selector.Selector) (*Elasticbeanstalk, error) {
 	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(e.region)},
+		Region: aws.String(region)},
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while creating session: %v", err)
+		return nil, fmt.Errorf("Error while creating session: %w", err)
 	}
 
-	e.client = elasticbeanstalk.New(sess)
+	return NewElasticbeanstalkWithSession(sess, sel)
+}
+
+// creates Elasticbeanstalk agent with clients from the shared session
+func NewElasticbeanstalkWithSession(sess *session.Session, sel *selector.Selector) (*Elasticbeanstalk, error) {
+	client := elasticbeanstalk.New(sess)
+	ratelimit.Install(&client.Handlers, "elasticbeanstalk")
+
+	e := NewElasticbeanstalkWithClient(client, sel)
+	e.region = aws.StringValue(sess.Config.Region)
+
+	return e, nil
+}
+
+// creates Elasticbeanstalk agent with the client, any implementation of AWS API can be used
+func NewElasticbeanstalkWithClient(client elasticbeanstalkiface.ElasticBeanstalkAPI, sel *selector.Selector) *Elasticbeanstalk {
+	e := Elasticbeanstalk{}
+	e.selector = sel
+	e.client = client
 	e.usageFuncs = createUsageFuncMap()
 
-	return &e, nil
+	return &e
 }
 
 // returns map of usage where key is the quotas code and value is the usage
-func (e *Elasticbeanstalk) GetUsage() (*map[string]int, error) {
-	usageMap := make(map[string]int)
+func (e *Elasticbeanstalk) GetUsage() (*map[string]float64, error) {
+	return e.GetUsageWithContext(context.Background())
+}
+
+// returns map of usage where key is the quotas code and value is the usage, requests are made with the context
+func (e *Elasticbeanstalk) GetUsageWithContext(ctx context.Context) (*map[string]float64, error) {
+	usageMap := make(map[string]float64)
 
-	for k, v := range *e.usageFuncs {
-		isQuotaAllowed := true
-		if e.allowedQuotas != nil && !utils.Find(*e.allowedQuotas, k) {
-			isQuotaAllowed = false
+	for _, k := range e.ListQuotasCodes() {
+		usage, err := e.GetQuotaUsageWithContext(ctx, k)
+		if err != nil {
+			return nil, err
 		}
-		if isQuotaAllowed {
-			usage, err := v(e.client)
 
-			if err != nil {
-				return nil, fmt.Errorf("Error while getting usage of ElasticBeanstalk quota: %v", err)
-			}
+		usageMap[k] = *usage
+	}
+
+	return &usageMap, nil
+}
+
+// returns usage of the quota by its code, requests are made with the context
+func (e *Elasticbeanstalk) GetQuotaUsageWithContext(ctx context.Context, quotaCode string) (*float64, error) {
+	f, ok := (*e.usageFuncs)[quotaCode]
+	if !ok || !e.selector.MatchQuotaCode(GetCode(), quotaCode) {
+		return nil, fmt.Errorf("%w: %v", errs.ErrNotSupported, quotaCode)
+	}
 
-			usageMap[k] = *usage
+	usage, err := f(ctx, e.client)
+	if err != nil {
+		return nil, fmt.Errorf("Error while getting usage of ElasticBeanstalk quota: %w", err)
+	}
+
+	return usage, nil
+}
+
+// returns sorted slice of allowed quotas codes which usage can be found
+func (e *Elasticbeanstalk) ListQuotasCodes() []string {
+	codes := make([]string, 0, len(*e.usageFuncs))
+
+	for k := range *e.usageFuncs {
+		if e.selector.MatchQuotaCode(GetCode(), k) {
+			codes = append(codes, k)
 		}
 	}
+	sort.Strings(codes)
 
-	return &usageMap, nil
+	return codes
+}
+
+// returns code for service in quotas
+func (e *Elasticbeanstalk) GetCode() string {
+	return GetCode()
+}
+
+// returns actions for IAM policy for allowed quotas, every action is associated with the quota code
+func (e *Elasticbeanstalk) GetIam() map[string]string {
+	actions := make(map[string]string)
+	all := GetIam()
+
+	for _, k := range e.ListQuotasCodes() {
+		actions[k] = all[k]
+	}
+
+	return actions
 }
 
 // creates map to associate quota code with func to calculate its usage
@@ -75,12 +138,12 @@ func createUsageFuncMap() *usageFuncMap {
 	return &usageFuncs
 }
 
-func getUsageApplicationVersions(client *elasticbeanstalk.ElasticBeanstalk) (*int, error) {
+func getUsageApplicationVersions(ctx context.Context, client elasticbeanstalkiface.ElasticBeanstalkAPI) (*float64, error) {
 	versions := make([]*elasticbeanstalk.ApplicationVersionDescription, 0, 0)
 
-	res, err := client.DescribeApplicationVersions(nil)
+	res, err := client.DescribeApplicationVersionsWithContext(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing ElasticBeanstalk application versions: %v", err)
+		return nil, fmt.Errorf("Error while describing ElasticBeanstalk application versions: %w", err)
 	}
 
 	versions = append(versions, res.ApplicationVersions...)
@@ -91,38 +154,38 @@ func getUsageApplicationVersions(client *elasticbeanstalk.ElasticBeanstalk) (*in
 			NextToken: nextToken,
 		}
 
-		res, err = client.DescribeApplicationVersions(params)
+		res, err = client.DescribeApplicationVersionsWithContext(ctx, params)
 		if err != nil {
-			return nil, fmt.Errorf("Error while describing ElasticBeanstalk application versions: %v", err)
+			return nil, fmt.Errorf("Error while describing ElasticBeanstalk application versions: %w", err)
 		}
 
 		versions = append(versions, res.ApplicationVersions...)
 		nextToken = res.NextToken
 	}
 
-	l := len(versions)
+	l := float64(len(versions))
 
 	return &l, nil
 }
 
-func getUsageApplications(client *elasticbeanstalk.ElasticBeanstalk) (*int, error) {
-	res, err := client.DescribeApplications(nil)
+func getUsageApplications(ctx context.Context, client elasticbeanstalkiface.ElasticBeanstalkAPI) (*float64, error) {
+	res, err := client.DescribeApplicationsWithContext(ctx, nil)
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing ElasticBeanstalk applications: %v", err)
+		return nil, fmt.Errorf("Error while describing ElasticBeanstalk applications: %w", err)
 	}
 
-	l := len(res.Applications)
+	l := float64(len(res.Applications))
 
 	return &l, nil
 }
 
-func getUsageEnvironments(client *elasticbeanstalk.ElasticBeanstalk) (*int, error) {
+func getUsageEnvironments(ctx context.Context, client elasticbeanstalkiface.ElasticBeanstalkAPI) (*float64, error) {
 	environments := make([]*elasticbeanstalk.EnvironmentDescription, 0, 0)
 
-	res, err := client.DescribeEnvironments(nil)
+	res, err := client.DescribeEnvironmentsWithContext(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing ElasticBeanstalk environments: %v", err)
+		return nil, fmt.Errorf("Error while describing ElasticBeanstalk environments: %w", err)
 	}
 
 	environments = append(environments, res.Environments...)
@@ -133,16 +196,16 @@ func getUsageEnvironments(client *elasticbeanstalk.ElasticBeanstalk) (*int, erro
 			NextToken: nextToken,
 		}
 
-		res, err = client.DescribeEnvironments(params)
+		res, err = client.DescribeEnvironmentsWithContext(ctx, params)
 		if err != nil {
-			return nil, fmt.Errorf("Error while describing ElasticBeanstalk environments: %v", err)
+			return nil, fmt.Errorf("Error while describing ElasticBeanstalk environments: %w", err)
 		}
 
 		environments = append(environments, res.Environments...)
 		nextToken = res.NextToken
 	}
 
-	l := len(environments)
+	l := float64(len(environments))
 
 	return &l, nil
 }
@@ -1,75 +1,142 @@
 package elb
 
 import (
+	"context"
 	"fmt"
+	"sort"
 
-	"github.com/vslchnk/aws_quotas_checker/utils"
+	"github.com/vslchnk/aws_quotas_checker/errs"
+	"github.com/vslchnk/aws_quotas_checker/ratelimit"
+	"github.com/vslchnk/aws_quotas_checker/selector"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elb/elbiface"
 	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
 )
 
 type usageFuncMap map[string]string
 
 type ELB struct {
-	region        string
-	clientv2      *elbv2.ELBV2
-	clientv1      *elb.ELB
-	usageFuncs    *usageFuncMap
-	allowedQuotas *[]string
+	region     string
+	clientv2   elbv2iface.ELBV2API
+	clientv1   elbiface.ELBAPI
+	usageFuncs *usageFuncMap
+	selector   *selector.Selector
 }
 
 // creates ELB agent
-func NewELB(region string, allowedQuotas *[]string) (*ELB, error) {
-	e := ELB{}
-	e.region = region
-	e.allowedQuotas = allowedQuotas
-
+func NewELB(region string, sel *selector.Selector) (*ELB, error) {
 	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(e.region)},
+		Region: aws.String(region)},
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while creating session: %v", err)
+		return nil, fmt.Errorf("Error while creating session: %w", err)
 	}
 
-	e.clientv2 = elbv2.New(sess)
-	e.clientv1 = elb.New(sess)
+	return NewELBWithSession(sess, sel)
+}
+
+// creates ELB agent with clients from the shared session
+func NewELBWithSession(sess *session.Session, sel *selector.Selector) (*ELB, error) {
+	clientv1 := elb.New(sess)
+	clientv2 := elbv2.New(sess)
+	ratelimit.Install(&clientv1.Handlers, "elasticloadbalancing")
+	ratelimit.Install(&clientv2.Handlers, "elasticloadbalancing")
+
+	e := NewELBWithClients(clientv1, clientv2, sel)
+	e.region = aws.StringValue(sess.Config.Region)
+
+	return e, nil
+}
+
+// creates ELB agent with the clients, any implementation of AWS API can be used
+func NewELBWithClients(clientv1 elbiface.ELBAPI, clientv2 elbv2iface.ELBV2API, sel *selector.Selector) *ELB {
+	e := ELB{}
+	e.selector = sel
+	e.clientv1 = clientv1
+	e.clientv2 = clientv2
 	e.usageFuncs = createUsageFuncMap()
 
-	return &e, nil
+	return &e
 }
 
 // returns map of usage where key is the quotas code and value is the usage
-func (e *ELB) GetUsage() (*map[string]int, error) {
-	usageMap := make(map[string]int)
+func (e *ELB) GetUsage() (*map[string]float64, error) {
+	return e.GetUsageWithContext(context.Background())
+}
+
+// returns map of usage where key is the quotas code and value is the usage, requests are made with the context
+func (e *ELB) GetUsageWithContext(ctx context.Context) (*map[string]float64, error) {
+	usageMap := make(map[string]float64)
 
-	for k, v := range *e.usageFuncs {
-		isQuotaAllowed := true
-		if e.allowedQuotas != nil && !utils.Find(*e.allowedQuotas, k) {
-			isQuotaAllowed = false
+	for _, k := range e.ListQuotasCodes() {
+		usage, err := e.GetQuotaUsageWithContext(ctx, k)
+		if err != nil {
+			return nil, err
 		}
-		if isQuotaAllowed {
-			var usage *int
-			var err error
 
-			if v == "classic" {
-				usage, err = getUsageClassicELBs(e.clientv1)
-			} else if v == "application" {
-				usage, err = getUsageApplicationELBs(e.clientv2)
-			}
+		usageMap[k] = *usage
+	}
+
+	return &usageMap, nil
+}
+
+// returns usage of the quota by its code, requests are made with the context
+func (e *ELB) GetQuotaUsageWithContext(ctx context.Context, quotaCode string) (*float64, error) {
+	f, ok := (*e.usageFuncs)[quotaCode]
+	if !ok || !e.selector.MatchQuotaCode(GetCode(), quotaCode) {
+		return nil, fmt.Errorf("%w: %v", errs.ErrNotSupported, quotaCode)
+	}
+
+	var usage *float64
+	var err error
 
-			if err != nil {
-				return nil, fmt.Errorf("Error while getting usage of ELB quota: %v", err)
-			}
+	if f == "classic" {
+		usage, err = getUsageClassicELBs(ctx, e.clientv1)
+	} else if f == "application" {
+		usage, err = getUsageApplicationELBs(ctx, e.clientv2)
+	}
 
-			usageMap[k] = *usage
+	if err != nil {
+		return nil, fmt.Errorf("Error while getting usage of ELB quota: %w", err)
+	}
+
+	return usage, nil
+}
+
+// returns sorted slice of allowed quotas codes which usage can be found
+func (e *ELB) ListQuotasCodes() []string {
+	codes := make([]string, 0, len(*e.usageFuncs))
+
+	for k := range *e.usageFuncs {
+		if e.selector.MatchQuotaCode(GetCode(), k) {
+			codes = append(codes, k)
 		}
 	}
+	sort.Strings(codes)
 
-	return &usageMap, nil
+	return codes
+}
+
+// returns code for service in quotas
+func (e *ELB) GetCode() string {
+	return GetCode()
+}
+
+// returns actions for IAM policy for allowed quotas, every action is associated with the quota code
+func (e *ELB) GetIam() map[string]string {
+	actions := make(map[string]string)
+	all := GetIam()
+
+	for _, k := range e.ListQuotasCodes() {
+		actions[k] = all[k]
+	}
+
+	return actions
 }
 
 // creates map to associate quota code with func to calculate its usage
@@ -82,40 +149,40 @@ func createUsageFuncMap() *usageFuncMap {
 	return &usageFuncs
 }
 
-func getUsageClassicELBs(client *elb.ELB) (*int, error) {
+func getUsageClassicELBs(ctx context.Context, client elbiface.ELBAPI) (*float64, error) {
 	elbs := make([]*elb.LoadBalancerDescription, 0, 0)
 
-	err := client.DescribeLoadBalancersPages(nil,
+	err := client.DescribeLoadBalancersPagesWithContext(ctx, nil,
 		func(page *elb.DescribeLoadBalancersOutput, lastPage bool) bool {
 			elbs = append(elbs, page.LoadBalancerDescriptions...)
 			return true
 		})
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing classic ELBs: %v", err)
+		return nil, fmt.Errorf("Error while describing classic ELBs: %w", err)
 	}
 
-	l := len(elbs)
+	l := float64(len(elbs))
 
 	return &l, nil
 }
 
-func getUsageApplicationELBs(client *elbv2.ELBV2) (*int, error) {
-	count := 0
+func getUsageApplicationELBs(ctx context.Context, client elbv2iface.ELBV2API) (*float64, error) {
+	count := 0.0
 	elbs := make([]*elbv2.LoadBalancer, 0, 0)
 
-	err := client.DescribeLoadBalancersPages(nil,
+	err := client.DescribeLoadBalancersPagesWithContext(ctx, nil,
 		func(page *elbv2.DescribeLoadBalancersOutput, lastPage bool) bool {
 			elbs = append(elbs, page.LoadBalancers...)
 			return true
 		})
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing application ELBs: %v", err)
+		return nil, fmt.Errorf("Error while describing application ELBs: %w", err)
 	}
 
 	for _, elb := range elbs {
-		if *elb.Type == "application" {
+		if aws.StringValue(elb.Type) == "application" {
 			count++
 		}
 	}
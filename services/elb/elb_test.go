@@ -0,0 +1,59 @@
+package elb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/vslchnk/aws_quotas_checker/errs"
+	"github.com/vslchnk/aws_quotas_checker/fakes"
+	"github.com/vslchnk/aws_quotas_checker/selector"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+)
+
+func loadBalancer(lbType string) *elbv2.LoadBalancer {
+	return &elbv2.LoadBalancer{Type: aws.String(lbType)}
+}
+
+func TestGetQuotaUsageWithContext(t *testing.T) {
+	clientv1 := &fakes.ELB{LoadBalancersPages: [][]*elb.LoadBalancerDescription{{{}, {}}, {{}}}}
+	clientv2 := &fakes.ELBV2{LoadBalancersPages: [][]*elbv2.LoadBalancer{
+		{loadBalancer("application"), loadBalancer("network")},
+		{loadBalancer("application"), loadBalancer("gateway"), {}},
+	}}
+
+	tests := []struct {
+		name      string
+		selector  *selector.Selector
+		quotaCode string
+		want      float64
+		wantErr   error
+	}{
+		{name: "classic", quotaCode: "L-E9E9831D", want: 3},
+		{name: "application", quotaCode: "L-53DA6B97", want: 2},
+		{name: "not allowed", selector: &selector.Selector{Exclude: []selector.Rule{{Quota: "L-53DA6B97"}}}, quotaCode: "L-53DA6B97", wantErr: errs.ErrNotSupported},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewELBWithClients(clientv1, clientv2, tt.selector)
+
+			usage, err := e.GetQuotaUsageWithContext(context.Background(), tt.quotaCode)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *usage != tt.want {
+				t.Errorf("usage = %v, want %v", *usage, tt.want)
+			}
+		})
+	}
+}
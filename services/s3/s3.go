@@ -1,78 +1,148 @@
 package s3
 
 import (
+	"context"
 	"fmt"
+	"sort"
 
-	"github.com/vslchnk/aws_quotas_checker/utils"
+	"github.com/vslchnk/aws_quotas_checker/errs"
+	"github.com/vslchnk/aws_quotas_checker/ratelimit"
+	"github.com/vslchnk/aws_quotas_checker/selector"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-sdk-go/service/s3control"
+	"github.com/aws/aws-sdk-go/service/s3control/s3controliface"
 	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
 )
 
 type usageFuncMap map[string]string
 
 type S3 struct {
 	region          string
-	session         *session.Session
-	clientS3        *s3.S3
-	clientS3Control *s3control.S3Control
+	clientS3        s3iface.S3API
+	clientS3Control s3controliface.S3ControlAPI
+	clientSTS       stsiface.STSAPI
 	usageFuncs      *usageFuncMap
-	allowedQuotas   *[]string
+	selector        *selector.Selector
 }
 
 // creates S3 agent
-func NewS3(region string, allowedQuotas *[]string) (*S3, error) {
-	s := S3{}
-	s.region = region
-	s.allowedQuotas = allowedQuotas
-
-	var err error
-	s.session, err = session.NewSession(&aws.Config{
-		Region: aws.String(s.region)},
+func NewS3(region string, sel *selector.Selector) (*S3, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(region)},
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while creating session: %v", err)
+		return nil, fmt.Errorf("Error while creating session: %w", err)
 	}
 
-	s.clientS3 = s3.New(s.session)
-	s.clientS3Control = s3control.New(s.session)
+	return NewS3WithSession(sess, sel)
+}
+
+// creates S3 agent with clients from the shared session
+func NewS3WithSession(sess *session.Session, sel *selector.Selector) (*S3, error) {
+	clientS3 := s3.New(sess)
+	clientS3Control := s3control.New(sess)
+	clientSTS := sts.New(sess)
+	ratelimit.Install(&clientS3.Handlers, "s3")
+	ratelimit.Install(&clientS3Control.Handlers, "s3")
+	ratelimit.Install(&clientSTS.Handlers, "sts")
+
+	s := NewS3WithClients(clientS3, clientS3Control, clientSTS, sel)
+	s.region = aws.StringValue(sess.Config.Region)
+
+	return s, nil
+}
+
+// creates S3 agent with the clients, any implementation of AWS API can be used
+func NewS3WithClients(clientS3 s3iface.S3API, clientS3Control s3controliface.S3ControlAPI, clientSTS stsiface.STSAPI, sel *selector.Selector) *S3 {
+	s := S3{}
+	s.selector = sel
+	s.clientS3 = clientS3
+	s.clientS3Control = clientS3Control
+	s.clientSTS = clientSTS
 	s.usageFuncs = createUsageFuncMap()
 
-	return &s, nil
+	return &s
 }
 
 // returns map of usage where key is the quotas code and value is the usage
-func (s *S3) GetUsage() (*map[string]int, error) {
-	usageMap := make(map[string]int)
+func (s *S3) GetUsage() (*map[string]float64, error) {
+	return s.GetUsageWithContext(context.Background())
+}
+
+// returns map of usage where key is the quotas code and value is the usage, requests are made with the context
+func (s *S3) GetUsageWithContext(ctx context.Context) (*map[string]float64, error) {
+	usageMap := make(map[string]float64)
 
-	for k, v := range *s.usageFuncs {
-		isQuotaAllowed := true
-		if s.allowedQuotas != nil && !utils.Find(*s.allowedQuotas, k) {
-			isQuotaAllowed = false
+	for _, k := range s.ListQuotasCodes() {
+		usage, err := s.GetQuotaUsageWithContext(ctx, k)
+		if err != nil {
+			return nil, err
 		}
-		if isQuotaAllowed {
-			var usage *int
-			var err error
 
-			if v == "s3" {
-				usage, err = getUsageBuckets(s.clientS3)
-			} else if v == "s3control" {
-				usage, err = getUsageAcessPoints(s.clientS3Control, s.session)
-			}
+		usageMap[k] = *usage
+	}
+
+	return &usageMap, nil
+}
+
+// returns usage of the quota by its code, requests are made with the context
+func (s *S3) GetQuotaUsageWithContext(ctx context.Context, quotaCode string) (*float64, error) {
+	f, ok := (*s.usageFuncs)[quotaCode]
+	if !ok || !s.selector.MatchQuotaCode(GetCode(), quotaCode) {
+		return nil, fmt.Errorf("%w: %v", errs.ErrNotSupported, quotaCode)
+	}
 
-			if err != nil {
-				return nil, fmt.Errorf("Error while getting usage of Autoscaling quota: %v", err)
-			}
+	var usage *float64
+	var err error
 
-			usageMap[k] = *usage
+	if f == "s3" {
+		usage, err = getUsageBuckets(ctx, s.clientS3)
+	} else if f == "s3control" {
+		usage, err = getUsageAcessPoints(ctx, s.clientS3Control, s.clientSTS)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Error while getting usage of S3 quota: %w", err)
+	}
+
+	return usage, nil
+}
+
+// returns sorted slice of allowed quotas codes which usage can be found
+func (s *S3) ListQuotasCodes() []string {
+	codes := make([]string, 0, len(*s.usageFuncs))
+
+	for k := range *s.usageFuncs {
+		if s.selector.MatchQuotaCode(GetCode(), k) {
+			codes = append(codes, k)
 		}
 	}
+	sort.Strings(codes)
 
-	return &usageMap, nil
+	return codes
+}
+
+// returns code for service in quotas
+func (s *S3) GetCode() string {
+	return GetCode()
+}
+
+// returns actions for IAM policy for allowed quotas, every action is associated with the quota code
+func (s *S3) GetIam() map[string]string {
+	actions := make(map[string]string)
+	all := GetIam()
+
+	for _, k := range s.ListQuotasCodes() {
+		actions[k] = all[k]
+	}
+
+	return actions
 }
 
 // creates map to associate quota code with func to calculate its usage
@@ -85,24 +155,22 @@ func createUsageFuncMap() *usageFuncMap {
 	return &usageFuncs
 }
 
-func getUsageBuckets(client *s3.S3) (*int, error) {
-	res, err := client.ListBuckets(nil)
+func getUsageBuckets(ctx context.Context, client s3iface.S3API) (*float64, error) {
+	res, err := client.ListBucketsWithContext(ctx, nil)
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing S3 buckets: %v", err)
+		return nil, fmt.Errorf("Error while describing S3 buckets: %w", err)
 	}
 
-	l := len(res.Buckets)
+	l := float64(len(res.Buckets))
 
 	return &l, nil
 }
 
-func getUsageAcessPoints(client *s3control.S3Control, session *session.Session) (*int, error) {
-	stsClient := sts.New(session)
-
-	res, err := stsClient.GetCallerIdentity(nil)
+func getUsageAcessPoints(ctx context.Context, client s3controliface.S3ControlAPI, stsClient stsiface.STSAPI) (*float64, error) {
+	res, err := stsClient.GetCallerIdentityWithContext(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("Error while getting caller identity: %v", err)
+		return nil, fmt.Errorf("Error while getting caller identity: %w", err)
 	}
 
 	points := make([]*s3control.AccessPoint, 0, 0)
@@ -111,17 +179,17 @@ func getUsageAcessPoints(client *s3control.S3Control, session *session.Session)
 		AccountId: res.Account,
 	}
 
-	err = client.ListAccessPointsPages(params,
+	err = client.ListAccessPointsPagesWithContext(ctx, params,
 		func(page *s3control.ListAccessPointsOutput, lastPage bool) bool {
 			points = append(points, page.AccessPointList...)
 			return true
 		})
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing S3 access points: %v", err)
+		return nil, fmt.Errorf("Error while describing S3 access points: %w", err)
 	}
 
-	l := len(points)
+	l := float64(len(points))
 
 	return &l, nil
 }
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/vslchnk/aws_quotas_checker/errs"
+	"github.com/vslchnk/aws_quotas_checker/selector"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// UsageProvider returns usage of quotas for a single service from its API
+type UsageProvider interface {
+	// returns code of the service in quotas
+	GetCode() string
+	// returns codes of the quotas which usage can be found by the provider
+	ListQuotasCodes() []string
+	// returns actions for IAM policy where key is the quota code and value is the action
+	GetIam() map[string]string
+	// returns map of usage where key is the quota code and value is the usage
+	GetUsage() (*map[string]float64, error)
+	// same as GetUsage, requests are made with the context
+	GetUsageWithContext(ctx context.Context) (*map[string]float64, error)
+	// returns usage of the quota by its code, requests are made with the context
+	GetQuotaUsageWithContext(ctx context.Context, quotaCode string) (*float64, error)
+}
+
+// ProviderFactory creates UsageProvider with clients from the shared session, nil selector means all quotas are allowed
+type ProviderFactory func(sess *session.Session, sel *selector.Selector) (UsageProvider, error)
+
+// Registry keeps factories of usage providers by service code
+type Registry struct {
+	mu        sync.RWMutex
+	factories map[string]ProviderFactory
+}
+
+// creates empty Registry
+func NewRegistry() *Registry {
+	r := Registry{}
+	r.factories = make(map[string]ProviderFactory)
+
+	return &r
+}
+
+// registers factory for the service code, already registered factory for the same code is replaced
+func (r *Registry) Register(serviceCode string, factory ProviderFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.factories[serviceCode] = factory
+}
+
+// removes factory for the service code
+func (r *Registry) Unregister(serviceCode string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.factories, serviceCode)
+}
+
+// returns sorted slice of registered service codes
+func (r *Registry) ListCodes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	codes := make([]string, 0, len(r.factories))
+	for k := range r.factories {
+		codes = append(codes, k)
+	}
+	sort.Strings(codes)
+
+	return codes
+}
+
+// creates provider for the service code with registered factory
+func (r *Registry) NewProvider(serviceCode string, sess *session.Session, sel *selector.Selector) (UsageProvider, error) {
+	r.mu.RLock()
+	factory, ok := r.factories[serviceCode]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: no provider registered for service %v", errs.ErrNotSupported, serviceCode)
+	}
+
+	p, err := factory(sess, sel)
+	if err != nil {
+		return nil, fmt.Errorf("Error while creating provider for service %v: %w", serviceCode, err)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,41 @@
+package services_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vslchnk/aws_quotas_checker/errs"
+	"github.com/vslchnk/aws_quotas_checker/fakes"
+	"github.com/vslchnk/aws_quotas_checker/selector"
+	"github.com/vslchnk/aws_quotas_checker/services"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func TestRegistry(t *testing.T) {
+	r := services.NewRegistry()
+	for _, code := range []string{"vpc", "ec2", "s3"} {
+		code := code
+		r.Register(code, func(sess *session.Session, sel *selector.Selector) (services.UsageProvider, error) {
+			return &fakes.Provider{Code: code}, nil
+		})
+	}
+	r.Unregister("s3")
+
+	codes := r.ListCodes()
+	if len(codes) != 2 || codes[0] != "ec2" || codes[1] != "vpc" {
+		t.Errorf("codes = %v, want [ec2 vpc]", codes)
+	}
+
+	p, err := r.NewProvider("vpc", nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.GetCode() != "vpc" {
+		t.Errorf("code = %v, want vpc", p.GetCode())
+	}
+
+	if _, err := r.NewProvider("s3", nil, nil); !errors.Is(err, errs.ErrNotSupported) {
+		t.Errorf("error = %v, want %v", err, errs.ErrNotSupported)
+	}
+}
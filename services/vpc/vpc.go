@@ -1,67 +1,130 @@
 package vpc
 
 import (
+	"context"
 	"fmt"
+	"sort"
 
-	"github.com/vslchnk/aws_quotas_checker/utils"
+	"github.com/vslchnk/aws_quotas_checker/errs"
+	"github.com/vslchnk/aws_quotas_checker/ratelimit"
+	"github.com/vslchnk/aws_quotas_checker/selector"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 )
 
-type getUsageFunc func(client *ec2.EC2) (*int, error)
+type getUsageFunc func(ctx context.Context, client ec2iface.EC2API) (*float64, error)
 
 type usageFuncMap map[string]getUsageFunc
 
 type VPC struct {
-	region        string
-	client        *ec2.EC2
-	usageFuncs    *usageFuncMap
-	allowedQuotas *[]string
+	region     string
+	client     ec2iface.EC2API
+	usageFuncs *usageFuncMap
+	selector   *selector.Selector
 }
 
 // creates S3 agent
-func NewVPC(region string, allowedQuotas *[]string) (*VPC, error) {
-	v := VPC{}
-	v.region = region
-	v.allowedQuotas = allowedQuotas
-
+func NewVPC(region string, sel *selector.Selector) (*VPC, error) {
 	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(v.region)},
+		Region: aws.String(region)},
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while creating session: %v", err)
+		return nil, fmt.Errorf("Error while creating session: %w", err)
 	}
 
-	v.client = ec2.New(sess)
+	return NewVPCWithSession(sess, sel)
+}
+
+// creates S3 agent with clients from the shared session
+func NewVPCWithSession(sess *session.Session, sel *selector.Selector) (*VPC, error) {
+	client := ec2.New(sess)
+	ratelimit.Install(&client.Handlers, "ec2")
+
+	v := NewVPCWithClient(client, sel)
+	v.region = aws.StringValue(sess.Config.Region)
+
+	return v, nil
+}
+
+// creates S3 agent with the client, any implementation of AWS API can be used
+func NewVPCWithClient(client ec2iface.EC2API, sel *selector.Selector) *VPC {
+	v := VPC{}
+	v.selector = sel
+	v.client = client
 	v.usageFuncs = createUsageFuncMap()
 
-	return &v, nil
+	return &v
 }
 
 // returns map of usage where key is the quotas code and value is the usage
-func (v *VPC) GetUsage() (*map[string]int, error) {
-	usageMap := make(map[string]int)
+func (v *VPC) GetUsage() (*map[string]float64, error) {
+	return v.GetUsageWithContext(context.Background())
+}
+
+// returns map of usage where key is the quotas code and value is the usage, requests are made with the context
+func (v *VPC) GetUsageWithContext(ctx context.Context) (*map[string]float64, error) {
+	usageMap := make(map[string]float64)
 
-	for k, val := range *v.usageFuncs {
-		isQuotaAllowed := true
-		if v.allowedQuotas != nil && !utils.Find(*v.allowedQuotas, k) {
-			isQuotaAllowed = false
+	for _, k := range v.ListQuotasCodes() {
+		usage, err := v.GetQuotaUsageWithContext(ctx, k)
+		if err != nil {
+			return nil, err
 		}
-		if isQuotaAllowed {
-			usage, err := val(v.client)
 
-			if err != nil {
-				return nil, fmt.Errorf("Error while getting usage of VPC quota: %v", err)
-			}
+		usageMap[k] = *usage
+	}
+
+	return &usageMap, nil
+}
+
+// returns usage of the quota by its code, requests are made with the context
+func (v *VPC) GetQuotaUsageWithContext(ctx context.Context, quotaCode string) (*float64, error) {
+	f, ok := (*v.usageFuncs)[quotaCode]
+	if !ok || !v.selector.MatchQuotaCode(GetCode(), quotaCode) {
+		return nil, fmt.Errorf("%w: %v", errs.ErrNotSupported, quotaCode)
+	}
+
+	usage, err := f(ctx, v.client)
+	if err != nil {
+		return nil, fmt.Errorf("Error while getting usage of VPC quota: %w", err)
+	}
+
+	return usage, nil
+}
 
-			usageMap[k] = *usage
+// returns sorted slice of allowed quotas codes which usage can be found
+func (v *VPC) ListQuotasCodes() []string {
+	codes := make([]string, 0, len(*v.usageFuncs))
+
+	for k := range *v.usageFuncs {
+		if v.selector.MatchQuotaCode(GetCode(), k) {
+			codes = append(codes, k)
 		}
 	}
+	sort.Strings(codes)
 
-	return &usageMap, nil
+	return codes
+}
+
+// returns code for service in quotas
+func (v *VPC) GetCode() string {
+	return GetCode()
+}
+
+// returns actions for IAM policy for allowed quotas, every action is associated with the quota code
+func (v *VPC) GetIam() map[string]string {
+	actions := make(map[string]string)
+	all := GetIam()
+
+	for _, k := range v.ListQuotasCodes() {
+		actions[k] = all[k]
+	}
+
+	return actions
 }
 
 // creates map to associate quota code with func to calculate its usage
@@ -80,138 +143,138 @@ func createUsageFuncMap() *usageFuncMap {
 	return &usageFuncs
 }
 
-func getUsageGatewayVpcEndPoint(client *ec2.EC2) (*int, error) {
-	return getUsageVpcEndpoints(client, "Gateway")
+func getUsageGatewayVpcEndPoint(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getUsageVpcEndpoints(ctx, client, "Gateway")
 }
 
-func getUsageInterfaceVpcEndPoint(client *ec2.EC2) (*int, error) {
-	return getUsageVpcEndpoints(client, "Interface")
+func getUsageInterfaceVpcEndPoint(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
+	return getUsageVpcEndpoints(ctx, client, "Interface")
 }
 
-func getUsageEgressOnlyInternetGateways(client *ec2.EC2) (*int, error) {
+func getUsageEgressOnlyInternetGateways(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
 	gateways := make([]*ec2.EgressOnlyInternetGateway, 0, 0)
 
-	err := client.DescribeEgressOnlyInternetGatewaysPages(nil,
+	err := client.DescribeEgressOnlyInternetGatewaysPagesWithContext(ctx, nil,
 		func(page *ec2.DescribeEgressOnlyInternetGatewaysOutput, lastPage bool) bool {
 			gateways = append(gateways, page.EgressOnlyInternetGateways...)
 			return true
 		})
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing egress only internet gateways: %v", err)
+		return nil, fmt.Errorf("Error while describing egress only internet gateways: %w", err)
 	}
 
-	l := len(gateways)
+	l := float64(len(gateways))
 
 	return &l, nil
 }
 
-func getUsageInternetGateways(client *ec2.EC2) (*int, error) {
+func getUsageInternetGateways(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
 	gateways := make([]*ec2.InternetGateway, 0, 0)
 
-	err := client.DescribeInternetGatewaysPages(nil,
+	err := client.DescribeInternetGatewaysPagesWithContext(ctx, nil,
 		func(page *ec2.DescribeInternetGatewaysOutput, lastPage bool) bool {
 			gateways = append(gateways, page.InternetGateways...)
 			return true
 		})
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing internet gateways: %v", err)
+		return nil, fmt.Errorf("Error while describing internet gateways: %w", err)
 	}
 
-	l := len(gateways)
+	l := float64(len(gateways))
 
 	return &l, nil
 }
 
-func getUsageNetworkAcls(client *ec2.EC2) (*int, error) {
+func getUsageNetworkAcls(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
 	acls := make([]*ec2.NetworkAcl, 0, 0)
 
-	err := client.DescribeNetworkAclsPages(nil,
+	err := client.DescribeNetworkAclsPagesWithContext(ctx, nil,
 		func(page *ec2.DescribeNetworkAclsOutput, lastPage bool) bool {
 			acls = append(acls, page.NetworkAcls...)
 			return true
 		})
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing network acls: %v", err)
+		return nil, fmt.Errorf("Error while describing network acls: %w", err)
 	}
 
-	l := len(acls)
+	l := float64(len(acls))
 
 	return &l, nil
 }
 
-func getUsageNetworkInterfaces(client *ec2.EC2) (*int, error) {
+func getUsageNetworkInterfaces(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
 	interfaces := make([]*ec2.NetworkInterface, 0, 0)
 
-	err := client.DescribeNetworkInterfacesPages(nil,
+	err := client.DescribeNetworkInterfacesPagesWithContext(ctx, nil,
 		func(page *ec2.DescribeNetworkInterfacesOutput, lastPage bool) bool {
 			interfaces = append(interfaces, page.NetworkInterfaces...)
 			return true
 		})
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing network interfaces: %v", err)
+		return nil, fmt.Errorf("Error while describing network interfaces: %w", err)
 	}
 
-	l := len(interfaces)
+	l := float64(len(interfaces))
 
 	return &l, nil
 }
 
-func getUsageSecurityGroups(client *ec2.EC2) (*int, error) {
+func getUsageSecurityGroups(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
 	sgs := make([]*ec2.SecurityGroup, 0, 0)
 
-	err := client.DescribeSecurityGroupsPages(nil,
+	err := client.DescribeSecurityGroupsPagesWithContext(ctx, nil,
 		func(page *ec2.DescribeSecurityGroupsOutput, lastPage bool) bool {
 			sgs = append(sgs, page.SecurityGroups...)
 			return true
 		})
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing security groups: %v", err)
+		return nil, fmt.Errorf("Error while describing security groups: %w", err)
 	}
 
-	l := len(sgs)
+	l := float64(len(sgs))
 
 	return &l, nil
 }
 
-func getUsageVpcs(client *ec2.EC2) (*int, error) {
+func getUsageVpcs(ctx context.Context, client ec2iface.EC2API) (*float64, error) {
 	vpcs := make([]*ec2.Vpc, 0, 0)
 
-	err := client.DescribeVpcsPages(nil,
+	err := client.DescribeVpcsPagesWithContext(ctx, nil,
 		func(page *ec2.DescribeVpcsOutput, lastPage bool) bool {
 			vpcs = append(vpcs, page.Vpcs...)
 			return true
 		})
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing VPCs: %v", err)
+		return nil, fmt.Errorf("Error while describing VPCs: %w", err)
 	}
 
-	l := len(vpcs)
+	l := float64(len(vpcs))
 
 	return &l, nil
 }
 
-func getUsageVpcEndpoints(client *ec2.EC2, gType string) (*int, error) {
-	count := 0
+func getUsageVpcEndpoints(ctx context.Context, client ec2iface.EC2API, gType string) (*float64, error) {
+	count := 0.0
 	endpoints := make([]*ec2.VpcEndpoint, 0, 0)
 
-	err := client.DescribeVpcEndpointsPages(nil,
+	err := client.DescribeVpcEndpointsPagesWithContext(ctx, nil,
 		func(page *ec2.DescribeVpcEndpointsOutput, lastPage bool) bool {
 			endpoints = append(endpoints, page.VpcEndpoints...)
 			return true
 		})
 
 	if err != nil {
-		return nil, fmt.Errorf("Error while describing vpc endpoints: %v", err)
+		return nil, fmt.Errorf("Error while describing vpc endpoints: %w", err)
 	}
 
 	for _, endpoint := range endpoints {
-		if *endpoint.VpcEndpointType == gType {
+		if aws.StringValue(endpoint.VpcEndpointType) == gType {
 			count++
 		}
 	}
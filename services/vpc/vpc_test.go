@@ -0,0 +1,69 @@
+package vpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vslchnk/aws_quotas_checker/fakes"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func endpoint(endpointType string) *ec2.VpcEndpoint {
+	return &ec2.VpcEndpoint{VpcEndpointType: aws.String(endpointType)}
+}
+
+func TestGetUsageVpcEndpoints(t *testing.T) {
+	pages := [][]*ec2.VpcEndpoint{
+		{endpoint("Gateway"), endpoint("Interface"), endpoint("Interface")},
+		{},
+		{endpoint("GatewayLoadBalancer"), endpoint("Interface"), {}},
+	}
+
+	tests := []struct {
+		quotaCode string
+		want      float64
+	}{
+		{"L-1B52E74A", 1},
+		{"L-29B6F2EB", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.quotaCode, func(t *testing.T) {
+			v := NewVPCWithClient(&fakes.EC2{VpcEndpointsPages: pages}, nil)
+
+			usage, err := v.GetQuotaUsageWithContext(context.Background(), tt.quotaCode)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *usage != tt.want {
+				t.Errorf("usage = %v, want %v", *usage, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetUsageVpcsPagination(t *testing.T) {
+	tests := []struct {
+		name  string
+		pages [][]*ec2.Vpc
+		want  float64
+	}{
+		{"no pages", nil, 0},
+		{"single page", [][]*ec2.Vpc{{{}, {}}}, 2},
+		{"several pages", [][]*ec2.Vpc{{{}, {}}, {}, {{}}}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage, err := getUsageVpcs(context.Background(), &fakes.EC2{VpcsPages: tt.pages})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *usage != tt.want {
+				t.Errorf("usage = %v, want %v", *usage, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package units
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// size of units of Service Quotas in bytes
+var byteUnits = map[string]float64{
+	"Bytes":     1,
+	"Kilobytes": 1 << 10,
+	"Megabytes": 1 << 20,
+	"Gigabytes": 1 << 30,
+	"Terabytes": 1 << 40,
+	"Petabytes": 1 << 50,
+}
+
+// short names of binary units from the smallest to the biggest one
+var byteNames = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+
+// short names of units of time
+var timeNames = map[string]string{
+	"Seconds":      "s",
+	"Milliseconds": "ms",
+	"Microseconds": "µs",
+}
+
+// returns human-readable value with the unit of Service Quotas, e.g. "1.5 TiB", "100/s", "12 vCPUs",
+// counts and values without unit are returned as numbers
+func Format(value float64, unit string) string {
+	base, perSecond := splitRate(unit)
+
+	var res string
+	switch {
+	case base == "" || base == "None" || base == "Count":
+		res = FormatNumber(value)
+	case base == "Percent":
+		res = FormatNumber(value) + "%"
+	case byteUnits[base] > 0:
+		res = formatBytes(value * byteUnits[base])
+	case timeNames[base] != "":
+		res = FormatNumber(value) + " " + timeNames[base]
+	default:
+		res = FormatNumber(value) + " " + base
+	}
+
+	if perSecond {
+		return res + "/s"
+	}
+
+	return res
+}
+
+// short names of periods of rate-based quotas
+var periodNames = map[time.Duration]string{
+	time.Microsecond:   "µs",
+	time.Millisecond:   "ms",
+	time.Second:        "s",
+	time.Minute:        "min",
+	time.Hour:          "h",
+	24 * time.Hour:     "day",
+	7 * 24 * time.Hour: "week",
+}
+
+// returns human-readable value of the rate-based quota with its period, e.g. "100/s", "5 GiB/day",
+// value is formatted without period if period is zero
+func FormatRate(value float64, unit string, period time.Duration) string {
+	if period <= 0 {
+		return Format(value, unit)
+	}
+
+	name, ok := periodNames[period]
+	if !ok {
+		name = period.String()
+	}
+
+	return Format(value, unit) + "/" + name
+}
+
+// returns number with at most two decimals and without trailing zeros
+func FormatNumber(value float64) string {
+	return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64)
+}
+
+// returns duration in days, hours or minutes with at most two decimals, e.g. "9.5d", "12h" or "30min"
+func FormatDuration(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return FormatNumber(d.Hours()/24) + "d"
+	case d >= time.Hour:
+		return FormatNumber(d.Hours()) + "h"
+	}
+
+	return FormatNumber(d.Minutes()) + "min"
+}
+
+// returns percentage of usage from the limit, zero is returned for not positive limit
+func Percent(usage float64, limit float64) float64 {
+	if limit <= 0 {
+		return 0
+	}
+
+	return usage * 100 / limit
+}
+
+// splits unit to the base one and flag if it is per second, e.g. "Count/Second"
+func splitRate(unit string) (string, bool) {
+	if strings.HasSuffix(unit, "/Second") {
+		return strings.TrimSuffix(unit, "/Second"), true
+	}
+
+	return unit, false
+}
+
+// returns number of bytes in the biggest binary unit which keeps it not less than 1
+func formatBytes(bytes float64) string {
+	i := 0
+	for math.Abs(bytes) >= 1024 && i < len(byteNames)-1 {
+		bytes /= 1024
+		i++
+	}
+
+	return FormatNumber(bytes) + " " + byteNames[i]
+}
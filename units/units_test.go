@@ -0,0 +1,96 @@
+package units
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		value float64
+		unit  string
+		want  string
+	}{
+		{12, "None", "12"},
+		{12, "", "12"},
+		{2.5, "Count", "2.5"},
+		{1.0 / 3, "Count", "0.33"},
+		{100, "Count/Second", "100/s"},
+		{1536, "Gigabytes", "1.5 TiB"},
+		{512, "Bytes", "512 B"},
+		{2048, "Kilobytes/Second", "2 MiB/s"},
+		{30, "Seconds", "30 s"},
+		{75, "Percent", "75%"},
+		{32, "vCPUs", "32 vCPUs"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := Format(tt.value, tt.unit); got != tt.want {
+				t.Errorf("Format(%v, %q) = %q, want %q", tt.value, tt.unit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatRate(t *testing.T) {
+	tests := []struct {
+		value  float64
+		unit   string
+		period time.Duration
+		want   string
+	}{
+		{100, "None", time.Second, "100/s"},
+		{20, "Count", time.Minute, "20/min"},
+		{5, "Gigabytes", 24 * time.Hour, "5 GiB/day"},
+		{3, "None", 10 * time.Second, "3/10s"},
+		{7, "None", 0, "7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := FormatRate(tt.value, tt.unit, tt.period); got != tt.want {
+				t.Errorf("FormatRate(%v, %q, %v) = %q, want %q", tt.value, tt.unit, tt.period, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{9*24*time.Hour + 12*time.Hour, "9.5d"},
+		{14 * 24 * time.Hour, "14d"},
+		{90 * time.Minute, "1.5h"},
+		{30 * time.Minute, "30min"},
+		{0, "0min"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := FormatDuration(tt.d); got != tt.want {
+				t.Errorf("FormatDuration(%v) = %q, want %q", tt.d, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		usage float64
+		limit float64
+		want  float64
+	}{
+		{1, 4, 25},
+		{0.5, 2, 25},
+		{3, 0, 0},
+	}
+
+	for _, tt := range tests {
+		if got := Percent(tt.usage, tt.limit); got != tt.want {
+			t.Errorf("Percent(%v, %v) = %v, want %v", tt.usage, tt.limit, got, tt.want)
+		}
+	}
+}
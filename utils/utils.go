@@ -2,7 +2,11 @@ package utils
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
 // checks if value exist in the string slice
@@ -16,12 +20,6 @@ func Find(slice []string, val string) bool {
 	return false
 }
 
-// exits with error code 1 and returns error message
-func ExitErrorf(msg string, args ...interface{}) {
-	fmt.Fprintf(os.Stderr, msg+"\n", args...)
-	os.Exit(1)
-}
-
 // merges maps with string keys and int values
 func MergeMaps(maps ...map[string]int) *map[string][]int {
 	res := make(map[string][]int)
@@ -52,3 +50,99 @@ func CheckKeyMap(m map[string]*[]string, key string) bool {
 
 	return ok
 }
+
+// returns Levenshtein distance between strings, letters are compared case-insensitively
+func Distance(a string, b string) int {
+	ra := []rune(strings.ToLower(a))
+	rb := []rune(strings.ToLower(b))
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// returns up to limit candidates closest to the value sorted by distance, candidates which differ
+// in more than a third of the value are skipped
+func Suggest(value string, candidates []string, limit int) []string {
+	maxDistance := len([]rune(value)) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+
+	distances := make(map[string]int)
+	res := make([]string, 0, 0)
+	for _, c := range candidates {
+		if _, ok := distances[c]; ok {
+			continue
+		}
+		d := Distance(value, c)
+		if d <= maxDistance || strings.HasPrefix(strings.ToLower(c), strings.ToLower(value)) {
+			distances[c] = d
+			res = append(res, c)
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		if distances[res[i]] != distances[res[j]] {
+			return distances[res[i]] < distances[res[j]]
+		}
+
+		return res[i] < res[j]
+	})
+
+	if len(res) > limit {
+		res = res[:limit]
+	}
+
+	return res
+}
+
+// writes data to the file which is replaced atomically, so readers never see partially written file,
+// directory of the file is created if it doesn't exist
+func WriteFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("Error while creating directory: %w", err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("Error while creating file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("Error while writing file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("Error while writing file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("Error while replacing file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{"", "", 0},
+		{"ec2", "ec2", 0},
+		{"EC2", "ec2", 0},
+		{"ecc2", "ec2", 1},
+		{"access-analyzr", "access-analyzer", 1},
+		{"kitten", "sitting", 3},
+	}
+
+	for _, tt := range tests {
+		if got := Distance(tt.a, tt.b); got != tt.want {
+			t.Errorf("Distance(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"ec2", "ecs", "eks", "elasticloadbalancing", "elasticfilesystem", "vpc"}
+
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"ecc2", []string{"ec2", "ecs"}},
+		{"elastic", []string{"elasticfilesystem", "elasticloadbalancing"}},
+		{"dynamodb", []string{}},
+	}
+
+	for _, tt := range tests {
+		if got := Suggest(tt.value, candidates, 3); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Suggest(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state", "file.json")
+
+	for _, data := range []string{"first", "second"} {
+		if err := WriteFileAtomic(path, []byte(data)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(got) != data {
+			t.Errorf("data = %q, want %q", got, data)
+		}
+	}
+
+	// temporary files are removed
+	files, err := ioutil.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("files = %v, want only the written file", len(files))
+	}
+
+	if err := WriteFileAtomic(filepath.Join(path, "file.json"), []byte("data")); err == nil {
+		t.Errorf("expected error for file in place of directory")
+	}
+}